@@ -76,6 +76,7 @@ const (
 	RootPathFull
 	ObjectExistsAsPrefix
 	AllAccessDisabled
+	PreconditionFailed
 )
 
 // APIError code to Error structure map
@@ -250,6 +251,11 @@ var errorCodeResponse = map[int]APIError{
 		Description:    "All access to this bucket has been disabled.",
 		HTTPStatusCode: http.StatusForbidden,
 	},
+	PreconditionFailed: {
+		Code:           "PreconditionFailed",
+		Description:    "At least one of the preconditions you specified did not hold.",
+		HTTPStatusCode: http.StatusPreconditionFailed,
+	},
 }
 
 // errorCodeError provides errorCode to Error. It returns empty if the code provided is unknown
@@ -22,6 +22,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/minio/minio/pkg/probe"
 	"github.com/minio/minio/pkg/s3/signature4"
 )
 
@@ -50,6 +51,14 @@ func isRequestSignatureV4(r *http.Request) bool {
 	return false
 }
 
+// Verify if request has AWS Signature Version '2'.
+func isRequestSignatureV2(r *http.Request) bool {
+	if _, ok := r.Header["Authorization"]; ok {
+		return signature4.IsSignatureV2(r.Header.Get("Authorization"))
+	}
+	return false
+}
+
 // Verify if request has AWS Presignature Version '4'.
 func isRequestPresignedSignatureV4(r *http.Request) bool {
 	if _, ok := r.URL.Query()["X-Amz-Credential"]; ok {
@@ -70,32 +79,49 @@ func isRequestPostPolicySignatureV4(r *http.Request) bool {
 
 // Verify if request requires ACL check.
 func isRequestRequiresACLCheck(r *http.Request) bool {
-	if isRequestSignatureV4(r) || isRequestPresignedSignatureV4(r) || isRequestPostPolicySignatureV4(r) {
+	if isRequestSignatureV4(r) || isRequestSignatureV2(r) || isRequestPresignedSignatureV4(r) || isRequestPostPolicySignatureV4(r) {
 		return false
 	}
 	return true
 }
 
-// Verify if request has valid AWS Signature Version '4'.
-func isSignV4ReqAuthenticated(sign *signature4.Sign, r *http.Request) bool {
+// Verify if request has valid AWS Signature Version '4', returns the error code to respond with
+// if it does not.
+func isSignV4ReqAuthenticated(sign *signature4.Sign, r *http.Request) (int, bool) {
 	auth := sign.SetHTTPRequestToVerify(r)
-	if isRequestSignatureV4(r) {
+	// Health checks and other internal paths do not require a signature.
+	if auth.IsSystemRequest() {
+		return 0, true
+	}
+	if isRequestSignatureV4(r) || isRequestSignatureV2(r) {
 		dummyPayload := sha256.Sum256([]byte(""))
-		ok, err := auth.DoesSignatureMatch(hex.EncodeToString(dummyPayload[:]))
-		if err != nil {
+		if err := auth.VerifySignature(hex.EncodeToString(dummyPayload[:])); err != nil {
 			errorIf(err.Trace(), "Signature verification failed.", nil)
-			return false
+			return getSignatureErrorCode(err), false
 		}
-		return ok
+		return 0, true
 	} else if isRequestPresignedSignatureV4(r) {
 		ok, err := auth.DoesPresignedSignatureMatch()
 		if err != nil {
 			errorIf(err.Trace(), "Presigned signature verification failed.", nil)
-			return false
+			return getSignatureErrorCode(err), false
 		}
-		return ok
+		return SignatureDoesNotMatch, ok
+	}
+	return SignatureDoesNotMatch, false
+}
+
+// getSignatureErrorCode - error code of a failed signature verification, signatures which could
+// not be verified at all do not match.
+func getSignatureErrorCode(err *probe.Error) int {
+	switch err.ToGoError().(type) {
+	case signature4.AuthorizationHeaderMalformed:
+		return AuthorizationHeaderMalformed
+	case signature4.RequestTimeTooSkewed:
+		return RequestTimeTooSkewed
+	default:
+		return SignatureDoesNotMatch
 	}
-	return false
 }
 
 // authHandler - handles all the incoming authorization headers and
@@ -132,7 +158,7 @@ func (a authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify if the signature algorithms are known.
-	if !isRequestSignatureV4(r) && !isRequestJWT(r) {
+	if !isRequestSignatureV4(r) && !isRequestSignatureV2(r) && !isRequestJWT(r) {
 		writeErrorResponse(w, r, SignatureVersionNotSupported, r.URL.Path)
 		return
 	}
@@ -43,8 +43,8 @@ func (api storageAPI) GetBucketLocationHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	if !isSignV4ReqAuthenticated(api.Signature, r) {
-		writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
+	if errCode, ok := isSignV4ReqAuthenticated(api.Signature, r); !ok {
+		writeErrorResponse(w, r, errCode, r.URL.Path)
 		return
 	}
 
@@ -90,8 +90,8 @@ func (api storageAPI) ListMultipartUploadsHandler(w http.ResponseWriter, r *http
 		return
 	}
 
-	if !isSignV4ReqAuthenticated(api.Signature, r) {
-		writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
+	if errCode, ok := isSignV4ReqAuthenticated(api.Signature, r); !ok {
+		writeErrorResponse(w, r, errCode, r.URL.Path)
 		return
 	}
 
@@ -141,8 +141,8 @@ func (api storageAPI) ListObjectsHandler(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	if !isSignV4ReqAuthenticated(api.Signature, r) {
-		writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
+	if errCode, ok := isSignV4ReqAuthenticated(api.Signature, r); !ok {
+		writeErrorResponse(w, r, errCode, r.URL.Path)
 		return
 	}
 
@@ -192,8 +192,8 @@ func (api storageAPI) ListBucketsHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if !isSignV4ReqAuthenticated(api.Signature, r) {
-		writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
+	if errCode, ok := isSignV4ReqAuthenticated(api.Signature, r); !ok {
+		writeErrorResponse(w, r, errCode, r.URL.Path)
 		return
 	}
 
@@ -253,7 +253,7 @@ func (api storageAPI) PutBucketHandler(w http.ResponseWriter, r *http.Request) {
 			writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
 			return
 		}
-	} else if isRequestSignatureV4(r) {
+	} else if isRequestSignatureV4(r) || isRequestSignatureV2(r) {
 		// Verify signature for the incoming body if any.
 		locationBytes, e := ioutil.ReadAll(r.Body)
 		if e != nil {
@@ -355,10 +355,10 @@ func (api storageAPI) PostPolicyBucketHandler(w http.ResponseWriter, r *http.Req
 	var ok bool
 
 	// Set http request for signature.
-	api.Signature.SetHTTPRequestToVerify(r)
+	auth := api.Signature.SetHTTPRequestToVerify(r)
 
 	// Verify policy signature.
-	ok, err = api.Signature.DoesPolicySignatureMatch(formValues)
+	ok, err = auth.DoesPolicySignatureMatch(formValues)
 	if err != nil {
 		errorIf(err.Trace(), "Unable to verify signature.", nil)
 		writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
@@ -412,8 +412,8 @@ func (api storageAPI) PutBucketACLHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if !isSignV4ReqAuthenticated(api.Signature, r) {
-		writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
+	if errCode, ok := isSignV4ReqAuthenticated(api.Signature, r); !ok {
+		writeErrorResponse(w, r, errCode, r.URL.Path)
 		return
 	}
 
@@ -454,8 +454,8 @@ func (api storageAPI) GetBucketACLHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if !isSignV4ReqAuthenticated(api.Signature, r) {
-		writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
+	if errCode, ok := isSignV4ReqAuthenticated(api.Signature, r); !ok {
+		writeErrorResponse(w, r, errCode, r.URL.Path)
 		return
 	}
 
@@ -498,8 +498,8 @@ func (api storageAPI) HeadBucketHandler(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	if !isSignV4ReqAuthenticated(api.Signature, r) {
-		writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
+	if errCode, ok := isSignV4ReqAuthenticated(api.Signature, r); !ok {
+		writeErrorResponse(w, r, errCode, r.URL.Path)
 		return
 	}
 
@@ -529,8 +529,8 @@ func (api storageAPI) DeleteBucketHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if !isSignV4ReqAuthenticated(api.Signature, r) {
-		writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
+	if errCode, ok := isSignV4ReqAuthenticated(api.Signature, r); !ok {
+		writeErrorResponse(w, r, errCode, r.URL.Path)
 		return
 	}
 
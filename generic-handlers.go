@@ -17,13 +17,12 @@
 package main
 
 import (
-	"errors"
 	"net/http"
 	"path"
 	"strings"
-	"time"
 
 	router "github.com/gorilla/mux"
+	"github.com/minio/minio/pkg/s3/signature4"
 	"github.com/rs/cors"
 )
 
@@ -44,49 +43,6 @@ func registerHandlers(mux *router.Router, handlerFns ...HandlerFunc) http.Handle
 	return f
 }
 
-// Attempts to parse date string into known date layouts. Date layouts
-// currently supported are ``time.RFC1123``, ``time.RFC1123Z`` and
-// special ``iso8601Format``.
-func parseKnownLayouts(date string) (time.Time, error) {
-	parsedTime, e := time.Parse(time.RFC1123, date)
-	if e == nil {
-		return parsedTime, nil
-	}
-	parsedTime, e = time.Parse(time.RFC1123Z, date)
-	if e == nil {
-		return parsedTime, nil
-	}
-	parsedTime, e = time.Parse(iso8601Format, date)
-	if e == nil {
-		return parsedTime, nil
-	}
-	return time.Time{}, e
-}
-
-// Parse date string from incoming header, current supports and verifies
-// follow HTTP headers.
-//
-//  - X-Amz-Date
-//  - X-Minio-Date
-//  - Date
-//
-// In following time layouts ``time.RFC1123``, ``time.RFC1123Z`` and ``iso8601Format``.
-func parseDateHeader(req *http.Request) (time.Time, error) {
-	amzDate := req.Header.Get(http.CanonicalHeaderKey("x-amz-date"))
-	if amzDate != "" {
-		return parseKnownLayouts(amzDate)
-	}
-	minioDate := req.Header.Get(http.CanonicalHeaderKey("x-minio-date"))
-	if minioDate != "" {
-		return parseKnownLayouts(minioDate)
-	}
-	genericDate := req.Header.Get("Date")
-	if genericDate != "" {
-		return parseKnownLayouts(genericDate)
-	}
-	return time.Time{}, errors.New("Date header missing, invalid request.")
-}
-
 // Adds redirect rules for incoming requests.
 type redirectHandler struct {
 	handler        http.Handler
@@ -150,32 +106,25 @@ func (h minioPrivateBucketHandler) ServeHTTP(w http.ResponseWriter, r *http.Requ
 	h.handler.ServeHTTP(w, r)
 }
 
+// timeHandler - rejects signed requests whose date differs from the server time by more than the
+// allowed skew of the signature, verified once on arrival before the request body is read.
 type timeHandler struct {
-	handler http.Handler
+	handler   http.Handler
+	signature *signature4.Sign
 }
 
-// setTimeValidityHandler to validate parsable time over http header
-func setTimeValidityHandler(h http.Handler) http.Handler {
-	return timeHandler{h}
+// setTimeValidityHandler to validate the date of signed requests
+func setTimeValidityHandler(sign *signature4.Sign) HandlerFunc {
+	return func(h http.Handler) http.Handler {
+		return timeHandler{handler: h, signature: sign}
+	}
 }
 
 func (h timeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Verify if date headers are set, if not reject the request
-	if r.Header.Get("Authorization") != "" {
-		date, e := parseDateHeader(r)
-		if e != nil {
-			// All our internal APIs are sensitive towards Date
-			// header, for all requests where Date header is not
-			// present we will reject such clients.
-			writeErrorResponse(w, r, RequestTimeTooSkewed, r.URL.Path)
-			return
-		}
-		duration := time.Since(date)
-		minutes := time.Duration(5) * time.Minute
-		// Verify if the request date header is more than 5minutes
-		// late, reject such clients.
-		if duration.Minutes() > minutes.Minutes() {
-			writeErrorResponse(w, r, RequestTimeTooSkewed, r.URL.Path)
+	if isRequestSignatureV4(r) || isRequestSignatureV2(r) {
+		if err := h.signature.SetHTTPRequestToVerify(r).VerifyRequestTime(); err != nil {
+			errorIf(err.Trace(), "Request time verification failed.", nil)
+			writeErrorResponse(w, r, getSignatureErrorCode(err), r.URL.Path)
 			return
 		}
 	}
@@ -17,12 +17,15 @@
 package main
 
 import (
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/minio/minio/pkg/fs"
+	"github.com/minio/minio/pkg/s3/signature4"
 )
 
 const (
@@ -47,6 +50,54 @@ func setResponseHeaders(w http.ResponseWriter, reqParams url.Values) {
 	}
 }
 
+// isETagEqual - compare two entity tags, weak comparison ignores the weakness
+// indicator `W/` while strong comparison requires both entity tags to be strong.
+func isETagEqual(etag1, etag2 string, weak bool) bool {
+	if weak {
+		return strings.TrimPrefix(etag1, "W/") == strings.TrimPrefix(etag2, "W/")
+	}
+	if strings.HasPrefix(etag1, "W/") || strings.HasPrefix(etag2, "W/") {
+		return false
+	}
+	return etag1 == etag2
+}
+
+// isETagMatch - verify if any of the comma separated entity tags in a
+// conditional header matches with the object entity tag, `*` matches any.
+func isETagMatch(header, etag string, weak bool) bool {
+	for _, headerETag := range strings.Split(header, ",") {
+		headerETag = strings.TrimSpace(headerETag)
+		if headerETag == "*" || isETagEqual(headerETag, etag, weak) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkETagPreconditions - verify `If-Match` with strong comparison and
+// `If-None-Match` with weak comparison, returns true if a response was written.
+func checkETagPreconditions(w http.ResponseWriter, r *http.Request, metadata fs.ObjectMetadata) bool {
+	// Entity tag is not known, conditions cannot be evaluated.
+	if metadata.MD5 == "" {
+		return false
+	}
+	etag := "\"" + metadata.MD5 + "\""
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !isETagMatch(ifMatch, etag, false) {
+			writeErrorResponse(w, r, PreconditionFailed, r.URL.Path)
+			return true
+		}
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if isETagMatch(ifNoneMatch, etag, true) {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
 // GetObjectHandler - GET Object
 // ----------
 // This implementation of the GET operation retrieves object. To use GET,
@@ -64,8 +115,8 @@ func (api storageAPI) GetObjectHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if !isSignV4ReqAuthenticated(api.Signature, r) {
-		writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
+	if errCode, ok := isSignV4ReqAuthenticated(api.Signature, r); !ok {
+		writeErrorResponse(w, r, errCode, r.URL.Path)
 		return
 	}
 
@@ -86,6 +137,12 @@ func (api storageAPI) GetObjectHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+
+	// Verify conditional headers.
+	if checkETagPreconditions(w, r, metadata) {
+		return
+	}
+
 	var hrange *httpRange
 	hrange, err = getRequestedRange(r.Header.Get("Range"), metadata.Size)
 	if err != nil {
@@ -122,8 +179,8 @@ func (api storageAPI) HeadObjectHandler(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	if !isSignV4ReqAuthenticated(api.Signature, r) {
-		writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
+	if errCode, ok := isSignV4ReqAuthenticated(api.Signature, r); !ok {
+		writeErrorResponse(w, r, errCode, r.URL.Path)
 		return
 	}
 
@@ -143,6 +200,12 @@ func (api storageAPI) HeadObjectHandler(w http.ResponseWriter, r *http.Request)
 		}
 		return
 	}
+
+	// Verify conditional headers.
+	if checkETagPreconditions(w, r, metadata) {
+		return
+	}
+
 	setObjectHeaders(w, metadata, nil)
 	w.WriteHeader(http.StatusOK)
 }
@@ -199,8 +262,29 @@ func (api storageAPI) PutObjectHandler(w http.ResponseWriter, r *http.Request) {
 		auth = nil
 	}
 
+	// Payloads signed per chunk are verified while they are read, the object is of their decoded size.
+	var body io.Reader = r.Body
+	if auth != nil && auth.IsStreamingPayload() {
+		decodedSize, e := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if e != nil || decodedSize < 0 {
+			writeErrorResponse(w, r, MissingContentLength, r.URL.Path)
+			return
+		}
+		if isMaxObjectSize(decodedSize) {
+			writeErrorResponse(w, r, EntityTooLarge, r.URL.Path)
+			return
+		}
+		chunkReader, err := auth.NewChunkReader(r.Body)
+		if err != nil {
+			errorIf(err.Trace(r.URL.String()), "Streaming signature verification failed.", nil)
+			writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
+			return
+		}
+		body, size, auth = chunkReader, decodedSize, nil
+	}
+
 	// Create object.
-	metadata, err := api.Filesystem.CreateObject(bucket, object, md5, size, r.Body, auth)
+	metadata, err := api.Filesystem.CreateObject(bucket, object, md5, size, body, auth)
 	if err != nil {
 		errorIf(err.Trace(), "CreateObject failed.", nil)
 		switch err.ToGoError().(type) {
@@ -212,8 +296,10 @@ func (api storageAPI) PutObjectHandler(w http.ResponseWriter, r *http.Request) {
 			writeErrorResponse(w, r, InvalidBucketName, r.URL.Path)
 		case fs.BadDigest:
 			writeErrorResponse(w, r, BadDigest, r.URL.Path)
-		case fs.SignDoesNotMatch:
+		case fs.SignDoesNotMatch, signature4.SignatureMismatch:
 			writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
+		case signature4.AuthorizationHeaderMalformed:
+			writeErrorResponse(w, r, AuthorizationHeaderMalformed, r.URL.Path)
 		case fs.IncompleteBody:
 			writeErrorResponse(w, r, IncompleteBody, r.URL.Path)
 		case fs.InvalidDigest:
@@ -247,8 +333,8 @@ func (api storageAPI) NewMultipartUploadHandler(w http.ResponseWriter, r *http.R
 		}
 	}
 
-	if !isSignV4ReqAuthenticated(api.Signature, r) {
-		writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
+	if errCode, ok := isSignV4ReqAuthenticated(api.Signature, r); !ok {
+		writeErrorResponse(w, r, errCode, r.URL.Path)
 		return
 	}
 
@@ -385,8 +471,8 @@ func (api storageAPI) AbortMultipartUploadHandler(w http.ResponseWriter, r *http
 		}
 	}
 
-	if !isSignV4ReqAuthenticated(api.Signature, r) {
-		writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
+	if errCode, ok := isSignV4ReqAuthenticated(api.Signature, r); !ok {
+		writeErrorResponse(w, r, errCode, r.URL.Path)
 		return
 	}
 
@@ -426,8 +512,8 @@ func (api storageAPI) ListObjectPartsHandler(w http.ResponseWriter, r *http.Requ
 		}
 	}
 
-	if !isSignV4ReqAuthenticated(api.Signature, r) {
-		writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
+	if errCode, ok := isSignV4ReqAuthenticated(api.Signature, r); !ok {
+		writeErrorResponse(w, r, errCode, r.URL.Path)
 		return
 	}
 
@@ -505,7 +591,7 @@ func (api storageAPI) CompleteMultipartUploadHandler(w http.ResponseWriter, r *h
 	objectResourcesMetadata := getObjectResources(r.URL.Query())
 
 	// Complete multipart upload.
-	metadata, err := api.Filesystem.CompleteMultipartUpload(bucket, object, objectResourcesMetadata.UploadID, r.Body, api.Signature)
+	metadata, err := api.Filesystem.CompleteMultipartUpload(bucket, object, objectResourcesMetadata.UploadID, r.Body, auth)
 	if err != nil {
 		errorIf(err.Trace(), "CompleteMultipartUpload failed.", nil)
 		switch err.ToGoError().(type) {
@@ -557,8 +643,8 @@ func (api storageAPI) DeleteObjectHandler(w http.ResponseWriter, r *http.Request
 		}
 	}
 
-	if !isSignV4ReqAuthenticated(api.Signature, r) {
-		writeErrorResponse(w, r, SignatureDoesNotMatch, r.URL.Path)
+	if errCode, ok := isSignV4ReqAuthenticated(api.Signature, r); !ok {
+		writeErrorResponse(w, r, errCode, r.URL.Path)
 		return
 	}
 
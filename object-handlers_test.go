@@ -0,0 +1,76 @@
+/*
+ * Minio Cloud Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/minio/minio/pkg/fs"
+	. "gopkg.in/check.v1"
+)
+
+type ObjectHandlersSuite struct{}
+
+var _ = Suite(&ObjectHandlersSuite{})
+
+func (s *ObjectHandlersSuite) TestCheckETagPreconditions(c *C) {
+	metadata := fs.ObjectMetadata{MD5: "b10a8db164e0754105b7a99be72e3fe5"}
+	etag := "\"" + metadata.MD5 + "\""
+
+	testCases := []struct {
+		header     string
+		value      string
+		statusCode int
+	}{
+		// If-None-Match compares weakly.
+		{"If-None-Match", etag, http.StatusNotModified},
+		{"If-None-Match", "W/" + etag, http.StatusNotModified},
+		{"If-None-Match", "\"other\", W/" + etag, http.StatusNotModified},
+		{"If-None-Match", "*", http.StatusNotModified},
+		{"If-None-Match", "\"other\"", http.StatusOK},
+		{"If-None-Match", "W/\"other\"", http.StatusOK},
+		// If-Match compares strongly.
+		{"If-Match", etag, http.StatusOK},
+		{"If-Match", "*", http.StatusOK},
+		{"If-Match", "W/" + etag, http.StatusPreconditionFailed},
+		{"If-Match", "\"other\"", http.StatusPreconditionFailed},
+	}
+	for _, testCase := range testCases {
+		for _, method := range []string{"GET", "HEAD"} {
+			request, err := http.NewRequest(method, "http://localhost:9000/bucket/object", nil)
+			c.Assert(err, IsNil)
+			request.Header.Set(testCase.header, testCase.value)
+
+			w := httptest.NewRecorder()
+			written := checkETagPreconditions(w, request, metadata)
+			comment := Commentf("%s %s: %s", method, testCase.header, testCase.value)
+			if testCase.statusCode == http.StatusOK {
+				c.Assert(written, Equals, false, comment)
+				continue
+			}
+			c.Assert(written, Equals, true, comment)
+			c.Assert(w.Code, Equals, testCase.statusCode, comment)
+		}
+	}
+
+	// Unknown entity tag, conditions are not evaluated.
+	request, err := http.NewRequest("GET", "http://localhost:9000/bucket/object", nil)
+	c.Assert(err, IsNil)
+	request.Header.Set("If-Match", "\"other\"")
+	c.Assert(checkETagPreconditions(httptest.NewRecorder(), request, fs.ObjectMetadata{}), Equals, false)
+}
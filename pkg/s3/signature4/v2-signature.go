@@ -0,0 +1,218 @@
+/*
+ * Minio Cloud Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signature4
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/minio/pkg/probe"
+)
+
+// AWS Signature Version '2' constants.
+const (
+	signV2Algorithm = "AWS"
+)
+
+// resourceList - sub resources which are part of the string to sign for signature version '2'.
+var resourceList = []string{
+	"acl",
+	"delete",
+	"location",
+	"logging",
+	"notification",
+	"partNumber",
+	"policy",
+	"requestPayment",
+	"response-cache-control",
+	"response-content-disposition",
+	"response-content-encoding",
+	"response-content-language",
+	"response-content-type",
+	"response-expires",
+	"torrent",
+	"uploadId",
+	"uploads",
+	"versionId",
+	"versioning",
+	"versions",
+	"website",
+}
+
+// IsSignatureV2 - verify if authorization header is of AWS Signature Version '2' form.
+//
+//   Authorization = "AWS" + " " + AWSAccessKeyId + ":" + Signature
+//
+func IsSignatureV2(authHeader string) bool {
+	return strings.HasPrefix(authHeader, signV2Algorithm+" ")
+}
+
+// parseSignV2 - parse signature version '2' authorization header into access key id and signature.
+func parseSignV2(v2Auth string) (accessKeyID string, signature string, err *probe.Error) {
+	if v2Auth == "" {
+		return "", "", ErrAuthHeaderEmpty("Auth header empty.").Trace()
+	}
+	if !IsSignatureV2(v2Auth) {
+		return "", "", ErrUnsuppSignAlgo("Unsupported algorithm in authorization header.", v2Auth).Trace(v2Auth)
+	}
+	authFields := strings.Split(strings.TrimSpace(strings.TrimPrefix(v2Auth, signV2Algorithm+" ")), ":")
+	if len(authFields) != 2 || authFields[0] == "" || authFields[1] == "" {
+		return "", "", ErrMissingFields("Auth header has missing fields.", v2Auth).Trace(v2Auth)
+	}
+	return authFields[0], authFields[1], nil
+}
+
+// getCanonicalizedAmzHeadersV2 generate lower cased, sorted list of x-amz-* headers
+func (s Sign) getCanonicalizedAmzHeadersV2() string {
+	var headers []string
+	vals := make(http.Header)
+	for k, vv := range s.httpRequest.Header {
+		k = strings.ToLower(k)
+		if !strings.HasPrefix(k, "x-amz-") {
+			continue
+		}
+		headers = append(headers, k)
+		vals[k] = vv
+	}
+	sort.Strings(headers)
+
+	var buf bytes.Buffer
+	for _, k := range headers {
+		buf.WriteString(k)
+		buf.WriteByte(':')
+		for idx, v := range vals[k] {
+			if idx > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(strings.TrimSpace(v))
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// getCanonicalizedResourceV2 generate the request path along with the sub resources it addresses
+func (s Sign) getCanonicalizedResourceV2() string {
+	encodedPath := getURLEncodedName(s.httpRequest.URL.Path)
+	query := s.httpRequest.URL.Query()
+	var subResources []string
+	for _, resource := range resourceList {
+		if _, ok := query[resource]; !ok {
+			continue
+		}
+		if value := query.Get(resource); value != "" {
+			subResources = append(subResources, resource+"="+value)
+			continue
+		}
+		subResources = append(subResources, resource)
+	}
+	if len(subResources) == 0 {
+		return encodedPath
+	}
+	return encodedPath + "?" + strings.Join(subResources, "&")
+}
+
+// getStringToSignV2 generate a string to sign of style
+//
+// stringToSign =
+//  <HTTPMethod>\n
+//  <Content-MD5>\n
+//  <Content-Type>\n
+//  <Date>\n
+//  <CanonicalizedAmzHeaders>
+//  <CanonicalizedResource>
+//
+func (s Sign) getStringToSignV2() string {
+	date := s.httpRequest.Header.Get("Date")
+	// x-amz-date is part of the canonicalized amz headers, date is left empty.
+	if s.httpRequest.Header.Get(http.CanonicalHeaderKey("x-amz-date")) != "" {
+		date = ""
+	}
+	return strings.Join([]string{
+		s.httpRequest.Method,
+		s.httpRequest.Header.Get("Content-MD5"),
+		s.httpRequest.Header.Get("Content-Type"),
+		date,
+		s.getCanonicalizedAmzHeadersV2() + s.getCanonicalizedResourceV2(),
+	}, "\n")
+}
+
+// dateFormatsV2 - formats of the date headers of signature version '2' requests, x-amz-date may
+// also be in iso8601 form
+var dateFormatsV2 = []string{http.TimeFormat, time.RFC1123Z, time.RFC1123, time.RFC850, time.ANSIC, iso8601Format}
+
+// verifyRequestTimeV2 - verify the x-amz-date or else the date header of a signature version '2'
+// request is within the allowed skew of the server time.
+func (s Sign) verifyRequestTimeV2() *probe.Error {
+	date := s.httpRequest.Header.Get(http.CanonicalHeaderKey("x-amz-date"))
+	if date == "" {
+		if date = s.httpRequest.Header.Get("Date"); date == "" {
+			return ErrMissingDateHeader("Date header is missing from the request.").Trace()
+		}
+	}
+	for _, format := range dateFormatsV2 {
+		if t, e := time.Parse(format, date); e == nil {
+			return s.verifyRequestTime(t.UTC())
+		}
+	}
+	return ErrMalformedDate("Malformed date header.", date).Trace(date)
+}
+
+// getSignatureV2 final signature in base64 form.
+func getSignatureV2(secretAccessKey, stringToSign string) string {
+	hash := hmac.New(sha1.New, []byte(secretAccessKey))
+	hash.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(hash.Sum(nil))
+}
+
+// DoesSignatureV2Match - Verify authorization header with calculated header in accordance with
+//     - http://docs.aws.amazon.com/AmazonS3/latest/dev/RESTAuthentication.html
+// returns true if matches, false otherwise. if error is not nil then it is always false
+func (s *Sign) DoesSignatureV2Match() (bool, *probe.Error) {
+	// Save authorization header.
+	v2Auth := s.httpRequest.Header.Get("Authorization")
+
+	// Parse signature version '2' header.
+	accessKeyID, signature, err := parseSignV2(v2Auth)
+	if err != nil {
+		return false, err.Trace(v2Auth)
+	}
+
+	// Lookup the secret access key for the access key id.
+	secretAccessKey, err := s.getSecretAccessKey(accessKeyID)
+	if err != nil {
+		return false, err.Trace(accessKeyID)
+	}
+
+	// Either of date headers is required.
+	if s.httpRequest.Header.Get(http.CanonicalHeaderKey("x-amz-date")) == "" && s.httpRequest.Header.Get("Date") == "" {
+		return false, ErrMissingDateHeader("Date header is missing from the request.").Trace()
+	}
+
+	// Verify if signature match.
+	newSignature := getSignatureV2(secretAccessKey, s.getStringToSignV2())
+	if !hmac.Equal([]byte(newSignature), []byte(signature)) {
+		return false, nil
+	}
+	return true, nil
+}
@@ -18,6 +18,8 @@ package signature4
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/minio/minio/pkg/probe"
 )
@@ -54,4 +56,56 @@ var (
 	ErrInvalidAccessKeyID    = errFactory()
 	ErrInvalidSecretKey      = errFactory()
 	ErrRegionISEmpty         = errFactory()
+	ErrInvalidUnsignedPath   = errFactory()
+
+	ErrMissingHostSignedHeader = errFactory()
 )
+
+// SignatureMismatchCause - cause of a signature mismatch.
+type SignatureMismatchCause string
+
+// Various causes of a signature mismatch.
+const (
+	PayloadHashMismatch     SignatureMismatchCause = "PayloadHashMismatch"
+	SignedHeaderMismatch    SignatureMismatchCause = "SignedHeaderMismatch"
+	CredentialScopeMismatch SignatureMismatchCause = "CredentialScopeMismatch"
+	ChunkSignatureMismatch  SignatureMismatchCause = "ChunkSignatureMismatch"
+	// signature version '2' signs a single string, it is not diagnosed any further
+	SignatureV2Mismatch SignatureMismatchCause = "SignatureV2Mismatch"
+)
+
+// SignatureMismatch - signature does not match, along with its cause and the names
+// of signed headers which failed verification.
+type SignatureMismatch struct {
+	Cause   SignatureMismatchCause
+	Headers []string
+}
+
+func (e SignatureMismatch) Error() string {
+	if len(e.Headers) > 0 {
+		return fmt.Sprintf("Signature does not match: %s, signed headers: %s", e.Cause, strings.Join(e.Headers, ";"))
+	}
+	return "Signature does not match: " + string(e.Cause)
+}
+
+// AuthorizationHeaderMalformed - credential of a request is malformed, such as missing its region.
+type AuthorizationHeaderMalformed struct {
+	Credential string
+	Reason     string
+}
+
+func (e AuthorizationHeaderMalformed) Error() string {
+	return "The authorization header is malformed; " + e.Reason + ": " + e.Credential
+}
+
+// RequestTimeTooSkewed - time of a request differs from the server time by more than allowed.
+type RequestTimeTooSkewed struct {
+	RequestTime    time.Time
+	ServerTime     time.Time
+	MaxAllowedSkew time.Duration
+}
+
+func (e RequestTimeTooSkewed) Error() string {
+	return fmt.Sprintf("The difference between the request time %s and the server time %s is larger than %s",
+		e.RequestTime.Format(time.RFC3339), e.ServerTime.Format(time.RFC3339), e.MaxAllowedSkew)
+}
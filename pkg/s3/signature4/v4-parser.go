@@ -18,6 +18,7 @@ package signature4
 
 import (
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -36,6 +37,25 @@ type credential struct {
 	}
 }
 
+// parseCredentialScopeRegion - region of a credential scope as sent in X-Amz-Credential, of the
+// form "<access-key-id>/<YYYYMMDD>/<region>/s3/aws4_request".
+func parseCredentialScopeRegion(scope string) (string, *probe.Error) {
+	malformed := func(reason string) *probe.Error {
+		return probe.NewError(AuthorizationHeaderMalformed{Credential: scope, Reason: reason}).Trace(scope)
+	}
+	scopeElements := strings.Split(strings.TrimSpace(scope), "/")
+	if len(scopeElements) != 5 || scopeElements[3] != "s3" || scopeElements[4] != "aws4_request" {
+		return "", malformed("the credential scope is malformed")
+	}
+	if _, e := time.Parse(yyyymmdd, scopeElements[1]); e != nil {
+		return "", malformed("the date is not of the form YYYYMMDD")
+	}
+	if scopeElements[2] == "" {
+		return "", malformed("the region is missing")
+	}
+	return scopeElements[2], nil
+}
+
 // parse credential string into its structured form.
 func parseCredential(credElement string) (credential, *probe.Error) {
 	creds := strings.Split(strings.TrimSpace(credElement), "=")
@@ -46,6 +66,10 @@ func parseCredential(credElement string) (credential, *probe.Error) {
 		return credential{}, ErrMissingCredTag("Missing credentials tag.", credElement).Trace(credElement)
 	}
 	credElements := strings.Split(strings.TrimSpace(creds[1]), "/")
+	// a scope of only date, service and request version omits the region altogether
+	if len(credElements) == 4 && credElements[2] == "s3" {
+		return credential{}, probe.NewError(AuthorizationHeaderMalformed{Credential: credElement, Reason: "the region is missing"}).Trace(credElement)
+	}
 	if len(credElements) != 5 {
 		return credential{}, ErrCredMalformed("Credential values malformed.", credElement).Trace(credElement)
 	}
@@ -61,7 +85,7 @@ func parseCredential(credElement string) (credential, *probe.Error) {
 		return credential{}, ErrInvalidDateFormat("Invalid date format.", credElement).Trace(credElement)
 	}
 	if credElements[2] == "" {
-		return credential{}, ErrRegionISEmpty("Region is empty.", credElement).Trace(credElement)
+		return credential{}, probe.NewError(AuthorizationHeaderMalformed{Credential: credElement, Reason: "the region is missing"}).Trace(credElement)
 	}
 	cred.scope.region = credElements[2]
 	if credElements[3] != "s3" {
@@ -147,17 +171,31 @@ func parsePreSignV4(query url.Values) (preSignValues, *probe.Error) {
 		return preSignValues{}, ErrMalformedDate("Malformed date string.", query.Get("X-Amz-Date")).Trace(query.Get("X-Amz-Date"))
 	}
 
-	// Save expires in native time.Duration.
-	preSignV4Values.Expires, e = time.ParseDuration(query.Get("X-Amz-Expires") + "s")
+	// Save expires in native time.Duration, it is a number of seconds up to seven days.
+	expires, e := strconv.ParseInt(query.Get("X-Amz-Expires"), 10, 64)
 	if e != nil {
 		return preSignValues{}, ErrMalformedExpires("Malformed expires string.", query.Get("X-Amz-Expires")).Trace(query.Get("X-Amz-Expires"))
 	}
+	if expires < minPresignExpires || expires > maxPresignExpires {
+		return preSignValues{}, ErrMalformedExpires("Expires must be between 1 and 604800 seconds.", query.Get("X-Amz-Expires")).Trace(query.Get("X-Amz-Expires"))
+	}
+	preSignV4Values.Expires = time.Duration(expires) * time.Second
 
 	// Save signed headers.
 	preSignV4Values.SignedHeaders, err = parseSignedHeaders("SignedHeaders=" + query.Get("X-Amz-SignedHeaders"))
 	if err != nil {
 		return preSignValues{}, err.Trace(query.Get("X-Amz-SignedHeaders"))
 	}
+	// Host header is always signed.
+	var hostSigned bool
+	for _, header := range preSignV4Values.SignedHeaders {
+		if header == "host" {
+			hostSigned = true
+		}
+	}
+	if !hostSigned {
+		return preSignValues{}, ErrMissingHostSignedHeader("Host header is not part of signed headers.", query.Get("X-Amz-SignedHeaders")).Trace(query.Get("X-Amz-SignedHeaders"))
+	}
 
 	// Save signature.
 	preSignV4Values.Signature, err = parseSignature("Signature=" + query.Get("X-Amz-Signature"))
@@ -22,15 +22,17 @@
 // - Based on Authorization header.
 // - Based on Query parameters.
 // - Based on Form POST policy.
+//
+// Legacy AWS Signature Version '2' authorization header is verified
+// as well.
 package signature4
 
 import (
 	"bytes"
 	"encoding/hex"
 	"net/http"
-	"net/url"
+	"path"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 
@@ -45,6 +47,17 @@ type Sign struct {
 	region                 string
 	httpRequest            *http.Request
 	extractedSignedHeaders http.Header
+
+	// memoized per request, on the copy returned by SetHTTPRequestToVerify()
+	signingKey      []byte
+	signingKeyScope string
+	canonicalPrefix string
+
+	// paths allowed without signature verification, see SetUnsignedPaths()
+	unsignedPaths map[string]struct{}
+
+	// request time may differ from the server time at most by this much, see SetMaxClockSkew()
+	maxClockSkew time.Duration
 }
 
 // AWS Signature Version '4' constants.
@@ -52,6 +65,21 @@ const (
 	signV4Algorithm = "AWS4-HMAC-SHA256"
 	iso8601Format   = "20060102T150405Z"
 	yyyymmdd        = "20060102"
+
+	// x-amz-content-sha256 values which are not a hash of the payload
+	unsignedPayload        = "UNSIGNED-PAYLOAD"
+	streamingPayloadPrefix = "STREAMING-"
+
+	// bounds of X-Amz-Expires of a presigned request in seconds, at most seven days
+	minPresignExpires = 1
+	maxPresignExpires = 604800
+
+	// only paths in the server's reserved namespace may skip signature verification, they
+	// never resolve to a bucket or an object
+	reservedPathPrefix = "/minio/"
+
+	// request time may differ from the server time at most by this much by default, like AWS
+	defaultMaxClockSkew = 15 * time.Minute
 )
 
 // New - initialize a new authorization checkes.
@@ -73,17 +101,108 @@ func New(accessKeyID, secretAccessKey, region string) (*Sign, *probe.Error) {
 	return signature, nil
 }
 
-// SetHTTPRequestToVerify - sets the http request which needs to be verified.
+// SetHTTPRequestToVerify - returns a copy of the signature which verifies the given http request,
+// such that requests verified concurrently do not share values derived while verifying them.
 func (s *Sign) SetHTTPRequestToVerify(r *http.Request) *Sign {
 	// Do not set http request if its 'nil'.
 	if r == nil {
 		return s
 	}
-	s.httpRequest = r
+	sign := *s
+	sign.httpRequest = r
+	sign.extractedSignedHeaders = nil
+	sign.signingKey = nil
+	sign.signingKeyScope = ""
+	sign.canonicalPrefix = ""
+	return &sign
+}
+
+// SetUnsignedPaths - set paths such as health checks which skip signature verification, requests
+// to them are authenticated as the system. Paths must be clean and inside the reserved '/minio/'
+// namespace such that no data operation is ever exposed, replaces any previously set paths.
+func (s *Sign) SetUnsignedPaths(paths ...string) *probe.Error {
+	unsignedPaths := make(map[string]struct{})
+	for _, p := range paths {
+		if !strings.HasPrefix(p, reservedPathPrefix) || path.Clean(p) != p {
+			return ErrInvalidUnsignedPath("Unsigned path must be a clean path inside "+reservedPathPrefix, p).Trace(p)
+		}
+		unsignedPaths[p] = struct{}{}
+	}
+	s.unsignedPaths = unsignedPaths
+	return nil
+}
+
+// SetMaxClockSkew - set how much the time of a request may differ from the server time, either way,
+// a skew which is not positive restores the default of 15 minutes.
+func (s *Sign) SetMaxClockSkew(skew time.Duration) *Sign {
+	s.maxClockSkew = skew
 	return s
 }
 
-// getCanonicalHeaders generate a list of request headers with their values
+// getMaxClockSkew - maximum allowed skew between the request time and the server time.
+func (s Sign) getMaxClockSkew() time.Duration {
+	if s.maxClockSkew <= 0 {
+		return defaultMaxClockSkew
+	}
+	return s.maxClockSkew
+}
+
+// verifyRequestTime - verify the request time is within the allowed skew of the server time.
+func (s Sign) verifyRequestTime(t time.Time) *probe.Error {
+	now := time.Now().UTC()
+	if skew := now.Sub(t); skew > s.getMaxClockSkew() || -skew > s.getMaxClockSkew() {
+		return probe.NewError(RequestTimeTooSkewed{RequestTime: t, ServerTime: now, MaxAllowedSkew: s.getMaxClockSkew()})
+	}
+	return nil
+}
+
+// VerifyRequestTime - verify the x-amz-date or else the date header of a signed request is within the
+// allowed skew of the server time. Requests are verified once on arrival, before their payload is
+// read, signature verification does not verify the request time again.
+func (s Sign) VerifyRequestTime() *probe.Error {
+	if s.IsSystemRequest() {
+		return nil
+	}
+	// Legacy clients still sign with signature version '2'.
+	if IsSignatureV2(s.httpRequest.Header.Get("Authorization")) {
+		return s.verifyRequestTimeV2().Trace()
+	}
+	var date string
+	if date = s.httpRequest.Header.Get(http.CanonicalHeaderKey("x-amz-date")); date == "" {
+		if date = s.httpRequest.Header.Get("Date"); date == "" {
+			return ErrMissingDateHeader("Date header is missing from the request.").Trace()
+		}
+	}
+	t, e := time.Parse(iso8601Format, date)
+	if e != nil {
+		return ErrMalformedDate("Malformed date header.", date).Trace(date)
+	}
+	return s.verifyRequestTime(t).Trace()
+}
+
+// IsSystemRequest - Verify if the request is a read of a path allowed without signature
+// verification, such requests are authenticated as the system.
+func (s Sign) IsSystemRequest() bool {
+	if s.httpRequest == nil || s.httpRequest.URL == nil {
+		return false
+	}
+	if s.httpRequest.Method != "GET" && s.httpRequest.Method != "HEAD" {
+		return false
+	}
+	_, ok := s.unsignedPaths[s.httpRequest.URL.Path]
+	return ok
+}
+
+// getSecretAccessKey - lookup secret access key for the incoming access key id.
+func (s Sign) getSecretAccessKey(accessKeyID string) (string, *probe.Error) {
+	if accessKeyID != s.accessKeyID {
+		return "", ErrInvalidAccessKeyID("Access key id does not match with our records.", accessKeyID).Trace(accessKeyID)
+	}
+	return s.secretAccessKey, nil
+}
+
+// getCanonicalHeaders generate a list of request headers with their values, values of
+// duplicate headers are combined with commas and spaces in values are trimmed
 func (s Sign) getCanonicalHeaders(signedHeaders http.Header) string {
 	var headers []string
 	vals := make(http.Header)
@@ -107,7 +226,7 @@ func (s Sign) getCanonicalHeaders(signedHeaders http.Header) string {
 				if idx > 0 {
 					buf.WriteByte(',')
 				}
-				buf.WriteString(v)
+				buf.WriteString(trimAll(v))
 			}
 			buf.WriteByte('\n')
 		}
@@ -126,6 +245,30 @@ func (s Sign) getSignedHeaders(signedHeaders http.Header) string {
 	return strings.Join(headers, ";")
 }
 
+// getCanonicalPrefix generate the request specific prefix of a canonical request, computed
+// only once per request.
+//
+// canonicalPrefix =
+//  <HTTPMethod>\n
+//  <CanonicalURI>\n
+//  <CanonicalQueryString>
+//
+func (s *Sign) getCanonicalPrefix() string {
+	if s.canonicalPrefix != "" {
+		return s.canonicalPrefix
+	}
+	s.httpRequest.URL.RawQuery = getCanonicalQueryString(s.httpRequest.URL.Query())
+	encodedPath := getURLEncodedName(s.httpRequest.URL.Path)
+	// Convert any space strings back to "+".
+	encodedPath = strings.Replace(encodedPath, "+", "%20", -1)
+	s.canonicalPrefix = strings.Join([]string{
+		s.httpRequest.Method,
+		encodedPath,
+		s.httpRequest.URL.RawQuery,
+	}, "\n")
+	return s.canonicalPrefix
+}
+
 // getCanonicalRequest generate a canonical request of style
 //
 // canonicalRequest =
@@ -136,19 +279,12 @@ func (s Sign) getSignedHeaders(signedHeaders http.Header) string {
 //  <SignedHeaders>\n
 //  <HashedPayload>
 //
-func (s *Sign) getCanonicalRequest() string {
-	payload := s.httpRequest.Header.Get(http.CanonicalHeaderKey("x-amz-content-sha256"))
-	s.httpRequest.URL.RawQuery = strings.Replace(s.httpRequest.URL.Query().Encode(), "+", "%20", -1)
-	encodedPath := getURLEncodedName(s.httpRequest.URL.Path)
-	// Convert any space strings back to "+".
-	encodedPath = strings.Replace(encodedPath, "+", "%20", -1)
+func (s *Sign) getCanonicalRequest(hashedPayload string) string {
 	canonicalRequest := strings.Join([]string{
-		s.httpRequest.Method,
-		encodedPath,
-		s.httpRequest.URL.RawQuery,
+		s.getCanonicalPrefix(),
 		s.getCanonicalHeaders(s.extractedSignedHeaders),
 		s.getSignedHeaders(s.extractedSignedHeaders),
-		payload,
+		hashedPayload,
 	}, "\n")
 	return canonicalRequest
 }
@@ -163,18 +299,17 @@ func (s *Sign) getCanonicalRequest() string {
 //  <SignedHeaders>\n
 //  <HashedPayload>
 //
-func (s Sign) getPresignedCanonicalRequest(presignedQuery string) string {
-	rawQuery := strings.Replace(presignedQuery, "+", "%20", -1)
+func (s Sign) getPresignedCanonicalRequest(canonicalQuery string) string {
 	encodedPath := getURLEncodedName(s.httpRequest.URL.Path)
 	// Convert any space strings back to "+".
 	encodedPath = strings.Replace(encodedPath, "+", "%20", -1)
 	canonicalRequest := strings.Join([]string{
 		s.httpRequest.Method,
 		encodedPath,
-		rawQuery,
+		canonicalQuery,
 		s.getCanonicalHeaders(s.extractedSignedHeaders),
 		s.getSignedHeaders(s.extractedSignedHeaders),
-		"UNSIGNED-PAYLOAD",
+		unsignedPayload,
 	}, "\n")
 	return canonicalRequest
 }
@@ -199,12 +334,22 @@ func (s Sign) getStringToSign(canonicalRequest string, t time.Time) string {
 	return stringToSign
 }
 
-// getSigningKey hmac seed to calculate final signature.
-func (s Sign) getSigningKey(t time.Time) []byte {
-	secret := s.secretAccessKey
-	date := sumHMAC([]byte("AWS4"+secret), []byte(t.Format(yyyymmdd)))
-	region := sumHMAC(date, []byte(s.region))
-	service := sumHMAC(region, []byte("s3"))
+// getSigningKey hmac seed to calculate final signature, derived only once per request.
+func (s *Sign) getSigningKey(t time.Time) []byte {
+	scope := s.getScope(t)
+	if s.signingKey != nil && s.signingKeyScope == scope {
+		return s.signingKey
+	}
+	s.signingKey = deriveSigningKey(s.secretAccessKey, s.region, t)
+	s.signingKeyScope = scope
+	return s.signingKey
+}
+
+// deriveSigningKey hmac chain deriving the signing key from secret access key.
+var deriveSigningKey = func(secretAccessKey, region string, t time.Time) []byte {
+	date := sumHMAC([]byte("AWS4"+secretAccessKey), []byte(t.Format(yyyymmdd)))
+	regionKey := sumHMAC(date, []byte(region))
+	service := sumHMAC(regionKey, []byte("s3"))
 	signingKey := sumHMAC(service, []byte("aws4_request"))
 	return signingKey
 }
@@ -214,6 +359,29 @@ func (s Sign) getSignature(signingKey []byte, stringToSign string) string {
 	return hex.EncodeToString(sumHMAC(signingKey, []byte(stringToSign)))
 }
 
+// GetRequestRegion - region of the request from the scope of its credential, sent as X-Amz-Credential
+// by presigned requests and in the Authorization header otherwise. Returns an error if the region
+// does not match the configured region.
+func (s Sign) GetRequestRegion() (string, *probe.Error) {
+	scope := s.httpRequest.URL.Query().Get("X-Amz-Credential")
+	if scope == "" {
+		authFields := strings.Split(strings.TrimPrefix(s.httpRequest.Header.Get("Authorization"), signV4Algorithm), ",")
+		for _, authField := range authFields {
+			if authField = strings.TrimSpace(authField); strings.HasPrefix(authField, "Credential=") {
+				scope = strings.TrimPrefix(authField, "Credential=")
+			}
+		}
+	}
+	reqRegion, err := parseCredentialScopeRegion(scope)
+	if err != nil {
+		return "", err.Trace()
+	}
+	if !isValidRegion(reqRegion, s.region) {
+		return "", ErrInvalidRegion("Requested region is not recognized.", reqRegion).Trace(reqRegion)
+	}
+	return reqRegion, nil
+}
+
 // DoesPolicySignatureMatch - Verify query headers with post policy
 //     - http://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-HTTPPOSTConstructPolicy.html
 // returns true if matches, false otherwise. if error is not nil then it is always false
@@ -255,6 +423,12 @@ func (s *Sign) DoesPolicySignatureMatch(formValues map[string]string) (bool, *pr
 //     - http://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html
 // returns true if matches, false otherwise. if error is not nil then it is always false
 func (s *Sign) DoesPresignedSignatureMatch() (bool, *probe.Error) {
+	// Verify if the region of the credential scope is valid.
+	reqRegion, err := s.GetRequestRegion()
+	if err != nil {
+		return false, err.Trace()
+	}
+
 	// Parse request query string.
 	preSignValues, err := parsePreSignV4(s.httpRequest.URL.Query())
 	if err != nil {
@@ -262,14 +436,8 @@ func (s *Sign) DoesPresignedSignatureMatch() (bool, *probe.Error) {
 	}
 
 	// Verify if the access key id matches.
-	if preSignValues.Credential.accessKeyID != s.accessKeyID {
-		return false, ErrInvalidAccessKeyID("Access key id does not match with our records.", preSignValues.Credential.accessKeyID).Trace(preSignValues.Credential.accessKeyID)
-	}
-
-	// Verify if region is valid.
-	reqRegion := preSignValues.Credential.scope.region
-	if !isValidRegion(reqRegion, s.region) {
-		return false, ErrInvalidRegion("Requested region is not recognized.", reqRegion).Trace(reqRegion)
+	if _, err := s.getSecretAccessKey(preSignValues.Credential.accessKeyID); err != nil {
+		return false, err.Trace(preSignValues.Credential.accessKeyID)
 	}
 
 	// Save region.
@@ -278,114 +446,156 @@ func (s *Sign) DoesPresignedSignatureMatch() (bool, *probe.Error) {
 	// Extract all the signed headers along with its values.
 	s.extractedSignedHeaders = extractSignedHeaders(preSignValues.SignedHeaders, s.httpRequest.Header)
 
-	// Construct new query.
-	query := make(url.Values)
-	query.Set("X-Amz-Algorithm", signV4Algorithm)
-
-	if time.Now().UTC().Sub(preSignValues.Date) > time.Duration(preSignValues.Expires) {
-		return false, ErrExpiredPresignRequest("Presigned request already expired, please initiate a new request.")
-	}
-
-	// Save the date and expires.
+	// Verify if the request is within its expires window.
 	t := preSignValues.Date
-	expireSeconds := int(time.Duration(preSignValues.Expires) / time.Second)
-
-	// Construct the query.
-	query.Set("X-Amz-Date", t.Format(iso8601Format))
-	query.Set("X-Amz-Expires", strconv.Itoa(expireSeconds))
-	query.Set("X-Amz-SignedHeaders", s.getSignedHeaders(s.extractedSignedHeaders))
-	query.Set("X-Amz-Credential", s.accessKeyID+"/"+s.getScope(t))
-
-	// Save other headers available in the request parameters.
-	for k, v := range s.httpRequest.URL.Query() {
-		if strings.HasPrefix(strings.ToLower(k), "x-amz") {
-			continue
-		}
-		query[k] = v
+	if time.Now().UTC().Sub(t) > preSignValues.Expires {
+		return false, ErrExpiredPresignRequest("Presigned request already expired, please initiate a new request.")
 	}
 
-	// Get the encoded query.
-	encodedQuery := query.Encode()
-
-	// Verify if date query is same.
-	if s.httpRequest.URL.Query().Get("X-Amz-Date") != query.Get("X-Amz-Date") {
+	// Verify if credential scope is of the same day as the request.
+	if !preSignValues.Credential.scope.date.Equal(t.Truncate(24 * time.Hour)) {
 		return false, nil
 	}
-	// Verify if expires query is same.
-	if s.httpRequest.URL.Query().Get("X-Amz-Expires") != query.Get("X-Amz-Expires") {
-		return false, nil
-	}
-	// Verify if signed headers query is same.
-	if s.httpRequest.URL.Query().Get("X-Amz-SignedHeaders") != query.Get("X-Amz-SignedHeaders") {
-		return false, nil
-	}
-	// Verify if credential query is same.
-	if s.httpRequest.URL.Query().Get("X-Amz-Credential") != query.Get("X-Amz-Credential") {
+	// Verify if signed headers query is same, it is signed as part of the canonical query.
+	if s.httpRequest.URL.Query().Get("X-Amz-SignedHeaders") != s.getSignedHeaders(s.extractedSignedHeaders) {
 		return false, nil
 	}
+
+	// Canonical query string is of all the query parameters except the signature itself.
+	query := s.httpRequest.URL.Query()
+	query.Del("X-Amz-Signature")
+	canonicalQuery := getCanonicalQueryString(query)
+
 	// Verify finally if signature is same.
-	newSignature := s.getSignature(s.getSigningKey(t), s.getStringToSign(s.getPresignedCanonicalRequest(encodedQuery), t))
-	if s.httpRequest.URL.Query().Get("X-Amz-Signature") != newSignature {
+	newSignature := s.getSignature(s.getSigningKey(t), s.getStringToSign(s.getPresignedCanonicalRequest(canonicalQuery), t))
+	if preSignValues.Signature != newSignature {
 		return false, nil
 	}
 	return true, nil
 }
 
+// DoesPayloadHashMatch - Verify if the payload hash sent in x-amz-content-sha256 matches with the
+// hash of the received payload, unsigned payloads are not verified. Streaming payloads never match,
+// their chunks are verified by reading them through NewChunkReader instead.
+func (s Sign) DoesPayloadHashMatch(hashedPayload string) bool {
+	contentSHA256 := s.httpRequest.Header.Get(http.CanonicalHeaderKey("x-amz-content-sha256"))
+	if contentSHA256 == "" || contentSHA256 == unsignedPayload {
+		return true
+	}
+	if strings.HasPrefix(contentSHA256, streamingPayloadPrefix) {
+		return false
+	}
+	return contentSHA256 == hashedPayload
+}
+
 // DoesSignatureMatch - Verify authorization header with calculated header in accordance with
 //     - http://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-authenticating-requests.html
 // returns true if matches, false otherwise. if error is not nil then it is always false
 func (s *Sign) DoesSignatureMatch(hashedPayload string) (bool, *probe.Error) {
+	if err := s.VerifySignature(hashedPayload); err != nil {
+		if _, ok := err.ToGoError().(SignatureMismatch); ok {
+			return false, nil
+		}
+		return false, err.Trace()
+	}
+	return true, nil
+}
+
+// VerifySignature - Verify authorization header similar to DoesSignatureMatch, on mismatch
+// returns SignatureMismatch error reporting the cause of the failure. Requests to unsigned
+// paths are not verified.
+func (s *Sign) VerifySignature(hashedPayload string) *probe.Error {
+	if s.IsSystemRequest() {
+		return nil
+	}
 	// Save authorization header.
 	v4Auth := s.httpRequest.Header.Get("Authorization")
 
+	// Legacy clients still sign with signature version '2'.
+	if IsSignatureV2(v4Auth) {
+		ok, err := s.DoesSignatureV2Match()
+		if err != nil {
+			return err.Trace()
+		}
+		if !ok {
+			return probe.NewError(SignatureMismatch{Cause: SignatureV2Mismatch})
+		}
+		return nil
+	}
+
+	// Verify if the region of the credential scope is valid.
+	reqRegion, err := s.GetRequestRegion()
+	if err != nil {
+		return err.Trace()
+	}
+
 	// Parse signature version '4' header.
 	signV4Values, err := parseSignV4(v4Auth)
 	if err != nil {
-		return false, err.Trace(v4Auth)
+		return err.Trace(v4Auth)
 	}
 
 	// Extract all the signed headers along with its values.
 	s.extractedSignedHeaders = extractSignedHeaders(signV4Values.SignedHeaders, s.httpRequest.Header)
 
 	// Verify if the access key id matches.
-	if signV4Values.Credential.accessKeyID != s.accessKeyID {
-		return false, ErrInvalidAccessKeyID("Access key id does not match with our records.", signV4Values.Credential.accessKeyID).Trace(signV4Values.Credential.accessKeyID)
-	}
-
-	// Verify if region is valid.
-	reqRegion := signV4Values.Credential.scope.region
-	if !isValidRegion(reqRegion, s.region) {
-		return false, ErrInvalidRegion("Requested region is not recognized.", reqRegion).Trace(reqRegion)
+	if _, err := s.getSecretAccessKey(signV4Values.Credential.accessKeyID); err != nil {
+		return err.Trace(signV4Values.Credential.accessKeyID)
 	}
 
 	// Save region.
 	s.region = reqRegion
 
-	// Set input payload.
-	s.httpRequest.Header.Set("X-Amz-Content-Sha256", hashedPayload)
-
 	// Extract date, if not present throw error.
 	var date string
 	if date = s.httpRequest.Header.Get(http.CanonicalHeaderKey("x-amz-date")); date == "" {
 		if date = s.httpRequest.Header.Get("Date"); date == "" {
-			return false, ErrMissingDateHeader("Date header is missing from the request.").Trace()
+			return ErrMissingDateHeader("Date header is missing from the request.").Trace()
 		}
 	}
 	// Parse date header.
 	t, e := time.Parse(iso8601Format, date)
 	if e != nil {
-		return false, probe.NewError(e)
+		return probe.NewError(e)
 	}
 
 	// Signature version '4'.
-	canonicalRequest := s.getCanonicalRequest()
+	canonicalRequest := s.getCanonicalRequest(hashedPayload)
 	stringToSign := s.getStringToSign(canonicalRequest, t)
 	signingKey := s.getSigningKey(t)
 	newSignature := s.getSignature(signingKey, stringToSign)
 
 	// Verify if signature match.
 	if newSignature != signV4Values.Signature {
-		return false, nil
+		return probe.NewError(s.getSignatureMismatch(signV4Values, hashedPayload, t))
 	}
-	return true, nil
+	return nil
+}
+
+// getSignatureMismatch - diagnose why a signature did not match, only names of signed headers
+// are reported never their values.
+func (s Sign) getSignatureMismatch(signV4Values signValues, hashedPayload string, t time.Time) SignatureMismatch {
+	// Credential scope must be of the same day as the request.
+	if !signV4Values.Credential.scope.date.Equal(t.Truncate(24 * time.Hour)) {
+		return SignatureMismatch{Cause: CredentialScopeMismatch}
+	}
+	// Payload hash sent by the client does not match with the payload received.
+	clientPayload := s.httpRequest.Header.Get(http.CanonicalHeaderKey("x-amz-content-sha256"))
+	if clientPayload != "" && clientPayload != hashedPayload {
+		return SignatureMismatch{Cause: PayloadHashMismatch}
+	}
+	// Signed headers missing in the request.
+	var missingHeaders []string
+	for _, header := range signV4Values.SignedHeaders {
+		if header == "host" {
+			continue
+		}
+		if _, ok := s.extractedSignedHeaders[header]; !ok {
+			missingHeaders = append(missingHeaders, header)
+		}
+	}
+	if len(missingHeaders) > 0 {
+		return SignatureMismatch{Cause: SignedHeaderMismatch, Headers: missingHeaders}
+	}
+	return SignatureMismatch{Cause: SignedHeaderMismatch, Headers: signV4Values.SignedHeaders}
 }
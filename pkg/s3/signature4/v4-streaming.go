@@ -0,0 +1,202 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signature4
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio/pkg/crypto/sha256"
+	"github.com/minio/minio/pkg/probe"
+)
+
+// AWS Signature Version '4' streaming constants.
+const (
+	streamingSignedPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	signV4ChunkAlgorithm   = "AWS4-HMAC-SHA256-PAYLOAD"
+	chunkSignaturePrefix   = "chunk-signature="
+
+	// chunks larger than this are rejected, such that a chunk header can not make us buffer
+	// an arbitrary amount of data
+	maxChunkSize = 16 * 1024 * 1024
+
+	// chunk headers longer than this are rejected, a header of the largest chunk size with its
+	// signature is well below it
+	maxChunkHeaderSize = 4096
+)
+
+// emptySHA256 - hex encoded sha256 sum of an empty payload, part of every chunk string to sign.
+var emptySHA256 = func() string {
+	sum := sha256.Sum256(nil)
+	return hex.EncodeToString(sum[:])
+}()
+
+// errMalformedChunk - chunk header or trailer is not of the aws-chunked form.
+var errMalformedChunk = errors.New("Malformed chunk encoding.")
+
+// IsStreamingPayload - verify if the request payload is sent in aws-chunked encoding with
+// per chunk signatures.
+func (s Sign) IsStreamingPayload() bool {
+	return s.httpRequest.Header.Get(http.CanonicalHeaderKey("x-amz-content-sha256")) == streamingSignedPayload
+}
+
+// NewChunkReader - Verify the seed signature of a request sent with a streaming payload
+//     - http://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
+// and wrap its aws-chunked body into a reader of the decoded payload. Signature of every chunk is
+// verified before any of its bytes are returned, on mismatch Read() returns SignatureMismatch.
+func (s *Sign) NewChunkReader(body io.Reader) (io.Reader, *probe.Error) {
+	if !s.IsStreamingPayload() {
+		return nil, ErrUnsuppSignAlgo("Payload is not signed per chunk.", s.httpRequest.Header.Get(http.CanonicalHeaderKey("x-amz-content-sha256"))).Trace()
+	}
+	// Verify the seed signature, it is the signature of the request headers.
+	if err := s.VerifySignature(streamingSignedPayload); err != nil {
+		return nil, err.Trace()
+	}
+	signV4Values, err := parseSignV4(s.httpRequest.Header.Get("Authorization"))
+	if err != nil {
+		return nil, err.Trace()
+	}
+	date := s.httpRequest.Header.Get(http.CanonicalHeaderKey("x-amz-date"))
+	if date == "" {
+		date = s.httpRequest.Header.Get("Date")
+	}
+	t, e := time.Parse(iso8601Format, date)
+	if e != nil {
+		return nil, probe.NewError(e)
+	}
+	return newChunkReader(body, s.getSigningKey(t), t, s.getScope(t), signV4Values.Signature), nil
+}
+
+// chunkReader - decodes an aws-chunked payload verifying the signature chain of its chunks.
+type chunkReader struct {
+	reader        *bufio.Reader
+	signingKey    []byte
+	date          time.Time
+	scope         string
+	prevSignature string
+
+	// verified bytes of the current chunk not yet read
+	chunk []byte
+	err   error
+}
+
+// newChunkReader - signature of the first chunk is chained from the seed signature.
+func newChunkReader(body io.Reader, signingKey []byte, t time.Time, scope, seedSignature string) *chunkReader {
+	return &chunkReader{
+		reader:        bufio.NewReaderSize(body, maxChunkHeaderSize),
+		signingKey:    signingKey,
+		date:          t,
+		scope:         scope,
+		prevSignature: seedSignature,
+	}
+}
+
+// getChunkSignature - signature of a chunk of style
+//
+// stringToSign =
+//  AWS4-HMAC-SHA256-PAYLOAD\n
+//  <Date>\n
+//  <Scope>\n
+//  <PreviousSignature>\n
+//  <EmptySHA256>\n
+//  <ChunkSHA256>
+//
+func (c *chunkReader) getChunkSignature(chunk []byte) string {
+	chunkSum := sha256.Sum256(chunk)
+	stringToSign := strings.Join([]string{
+		signV4ChunkAlgorithm,
+		c.date.Format(iso8601Format),
+		c.scope,
+		c.prevSignature,
+		emptySHA256,
+		hex.EncodeToString(chunkSum[:]),
+	}, "\n")
+	return hex.EncodeToString(sumHMAC(c.signingKey, []byte(stringToSign)))
+}
+
+// readChunk - read and verify the next chunk of style
+//
+//  <hex size>;chunk-signature=<signature>\r\n<data>\r\n
+//
+// the final chunk is of size zero, io.EOF is returned once it is verified.
+func (c *chunkReader) readChunk() error {
+	// headers are read up to the size of the read buffer at most
+	line, e := c.reader.ReadSlice('\n')
+	if e != nil {
+		if e == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if e == bufio.ErrBufferFull {
+			return errMalformedChunk
+		}
+		return e
+	}
+	header := string(line)
+	if !strings.HasSuffix(header, "\r\n") {
+		return errMalformedChunk
+	}
+	fields := strings.SplitN(strings.TrimSuffix(header, "\r\n"), ";", 2)
+	if len(fields) != 2 || !strings.HasPrefix(fields[1], chunkSignaturePrefix) {
+		return errMalformedChunk
+	}
+	size, e := strconv.ParseInt(fields[0], 16, 64)
+	if e != nil || size < 0 || size > maxChunkSize {
+		return errMalformedChunk
+	}
+	chunk := make([]byte, size+2)
+	if _, e := io.ReadFull(c.reader, chunk); e != nil {
+		if e == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return e
+	}
+	if !bytes.HasSuffix(chunk, []byte("\r\n")) {
+		return errMalformedChunk
+	}
+	chunk = chunk[:size]
+
+	newSignature := c.getChunkSignature(chunk)
+	if newSignature != strings.TrimPrefix(fields[1], chunkSignaturePrefix) {
+		return SignatureMismatch{Cause: ChunkSignatureMismatch}
+	}
+	c.prevSignature = newSignature
+	if size == 0 {
+		return io.EOF
+	}
+	c.chunk = chunk
+	return nil
+}
+
+// Read - read decoded payload, only bytes of chunks whose signature matched are returned.
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.chunk) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		c.err = c.readChunk()
+	}
+	n := copy(p, c.chunk)
+	c.chunk = c.chunk[n:]
+	return n, nil
+}
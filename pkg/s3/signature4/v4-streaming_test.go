@@ -0,0 +1,153 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signature4
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// signChunks encode chunks in aws-chunked form, signatures are chained from the seed signature
+func signChunks(chunks [][]byte, seedSignature string, t time.Time) []byte {
+	scope := strings.Join([]string{t.Format(yyyymmdd), testRegion, "s3", "aws4_request"}, "/")
+	signingKey := hmacSHA256([]byte("AWS4"+testSecretAccessKey), t.Format(yyyymmdd))
+	signingKey = hmacSHA256(signingKey, testRegion)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	emptySum := sha256.Sum256(nil)
+
+	var buf bytes.Buffer
+	signature := seedSignature
+	for _, chunk := range append(chunks, nil) {
+		chunkSum := sha256.Sum256(chunk)
+		stringToSign := "AWS4-HMAC-SHA256-PAYLOAD\n" + t.Format(iso8601Format) + "\n" + scope + "\n" + signature + "\n" +
+			hex.EncodeToString(emptySum[:]) + "\n" + hex.EncodeToString(chunkSum[:])
+		signature = hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+		fmt.Fprintf(&buf, "%x;chunk-signature=%s\r\n", len(chunk), signature)
+		buf.Write(chunk)
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes()
+}
+
+// newStreamingRequest create a new request signed with signature version '4' for a streaming payload
+func newStreamingRequest(c *C, chunks [][]byte) *http.Request {
+	t := time.Now().UTC()
+	req, e := http.NewRequest("PUT", "http://localhost:9000/bucket/object", nil)
+	c.Assert(e, IsNil)
+	req.Header.Set("Content-Encoding", "aws-chunked")
+	signV4Request(req, streamingSignedPayload, t)
+	seedSignature := req.Header.Get("Authorization")
+	seedSignature = seedSignature[strings.LastIndex(seedSignature, "=")+1:]
+	req.Body = ioutil.NopCloser(bytes.NewReader(signChunks(chunks, seedSignature, t)))
+	return req
+}
+
+func (s *MySuite) TestChunkReaderExample(c *C) {
+	// example from http://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
+	t, e := time.Parse(iso8601Format, "20130524T000000Z")
+	c.Assert(e, IsNil)
+	sign, err := New(testAccessKeyID, testSecretAccessKey, testRegion)
+	c.Assert(err, IsNil)
+
+	var body bytes.Buffer
+	body.WriteString("10000;chunk-signature=ad80c730a21e5b8d04586a2213dd63b9a0e99e0e2307b0ade35a65485a288648\r\n")
+	body.Write(bytes.Repeat([]byte("a"), 65536))
+	body.WriteString("\r\n400;chunk-signature=0055627c9e194cb4542bae2aa5492e3c1575bbb81b612b7d234b86a503ef5497\r\n")
+	body.Write(bytes.Repeat([]byte("a"), 1024))
+	body.WriteString("\r\n0;chunk-signature=b6c6ea8a5354eaf15b3cb7646744f4275b71ea724fed81ceb9323e279d449df9\r\n\r\n")
+
+	reader := newChunkReader(&body, sign.getSigningKey(t), t, sign.getScope(t), "4f232c4386841ef735655705268965c44a0e4690baa4adea153f7db9fa80a0a9")
+	data, e := ioutil.ReadAll(reader)
+	c.Assert(e, IsNil)
+	c.Assert(data, DeepEquals, bytes.Repeat([]byte("a"), 66560))
+}
+
+func (s *MySuite) TestChunkReader(c *C) {
+	sign, err := New(testAccessKeyID, testSecretAccessKey, testRegion)
+	c.Assert(err, IsNil)
+
+	chunks := [][]byte{[]byte("Hello "), []byte("Streaming "), []byte("World")}
+	req := newStreamingRequest(c, chunks)
+	c.Assert(sign.SetHTTPRequestToVerify(req).IsStreamingPayload(), Equals, true)
+	reader, err := sign.SetHTTPRequestToVerify(req).NewChunkReader(req.Body)
+	c.Assert(err, IsNil)
+	data, e := ioutil.ReadAll(reader)
+	c.Assert(e, IsNil)
+	c.Assert(string(data), Equals, "Hello Streaming World")
+
+	// payload not signed per chunk.
+	req = newTestRequest(c, "PUT", "http://localhost:9000/bucket/object", unsignedPayload)
+	_, err = sign.SetHTTPRequestToVerify(req).NewChunkReader(req.Body)
+	c.Assert(err, Not(IsNil))
+
+	// tampered seed signature.
+	req = newStreamingRequest(c, chunks)
+	req.Header.Set("Authorization", strings.Replace(req.Header.Get("Authorization"), "Signature=", "Signature=0", 1))
+	_, err = sign.SetHTTPRequestToVerify(req).NewChunkReader(req.Body)
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *MySuite) TestChunkReaderTampered(c *C) {
+	sign, err := New(testAccessKeyID, testSecretAccessKey, testRegion)
+	c.Assert(err, IsNil)
+
+	// tampered data of the second chunk, the first chunk is still returned.
+	req := newStreamingRequest(c, [][]byte{[]byte("Hello "), []byte("World")})
+	body, e := ioutil.ReadAll(req.Body)
+	c.Assert(e, IsNil)
+	req.Body = ioutil.NopCloser(bytes.NewReader(bytes.Replace(body, []byte("World"), []byte("Wurld"), 1)))
+	reader, err := sign.SetHTTPRequestToVerify(req).NewChunkReader(req.Body)
+	c.Assert(err, IsNil)
+	data, e := ioutil.ReadAll(reader)
+	c.Assert(string(data), Equals, "Hello ")
+	mismatch, ok := e.(SignatureMismatch)
+	c.Assert(ok, Equals, true)
+	c.Assert(mismatch.Cause, Equals, ChunkSignatureMismatch)
+
+	// truncated payload, the final chunk is missing.
+	req = newStreamingRequest(c, [][]byte{[]byte("Hello "), []byte("World")})
+	body, e = ioutil.ReadAll(req.Body)
+	c.Assert(e, IsNil)
+	reader, err = sign.SetHTTPRequestToVerify(req).NewChunkReader(bytes.NewReader(body[:bytes.LastIndex(body, []byte("0;"))]))
+	c.Assert(err, IsNil)
+	_, e = ioutil.ReadAll(reader)
+	c.Assert(e, Equals, io.ErrUnexpectedEOF)
+
+	// malformed chunk header.
+	req = newStreamingRequest(c, [][]byte{[]byte("Hello")})
+	reader, err = sign.SetHTTPRequestToVerify(req).NewChunkReader(strings.NewReader("zz;chunk-signature=00\r\nHello\r\n"))
+	c.Assert(err, IsNil)
+	_, e = ioutil.ReadAll(reader)
+	c.Assert(e, Equals, errMalformedChunk)
+
+	// chunk header without an end is not read beyond its limit.
+	req = newStreamingRequest(c, [][]byte{[]byte("Hello")})
+	reader, err = sign.SetHTTPRequestToVerify(req).NewChunkReader(strings.NewReader("5;chunk-signature=" + strings.Repeat("0", 2*maxChunkHeaderSize)))
+	c.Assert(err, IsNil)
+	_, e = ioutil.ReadAll(reader)
+	c.Assert(e, Equals, errMalformedChunk)
+}
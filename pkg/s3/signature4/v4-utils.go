@@ -20,7 +20,9 @@ import (
 	"crypto/hmac"
 	"encoding/hex"
 	"net/http"
+	"net/url"
 	"regexp"
+	"sort"
 	"strings"
 	"unicode/utf8"
 
@@ -36,7 +38,11 @@ var isValidSecretKey = regexp.MustCompile("^.{40}$")
 var isValidAccessKey = regexp.MustCompile("^[A-Z0-9\\-\\.\\_\\~]{20}$")
 
 // isValidRegion - verify if incoming region value is valid with configured Region.
+// A missing incoming region never matches, not even the default region.
 func isValidRegion(reqRegion string, confRegion string) bool {
+	if reqRegion == "" {
+		return false
+	}
 	if confRegion == "" || confRegion == "US" {
 		confRegion = "us-east-1"
 	}
@@ -94,6 +100,58 @@ func getURLEncodedName(name string) string {
 	return encodedName
 }
 
+// getCanonicalQueryString canonicalize query parameters as required by signature version '4', keys
+// and values are URI encoded, sorted by key and then by value. Repeated keys are kept, keys without
+// a value are followed by an empty value.
+func getCanonicalQueryString(query url.Values) string {
+	var params queryParams
+	for key, values := range query {
+		encodedKey := getURLEncodedQueryName(key)
+		for _, value := range values {
+			params = append(params, queryParam{key: encodedKey, value: getURLEncodedQueryName(value)})
+		}
+	}
+	sort.Sort(params)
+	encodedParams := make([]string, len(params))
+	for i, param := range params {
+		encodedParams[i] = param.key + "=" + param.value
+	}
+	return strings.Join(encodedParams, "&")
+}
+
+// getURLEncodedQueryName encode a query key or value, unlike in paths '/' is encoded.
+func getURLEncodedQueryName(name string) string {
+	return strings.Replace(getURLEncodedName(name), "/", "%2F", -1)
+}
+
+// queryParam an encoded query parameter.
+type queryParam struct {
+	key   string
+	value string
+}
+
+// queryParams encoded query parameters, sortable by key and then by value.
+type queryParams []queryParam
+
+func (p queryParams) Len() int      { return len(p) }
+func (p queryParams) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p queryParams) Less(i, j int) bool {
+	if p[i].key != p[j].key {
+		return p[i].key < p[j].key
+	}
+	return p[i].value < p[j].value
+}
+
+// trimAll trim leading and trailing spaces of a header value and replace sequential spaces
+// with a single space, as required for canonical header values.
+func trimAll(value string) string {
+	value = strings.Trim(value, " ")
+	for strings.Contains(value, "  ") {
+		value = strings.Replace(value, "  ", " ", -1)
+	}
+	return value
+}
+
 // extractSignedHeaders extract signed headers from Authorization header
 func extractSignedHeaders(signedHeaders []string, reqHeaders http.Header) http.Header {
 	extractedSignedHeaders := make(http.Header)
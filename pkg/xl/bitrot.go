@@ -0,0 +1,163 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/minio/minio/pkg/probe"
+)
+
+// sliceHashesConfig - file beside the data slice on every disk, holding the binary sha256 of each
+// encoded block of the slice in chunk order
+const sliceHashesConfig = "sliceHashes"
+
+// sliceHashes - sha256 of every encoded block of an object as written to every slice, chunks are
+// added in order as they are written
+type sliceHashes struct {
+	chunks [][][sha256.Size]byte
+
+	// hex encoded hashes by chunk and disk order, loaded once first looked up
+	once    sync.Once
+	load    func() [][]string
+	encoded [][]string
+}
+
+// addChunk - add the encoded blocks of the next chunk in disk order, no-op on nil hashes
+func (s *sliceHashes) addChunk(encodedBlocks [][]byte) {
+	if s == nil {
+		return
+	}
+	hashes := make([][sha256.Size]byte, len(encodedBlocks))
+	for order, block := range encodedBlocks {
+		hashes[order] = sha256.Sum256(block)
+	}
+	s.chunks = append(s.chunks, hashes)
+}
+
+// getChunk - hex encoded hashes of a chunk in disk order, nil if unknown
+func (s *sliceHashes) getChunk(chunk int) []string {
+	if s == nil {
+		return nil
+	}
+	s.once.Do(func() {
+		if s.load != nil {
+			s.encoded = s.load()
+			return
+		}
+		s.encoded = make([][]string, len(s.chunks))
+		for i, hashes := range s.chunks {
+			s.encoded[i] = make([]string, len(hashes))
+			for order, hash := range hashes {
+				s.encoded[i][order] = hex.EncodeToString(hash[:])
+			}
+		}
+	})
+	if chunk < 0 || chunk >= len(s.encoded) {
+		return nil
+	}
+	return s.encoded[chunk]
+}
+
+// writeTo - write the hashes of every slice to its own writer, writers are in disk order
+func (s *sliceHashes) writeTo(writers []io.WriteCloser) *probe.Error {
+	for order, writer := range writers {
+		for _, hashes := range s.chunks {
+			if order >= len(hashes) {
+				return probe.NewError(InvalidArgument{})
+			}
+			if _, e := writer.Write(hashes[order][:]); e != nil {
+				return probe.NewError(e)
+			}
+		}
+	}
+	return nil
+}
+
+// hashSlice - hex encoded sha256 of an encoded block
+func hashSlice(block []byte) string {
+	sum := sha256.Sum256(block)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeSliceHashes - stage the slice hashes of an object beside its data slices on all disks,
+// returns the writers to commit them with
+func (b bucket) writeSliceHashes(objectName string, hashes *sliceHashes) ([]io.WriteCloser, *probe.Error) {
+	writers, err := b.getObjectWriters(objectName, sliceHashesConfig)
+	if err != nil {
+		return nil, err.Trace()
+	}
+	if err := hashes.writeTo(writers); err != nil {
+		CleanupWritersOnError(writers)
+		return nil, err.Trace()
+	}
+	return writers, nil
+}
+
+// loadSliceHashes - slice hashes of an object read from beside its data slices, by chunk and disk
+// order. Hashes unreadable on a disk are left empty, such that its slice is not trusted
+func (b bucket) loadSliceHashes(objectName string, chunkCount, totalShards int) [][]string {
+	chunks := make([][]string, chunkCount)
+	for chunk := range chunks {
+		chunks[chunk] = make([]string, totalShards)
+	}
+	readers, err := b.getObjectReaders(objectName, sliceHashesConfig)
+	if err != nil {
+		return chunks
+	}
+	for order, reader := range readers {
+		data, e := ioutil.ReadAll(reader)
+		reader.Close()
+		if e != nil || order >= totalShards {
+			continue
+		}
+		for chunk := range chunks {
+			if (chunk+1)*sha256.Size > len(data) {
+				break
+			}
+			chunks[chunk][order] = hex.EncodeToString(data[chunk*sha256.Size : (chunk+1)*sha256.Size])
+		}
+	}
+	return chunks
+}
+
+// newSliceHashLoader - slice hashes of an object read from beside its data slices once first
+// looked up, such that reading object metadata alone never reads them
+func (b bucket) newSliceHashLoader(objectName string, objMetadata ObjectMetadata) *sliceHashes {
+	return &sliceHashes{
+		load: func() [][]string {
+			return b.loadSliceHashes(objectName, objMetadata.ChunkCount, int(objMetadata.DataDisks)+int(objMetadata.ParityDisks))
+		},
+	}
+}
+
+// getSliceHashes - hashes of the encoded blocks of a chunk in disk order, nil for objects written
+// without slice hashes
+func (o ObjectMetadata) getSliceHashes(chunk int) []string {
+	// objects written before slice hashes were kept beside the data slices
+	if len(o.SliceHashes) > 0 {
+		if chunk < 0 || chunk >= len(o.SliceHashes) {
+			return nil
+		}
+		return o.SliceHashes[chunk]
+	}
+	return o.sliceHashes.getChunk(chunk)
+}
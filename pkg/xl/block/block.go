@@ -180,6 +180,34 @@ func (d Block) Open(filename string) (*os.File, *probe.Error) {
 	return dataFile, nil
 }
 
+// Rename - rename a file or directory inside disk root path
+func (d Block) Rename(oldname, newname string) *probe.Error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if oldname == "" || newname == "" {
+		return probe.NewError(ErrInvalidArgument)
+	}
+	if err := os.Rename(filepath.Join(d.path, oldname), filepath.Join(d.path, newname)); err != nil {
+		return probe.NewError(err)
+	}
+	return nil
+}
+
+// RemoveAll - remove a file or directory and all its contents inside disk root path
+func (d Block) RemoveAll(name string) *probe.Error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if name == "" {
+		return probe.NewError(ErrInvalidArgument)
+	}
+	if err := os.RemoveAll(filepath.Join(d.path, name)); err != nil {
+		return probe.NewError(err)
+	}
+	return nil
+}
+
 // OpenFile - Use with caution
 func (d Block) OpenFile(filename string, flags int, perm os.FileMode) (*os.File, *probe.Error) {
 	d.lock.Lock()
@@ -81,3 +81,19 @@ func (s *MyDiskSuite) TestDiskOpen(c *C) {
 	c.Assert(f2.Name(), Equals, filepath.Join(s.path, "hello2"))
 	defer f2.Close()
 }
+
+func (s *MyDiskSuite) TestDiskRenameAndRemove(c *C) {
+	f, err := s.d.CreateFile("hello3/file")
+	c.Assert(err, IsNil)
+	f.Close()
+
+	c.Assert(s.d.Rename("hello3", "hello4"), IsNil)
+	_, err = s.d.Open("hello3/file")
+	c.Assert(err, Not(IsNil))
+	_, err = s.d.Open("hello4/file")
+	c.Assert(err, IsNil)
+
+	c.Assert(s.d.RemoveAll("hello4"), IsNil)
+	_, err = s.d.Open("hello4/file")
+	c.Assert(err, Not(IsNil))
+}
@@ -17,10 +17,19 @@
 package xl
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
 	"fmt"
 	"hash"
+	"hash/crc32"
 	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -28,9 +37,11 @@ import (
 	"time"
 
 	"crypto/md5"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 
+	"github.com/minio/minio/pkg/atomic"
 	"github.com/minio/minio/pkg/crypto/sha256"
 	"github.com/minio/minio/pkg/crypto/sha512"
 	"github.com/minio/minio/pkg/probe"
@@ -39,33 +50,123 @@ import (
 )
 
 const (
+	// chunks objects are erasure coded in are of this size, unless configured otherwise
 	blockSize = 10 * 1024 * 1024
+
+	// block sizes a bucket can be configured with
+	minBlockSize = 64 * 1024
+	maxBlockSize = 1024 * 1024 * 1024
+
+	// placement of shards on nodes kept with a bucket, sorted by node name or in map order
+	placementDeterministic = "deterministic"
+	placementUnordered     = "unordered"
+
+	// objects up to this size are replicated instead of erasure coded
+	defaultSmallObjectSize = 4 * 1024
+
+	// maximum number of objects returned in a single list
+	maxObjectList = 1000
+
+	// compression codecs for data at rest
+	compressionGzip = "gzip"
+	compressionZlib = "zlib"
+
+	// encoded blocks written to a disk are batched up to this size, if enabled
+	shardBatchSize = 4 * 1024 * 1024
+
+	// digests used as ETag
+	etagMD5    = "md5"
+	etagSHA256 = "sha256"
+
+	// bucket metadata writes failing on a disk are retried with exponential backoff
+	defaultMetadataWriteRetries = 3
+	metadataWriteBackoff        = 10 * time.Millisecond
+
+	// writes queued per hasher when hashing in parallel
+	parallelHashDepth = 8
+
+	// streams of unknown size start with this block size, doubled every chunk up to the block size
+	minAdaptiveBlockSize = 64 * 1024
+
+	// decoded chunks are gathered up to this size into a single vectored write
+	vectoredWriteSize = blockSize
 )
 
 // internal struct carrying bucket specific information
 type bucket struct {
-	name   string
-	acl    string
-	time   time.Time
-	xlName string
-	nodes  map[string]node
-	lock   *sync.Mutex
+	name             string
+	acl              string
+	time             time.Time
+	xlName           string
+	nodes            map[string]node
+	smallObjectSize  int64
+	preProvisionDirs bool
+	compression      string
+	batchShardWrites bool
+	etagAlgorithm    string
+	metadataRetries  int
+	parallelHashing  bool
+	readTimeout      time.Duration
+	treeHash         bool
+	notFoundTTL      time.Duration
+	notFoundSize     int
+	reservedPrefixes []string
+	shardReadLimit   int
+	trustSHA512      bool
+	deterministic    bool
+	readParallelism  int
+	excludedDisks    map[int]struct{}
+	parityVerifyRate float64
+	blockSize        int64
+	stats            *readStats
+	objectLocks      *objectLocker
+	// set once all objects are stored under their encoded name, legacy names are no longer looked up
+	namesEncoded *namesEncoded
+	lock         *sync.RWMutex
 }
 
 // newBucket - instantiate a new bucket
-func newBucket(bucketName, aclType, xlName string, nodes map[string]node) (bucket, BucketMetadata, *probe.Error) {
-	if strings.TrimSpace(bucketName) == "" || strings.TrimSpace(xlName) == "" {
+func newBucket(bucketName, aclType string, config *Config, nodes map[string]node, stats *readStats) (bucket, BucketMetadata, *probe.Error) {
+	if strings.TrimSpace(bucketName) == "" || strings.TrimSpace(config.XLName) == "" {
 		return bucket{}, BucketMetadata{}, probe.NewError(InvalidArgument{})
 	}
+	if err := checkConfig(config); err != nil {
+		return bucket{}, BucketMetadata{}, err.Trace()
+	}
 
 	b := bucket{}
 	t := time.Now().UTC()
 	b.name = bucketName
 	b.acl = aclType
 	b.time = t
-	b.xlName = xlName
+	b.xlName = config.XLName
 	b.nodes = nodes
-	b.lock = new(sync.Mutex)
+	b.stats = stats
+	b.preProvisionDirs = config.PreProvisionDirs
+	b.compression = config.Compression
+	b.batchShardWrites = config.BatchShardWrites
+	b.smallObjectSize = config.SmallObjectSize
+	if b.smallObjectSize == 0 {
+		b.smallObjectSize = defaultSmallObjectSize
+	}
+	b.metadataRetries = getMetadataWriteRetries(config)
+	b.parallelHashing = config.ParallelHashing
+	b.readTimeout = config.DiskReadTimeout
+	b.treeHash = config.TreeHash
+	b.notFoundTTL = config.NotFoundCacheTTL
+	b.notFoundSize = config.NotFoundCacheSize
+	if b.notFoundSize <= 0 {
+		b.notFoundSize = defaultNotFoundCacheSize
+	}
+	b.reservedPrefixes = config.ReservedObjectPrefixes
+	b.shardReadLimit = config.ShardReadsPerDisk
+	b.trustSHA512 = config.TrustSuppliedSHA512
+	b.deterministic = config.DeterministicPlacement
+	b.parityVerifyRate = config.ParityVerifyRate
+	b.blockSize = config.BlockSize
+	b.lock = new(sync.RWMutex)
+	b.objectLocks = newObjectLocker()
+	b.namesEncoded = &namesEncoded{lock: new(sync.RWMutex)}
 
 	metadata := BucketMetadata{}
 	metadata.Version = bucketMetadataVersion
@@ -74,26 +175,88 @@ func newBucket(bucketName, aclType, xlName string, nodes map[string]node) (bucke
 	metadata.Created = t
 	metadata.Metadata = make(map[string]string)
 	metadata.BucketObjects = make(map[string]struct{})
+	metadata.NamesEncoded = true
+	// settings taken from the config are kept with the bucket, such that changing the config only
+	// affects buckets made afterwards
+	metadata.BlockSize = b.getBlockSize()
+	metadata.Placement = placementUnordered
+	if b.deterministic {
+		metadata.Placement = placementDeterministic
+	}
 
 	return b, metadata, nil
 }
 
+// withBucketSettings - the bucket with the settings kept in its metadata, buckets made before a
+// setting was kept take it from the config
+func (b bucket) withBucketSettings(metadata BucketMetadata) bucket {
+	if metadata.BlockSize > 0 {
+		b.blockSize = metadata.BlockSize
+	}
+	switch metadata.Placement {
+	case placementDeterministic:
+		b.deterministic = true
+	case placementUnordered:
+		b.deterministic = false
+	}
+	return b
+}
+
+// isValidBlockSize - verify block size is within the bounds objects can be erasure coded in
+func isValidBlockSize(size int64) bool {
+	return size >= minBlockSize && size <= maxBlockSize
+}
+
 // getBucketName -
 func (b bucket) getBucketName() string {
 	return b.name
 }
 
-// getBucketMetadataReaders -
-func (b bucket) getBucketMetadataReaders() (map[int]io.ReadCloser, *probe.Error) {
-	readers := make(map[int]io.ReadCloser)
-	var disks map[int]block.Block
-	var err *probe.Error
-	for _, node := range b.nodes {
-		disks, err = node.ListDisks()
+// getBlockSize - size of the chunks new objects are erasure coded in
+func (b bucket) getBlockSize() int64 {
+	if b.blockSize <= 0 {
+		return blockSize
+	}
+	return b.blockSize
+}
+
+// getNodes - nodes of the bucket, shards are placed on their disks in this order. Sorted by node
+// name in deterministic placement mode, in map order otherwise
+func (b bucket) getNodes() []node {
+	var names []string
+	for name := range b.nodes {
+		names = append(names, name)
+	}
+	if b.deterministic {
+		sort.Strings(names)
+	}
+	nodes := make([]node, 0, len(names))
+	for _, name := range names {
+		nodes = append(nodes, b.nodes[name])
+	}
+	return nodes
+}
+
+// getDisks - disks of all nodes of the bucket by their order
+func (b bucket) getDisks() (map[int]block.Block, *probe.Error) {
+	disks := make(map[int]block.Block)
+	for _, node := range b.getNodes() {
+		nDisks, err := node.ListDisks()
 		if err != nil {
 			return nil, err.Trace()
 		}
+		for k, v := range nDisks {
+			disks[k] = v
+		}
 	}
+	return disks, nil
+}
+
+// getBucketMetadataReaders - readers of the bucket metadata on disks, disks failing to open it are
+// left out. Fails only if no disk could open it
+func (b bucket) getBucketMetadataReaders(disks map[int]block.Block) (map[int]io.ReadCloser, *probe.Error) {
+	readers := make(map[int]io.ReadCloser)
+	var err *probe.Error
 	var bucketMetaDataReader io.ReadCloser
 	for order, disk := range disks {
 		bucketMetaDataReader, err = disk.Open(filepath.Join(b.xlName, bucketMetadataConfig))
@@ -102,64 +265,537 @@ func (b bucket) getBucketMetadataReaders() (map[int]io.ReadCloser, *probe.Error)
 		}
 		readers[order] = bucketMetaDataReader
 	}
-	if err != nil {
+	if len(readers) == 0 && err != nil {
 		return nil, err.Trace()
 	}
 	return readers, nil
 }
 
-// getBucketMetadata -
-func (b bucket) getBucketMetadata() (*AllBuckets, *probe.Error) {
-	metadata := new(AllBuckets)
-	var readers map[int]io.ReadCloser
-	{
+// saveBucketMetadata - write bucket metadata to a temporary file on every disk, renamed into place on
+// all disks once written on every disk. Callers updating metadata read with getBucketMetadata hold
+// the metadata lock from the read on
+func (b bucket) saveBucketMetadata(metadata *AllBuckets) *probe.Error {
+	disks, err := b.getDisks()
+	if err != nil {
+		return err.Trace()
+	}
+	return writeBucketMetadata(disks, filepath.Join(b.xlName, bucketMetadataConfig), metadata, b.metadataRetries)
+}
+
+// getMetadataWriteRetries - number of retries for bucket metadata writes failing on a disk
+func getMetadataWriteRetries(config *Config) int {
+	if config.MetadataWriteRetries == 0 {
+		return defaultMetadataWriteRetries
+	}
+	if config.MetadataWriteRetries < 0 {
+		return 0
+	}
+	return config.MetadataWriteRetries
+}
+
+// createBucketMetadataFile - create bucket metadata file on disk, replaced in tests to inject failures
+var createBucketMetadataFile = func(disk block.Block, filename string) (*atomic.File, *probe.Error) {
+	return disk.CreateFile(filename)
+}
+
+// writeBucketMetadata - write bucket metadata on all disks, a disk failing the write is retried
+// up to retries times with backoff. Each attempt writes to a new temporary file which is purged on
+// failure, metadata is committed on all disks only once it is written on every disk
+func writeBucketMetadata(disks map[int]block.Block, filename string, metadata *AllBuckets, retries int) *probe.Error {
+	var writers []io.WriteCloser
+	for _, disk := range disks {
+		var writer *atomic.File
 		var err *probe.Error
-		readers, err = b.getBucketMetadataReaders()
+		backoff := metadataWriteBackoff
+		for attempt := 0; ; attempt++ {
+			writer, err = createBucketMetadataFile(disk, filename)
+			if err == nil {
+				if e := json.NewEncoder(writer).Encode(metadata); e != nil {
+					writer.CloseAndPurge()
+					err = probe.NewError(e)
+				}
+			}
+			if err == nil || attempt >= retries {
+				break
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
 		if err != nil {
-			return nil, err.Trace()
+			CleanupWritersOnError(writers)
+			return err.Trace()
 		}
+		writers = append(writers, writer)
 	}
-	for _, reader := range readers {
-		defer reader.Close()
+	for _, writer := range writers {
+		writer.Close()
+	}
+	return nil
+}
+
+// readBucketMetadataCopies - decode the bucket metadata on every disk, also returns the disks whose
+// copy is missing or fails to decode. Fails only if no disk has a copy which decodes
+func (b bucket) readBucketMetadataCopies(disks map[int]block.Block) (map[int]*AllBuckets, []int, *probe.Error) {
+	readers, perr := b.getBucketMetadataReaders(disks)
+	if perr != nil {
+		return nil, nil, perr.Trace()
 	}
 	var err error
-	for _, reader := range readers {
-		jenc := json.NewDecoder(reader)
-		if err = jenc.Decode(metadata); err == nil {
-			return metadata, nil
+	metadatas := make(map[int]*AllBuckets)
+	var unreadable []int
+	for order := range disks {
+		reader, ok := readers[order]
+		if !ok {
+			unreadable = append(unreadable, order)
+			continue
+		}
+		metadata := new(AllBuckets)
+		if err = json.NewDecoder(reader).Decode(metadata); err == nil {
+			metadatas[order] = metadata
+		} else {
+			unreadable = append(unreadable, order)
+		}
+		reader.Close()
+	}
+	if len(metadatas) == 0 {
+		return nil, nil, probe.NewError(err)
+	}
+	sort.Ints(unreadable)
+	return metadatas, unreadable, nil
+}
+
+// getBucketMetadata - bucket metadata agreed upon by the majority of disks, copies on disks which
+// disagree with it, fail to decode or are missing are repaired. Fails with InsufficientReadQuorum
+// if fewer than a majority of disks agree on a copy
+func (b bucket) getBucketMetadata() (*AllBuckets, *probe.Error) {
+	disks, err := b.getDisks()
+	if err != nil {
+		return nil, err.Trace()
+	}
+	// without disks there is no metadata to agree upon
+	if len(disks) == 0 {
+		return nil, nil
+	}
+	metadatas, unreadable, err := b.readBucketMetadataCopies(disks)
+	if err != nil {
+		return nil, err.Trace()
+	}
+	if quorum := len(disks)/2 + 1; len(metadatas) < quorum {
+		return nil, probe.NewError(InsufficientReadQuorum{Bucket: b.getBucketName(), Disks: len(metadatas), Quorum: quorum})
+	}
+	metadata, minority, err := quorumBucketMetadata(metadatas, len(disks))
+	if err != nil {
+		return nil, err.Trace()
+	}
+	if len(minority) > 0 || len(unreadable) > 0 {
+		b.repairBucketMetadata(disks)
+	}
+	return metadata, nil
+}
+
+// GetBucketCreationTime - time the bucket was created as recorded in the bucket metadata
+func (b bucket) GetBucketCreationTime() (time.Time, *probe.Error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		if os.IsNotExist(err.ToGoError()) {
+			return time.Time{}, probe.NewError(BucketNotFound{Bucket: b.getBucketName()})
 		}
+		return time.Time{}, err.Trace()
+	}
+	metadata, ok := bucketMetadata.Buckets[b.getBucketName()]
+	if !ok {
+		return time.Time{}, probe.NewError(BucketNotFound{Bucket: b.getBucketName()})
 	}
-	return nil, probe.NewError(err)
+	return metadata.Created, nil
 }
 
 // GetObjectMetadata - get metadata for an object
-func (b bucket) GetObjectMetadata(objectName string) (ObjectMetadata, *probe.Error) {
-	b.lock.Lock()
-	defer b.lock.Unlock()
-	return b.readObjectMetadata(normalizeObjectName(objectName))
+func (b bucket) GetObjectMetadata(objectName string) (objMetadata ObjectMetadata, err *probe.Error) {
+	defer b.rlockObject(objectName)()
+	defer func(start time.Time) {
+		b.stats.recordRequest(b.name, operationHeadObject, start, 0, err)
+	}(time.Now())
+	if b.isCachedNotFound(objectName) {
+		return ObjectMetadata{}, probe.NewError(ObjectNotFound{Object: objectName})
+	}
+	objMetadata, err = b.readObjectMetadata(b.getObjectPath(objectName))
+	if err != nil {
+		if os.IsNotExist(err.ToGoError()) {
+			b.cacheNotFound(objectName)
+		}
+		return ObjectMetadata{}, err.Trace()
+	}
+	objMetadata.ReencodeRecommended, err = b.isReencodeRecommended(objMetadata)
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	return objMetadata, nil
+}
+
+// StatObject - metadata of an object for HEAD requests with its expiry under the bucket lifecycle
+// rules, objects not in the bucket index are not found without opening any of their slices
+func (b bucket) StatObject(objectName string) (objMetadata ObjectMetadata, err *probe.Error) {
+	defer b.rlockObject(objectName)()
+	defer func(start time.Time) {
+		b.stats.recordRequest(b.name, operationHeadObject, start, 0, err)
+	}(time.Now())
+	if b.isCachedNotFound(objectName) {
+		return ObjectMetadata{}, probe.NewError(ObjectNotFound{Object: objectName})
+	}
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	metadata := bucketMetadata.Buckets[b.getBucketName()]
+	if _, ok := metadata.BucketObjects[objectName]; !ok {
+		b.cacheNotFound(objectName)
+		return ObjectMetadata{}, probe.NewError(ObjectNotFound{Object: objectName})
+	}
+	objMetadata, err = b.readObjectMetadata(b.getObjectPath(objectName))
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	objMetadata.ReencodeRecommended, err = b.isReencodeRecommended(objMetadata)
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	return setObjectExpiration(objMetadata, metadata.LifecycleRules), nil
+}
+
+// DescribeObjectLayout - human readable layout of an object as stored, its encoding, chunks and the
+// disk holding every shard or replica, for operators diagnosing placement issues
+func (b bucket) DescribeObjectLayout(objectName string) (string, *probe.Error) {
+	defer b.rlockObject(objectName)()
+	objectPath := b.getObjectPath(objectName)
+	objMetadata, err := b.readObjectMetadata(objectPath)
+	if err != nil {
+		return "", err.Trace()
+	}
+	var layout bytes.Buffer
+	fmt.Fprintf(&layout, "object: %s/%s\n", b.getBucketName(), objMetadata.Object)
+	fmt.Fprintf(&layout, "size: %d\n", objMetadata.Size)
+	if objMetadata.Compression != "" {
+		fmt.Fprintf(&layout, "compression: %s, stored size: %d\n", objMetadata.Compression, objMetadata.StoredSize)
+	}
+	totalShards := int(objMetadata.ReplicaDisks)
+	if objMetadata.ReplicaDisks > 0 {
+		fmt.Fprintf(&layout, "encoding: replicated on %d disks\n", objMetadata.ReplicaDisks)
+	} else {
+		totalShards = int(objMetadata.DataDisks) + int(objMetadata.ParityDisks)
+		fmt.Fprintf(&layout, "encoding: erasure, %d data + %d parity shards\n", objMetadata.DataDisks, objMetadata.ParityDisks)
+		fmt.Fprintf(&layout, "block size: %d\n", objMetadata.BlockSize)
+		fmt.Fprintf(&layout, "chunks: %d\n", objMetadata.ChunkCount)
+	}
+	nodeSlice := 0
+	for _, node := range b.getNodes() {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return "", err.Trace()
+		}
+		for order := 0; order < len(disks); order++ {
+			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, order)
+			slicePath := filepath.Join(disks[order].GetPath(), b.xlName, bucketSlice, objectPath)
+			switch {
+			case order >= totalShards:
+				continue
+			case objMetadata.ReplicaDisks > 0:
+				fmt.Fprintf(&layout, "replica %d: %s\n", order, slicePath)
+			case order < int(objMetadata.DataDisks):
+				fmt.Fprintf(&layout, "shard %d (data): %s\n", order, slicePath)
+			default:
+				fmt.Fprintf(&layout, "shard %d (parity): %s\n", order, slicePath)
+			}
+		}
+		nodeSlice = nodeSlice + 1
+	}
+	return layout.String(), nil
+}
+
+// VerifyAgainstManifest - compare the stored md5sum of every object in manifest, mapping object names
+// to hex encoded md5sums, against the expected one. Object data is not read, missing objects do not match
+func (b bucket) VerifyAgainstManifest(manifest map[string]string) (map[string]bool, *probe.Error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	results := make(map[string]bool)
+	for objectName, expectedMD5Sum := range manifest {
+		objMetadata, err := b.readObjectMetadata(b.getObjectPath(objectName))
+		if err != nil {
+			if os.IsNotExist(err.ToGoError()) {
+				results[objectName] = false
+				continue
+			}
+			return nil, err.Trace(objectName)
+		}
+		expectedMD5Sum = strings.ToLower(strings.Trim(strings.TrimSpace(expectedMD5Sum), "\""))
+		results[objectName] = objMetadata.MD5Sum == expectedMD5Sum
+	}
+	return results, nil
+}
+
+// isReencodeRecommended - erasure coded objects are recommended to be re-encoded when their data and
+// parity differ from what the current disks dictate, e.g after disks were added or removed. Objects
+// written with requested parity are compared against the same parity on the current disks
+func (b bucket) isReencodeRecommended(objMetadata ObjectMetadata) (bool, *probe.Error) {
+	if objMetadata.DataDisks == 0 {
+		return false, nil
+	}
+	totalDisks := 0
+	for _, node := range b.getNodes() {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return false, err.Trace()
+		}
+		totalDisks += len(disks)
+	}
+	k, m, err := b.getDataAndParity(totalDisks)
+	if err != nil {
+		// current disks cannot erasure code at all
+		return false, nil
+	}
+	if requested := int(objMetadata.RequestedParityDisks); requested > 0 && requested < totalDisks {
+		k, m = uint8(totalDisks-requested), uint8(requested)
+	}
+	return k != objMetadata.DataDisks || m != objMetadata.ParityDisks, nil
+}
+
+// getObjectETag - ETag of an object, md5sum for objects written before ETag was recorded
+func getObjectETag(objMetadata ObjectMetadata) string {
+	if objMetadata.ETag != "" {
+		return objMetadata.ETag
+	}
+	return objMetadata.MD5Sum
+}
+
+// IsETagMatch - verify if any of the comma separated entity tags matches with the ETag of
+// an object, entity tags are compared weakly and `*` matches any
+func (b bucket) IsETagMatch(objectName, etags string) (bool, *probe.Error) {
+	defer b.rlockObject(objectName)()
+	objMetadata, err := b.readObjectMetadata(b.getObjectPath(objectName))
+	if err != nil {
+		return false, err.Trace()
+	}
+	return isETagMatch(objMetadata, etags, true), nil
+}
+
+// isETagMatch - verify if any of the comma separated etags matches the object's ETag, weak entity
+// tags only match if compared weakly
+func isETagMatch(objMetadata ObjectMetadata, etags string, weak bool) bool {
+	objectETag := getObjectETag(objMetadata)
+	for _, etag := range strings.Split(etags, ",") {
+		etag = strings.TrimSpace(etag)
+		if etag == "*" {
+			return true
+		}
+		if strings.HasPrefix(etag, "W/") {
+			if !weak {
+				continue
+			}
+			etag = strings.TrimPrefix(etag, "W/")
+		}
+		if strings.Trim(etag, "\"") == objectETag {
+			return true
+		}
+	}
+	return false
+}
+
+// GetObjectIntegrity - get checksums and encoding parameters of an object, object data is not read
+func (b bucket) GetObjectIntegrity(objectName string) (IntegrityManifest, *probe.Error) {
+	defer b.rlockObject(objectName)()
+	objMetadata, err := b.readObjectMetadata(b.getObjectPath(objectName))
+	if err != nil {
+		return IntegrityManifest{}, err.Trace()
+	}
+	return IntegrityManifest{
+		Bucket:       objMetadata.Bucket,
+		Object:       objMetadata.Object,
+		Size:         objMetadata.Size,
+		MD5Sum:       objMetadata.MD5Sum,
+		SHA512Sum:    objMetadata.SHA512Sum,
+		DataDisks:    objMetadata.DataDisks,
+		ParityDisks:  objMetadata.ParityDisks,
+		BlockSize:    objMetadata.BlockSize,
+		ChunkSizes:   objMetadata.ChunkSizes,
+		ChunkCount:   objMetadata.ChunkCount,
+		ReplicaDisks: objMetadata.ReplicaDisks,
+		Compression:  objMetadata.Compression,
+		StoredSize:   objMetadata.StoredSize,
+	}, nil
+}
+
+// EstimateDecodeCost - estimate the CPU cost of decoding an object from its metadata, without
+// reading any of its data. Replicated objects need no decoding
+func (b bucket) EstimateDecodeCost(objectName string) (DecodeCostEstimate, *probe.Error) {
+	defer b.rlockObject(objectName)()
+	objMetadata, err := b.readObjectMetadata(b.getObjectPath(objectName))
+	if err != nil {
+		return DecodeCostEstimate{}, err.Trace()
+	}
+	if objMetadata.DataDisks == 0 {
+		return DecodeCostEstimate{}, nil
+	}
+	estimate := DecodeCostEstimate{
+		ChunkCount:  objMetadata.ChunkCount,
+		DataDisks:   objMetadata.DataDisks,
+		ParityDisks: objMetadata.ParityDisks,
+	}
+	encoder, err := newEncoder(objMetadata.DataDisks, objMetadata.ParityDisks)
+	if err != nil {
+		return DecodeCostEstimate{}, err.Trace()
+	}
+	totalLeft := objMetadata.Size
+	if objMetadata.Compression != "" {
+		totalLeft = objMetadata.StoredSize
+	}
+	k, m := int64(objMetadata.DataDisks), int64(objMetadata.ParityDisks)
+	for i := 0; i < objMetadata.ChunkCount && totalLeft > 0; i++ {
+		chunkSize := int64(objMetadata.BlockSize)
+		if len(objMetadata.ChunkSizes) > 0 {
+			chunkSize = objMetadata.ChunkSizes[i]
+		}
+		if chunkSize > totalLeft {
+			chunkSize = totalLeft
+		}
+		sliceLen, err := encoder.GetEncodedBlockLen(int(chunkSize))
+		if err != nil {
+			return DecodeCostEstimate{}, err.Trace()
+		}
+		// every slice of the chunk is a linear combination of the k data slices
+		estimate.Operations += int64(sliceLen) * k * (k + m)
+		totalLeft = totalLeft - chunkSize
+	}
+	return estimate, nil
+}
+
+// DiskUsageByBucket - bytes of object slices stored per disk order for this bucket, metadata
+// and in-progress writes are not accounted
+func (b bucket) DiskUsageByBucket() (map[int]int64, *probe.Error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	usage := make(map[int]int64)
+	nodeSlice := 0
+	for _, node := range b.getNodes() {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return nil, err.Trace()
+		}
+		for order, disk := range disks {
+			usage[order] = 0
+			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, order)
+			objects, err := disk.ListDir(filepath.Join(b.xlName, bucketSlice))
+			if err != nil {
+				if os.IsNotExist(err.ToGoError()) {
+					continue
+				}
+				return nil, err.Trace()
+			}
+			for _, object := range objects {
+				files, err := disk.ListFiles(filepath.Join(b.xlName, bucketSlice, object.Name()))
+				if err != nil {
+					return nil, err.Trace()
+				}
+				for _, file := range files {
+					if file.Name() == "data" {
+						usage[order] += file.Size()
+					}
+				}
+			}
+		}
+		nodeSlice = nodeSlice + 1
+	}
+	return usage, nil
 }
 
 // ListObjects - list all objects
 func (b bucket) ListObjects(prefix, marker, delimiter string, maxkeys int) (ListObjectsResults, *probe.Error) {
-	b.lock.Lock()
-	defer b.lock.Unlock()
-	if maxkeys <= 0 {
-		maxkeys = 1000
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	start := time.Now()
+	listObjects, err := b.listObjects(prefix, marker, delimiter, maxkeys)
+	b.stats.recordRequest(b.name, operationListObjects, start, 0, err)
+	return listObjects, err
+}
+
+// ListObjectsWithTags - list objects along with their tags, costs one extra read per listed object
+// which is bounded by maxkeys. If tagKey is not empty only objects having the tag, with tagValue
+// if not empty, are returned, the filter applies to the listed page of objects
+func (b bucket) ListObjectsWithTags(prefix, marker, delimiter string, maxkeys int, tagKey, tagValue string) (ListObjectsResults, *probe.Error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	listObjects, err := b.listObjects(prefix, marker, delimiter, maxkeys)
+	if err != nil {
+		return ListObjectsResults{}, err.Trace()
 	}
-	var isTruncated bool
-	var objects []string
+	for objectName, objMetadata := range listObjects.Objects {
+		tags, err := b.readObjectTags(b.getObjectPath(objectName))
+		if err != nil {
+			return ListObjectsResults{}, err.Trace()
+		}
+		if tagKey != "" {
+			value, ok := tags[tagKey]
+			if !ok || (tagValue != "" && value != tagValue) {
+				delete(listObjects.Objects, objectName)
+				continue
+			}
+		}
+		objMetadata.Tags = tags
+		listObjects.Objects[objectName] = objMetadata
+	}
+	return listObjects, nil
+}
+
+// listObjects - list objects, caller holds the bucket read lock
+func (b bucket) listObjects(prefix, marker, delimiter string, maxkeys int) (ListObjectsResults, *probe.Error) {
+	// clamp to the maximum allowed, matching AWS S3
+	if maxkeys <= 0 || maxkeys > maxObjectList {
+		maxkeys = maxObjectList
+	}
+	// a bucket missing from the index, or without any index when no bucket was created, does not
+	// exist rather than being empty
 	bucketMetadata, err := b.getBucketMetadata()
 	if err != nil {
+		if os.IsNotExist(err.ToGoError()) {
+			return ListObjectsResults{}, probe.NewError(BucketNotFound{Bucket: b.getBucketName()})
+		}
 		return ListObjectsResults{}, err.Trace()
 	}
-	for objectName := range bucketMetadata.Buckets[b.getBucketName()].Multiparts {
-		if strings.HasPrefix(objectName, strings.TrimSpace(prefix)) {
-			if objectName > marker {
-				objects = append(objects, objectName)
-			}
+	metadata, ok := bucketMetadata.Buckets[b.getBucketName()]
+	if !ok {
+		return ListObjectsResults{}, probe.NewError(BucketNotFound{Bucket: b.getBucketName()})
+	}
+	var results, commonPrefixes []string
+	var isTruncated bool
+	var nextMarker string
+	// top level listing reads directly from the bucket index
+	if strings.TrimSpace(prefix) == "" {
+		results, commonPrefixes, isTruncated, nextMarker = listObjectNamesWithoutPrefix(metadata, marker, delimiter, maxkeys)
+	} else {
+		results, commonPrefixes, isTruncated, nextMarker = listObjectNames(metadata, prefix, marker, delimiter, maxkeys)
+	}
+
+	listObjects := ListObjectsResults{}
+	listObjects.Objects = make(map[string]ObjectMetadata)
+	listObjects.CommonPrefixes = commonPrefixes
+	listObjects.IsTruncated = isTruncated
+	listObjects.NextMarker = nextMarker
+
+	for _, objectName := range results {
+		objMetadata, err := b.readObjectMetadata(b.getObjectPath(objectName))
+		if err != nil {
+			return ListObjectsResults{}, err.Trace()
 		}
+		listObjects.Objects[objectName] = objMetadata
 	}
-	for objectName := range bucketMetadata.Buckets[b.getBucketName()].BucketObjects {
+	return listObjects, nil
+}
+
+// listObjectNames - list object names and common prefixes from the bucket index
+func listObjectNames(bucketMetadata BucketMetadata, prefix, marker, delimiter string, maxkeys int) ([]string, []string, bool, string) {
+	var objects []string
+	// objects being uploaded in parts are not listed until their upload is completed
+	for objectName := range bucketMetadata.BucketObjects {
 		if strings.HasPrefix(objectName, strings.TrimSpace(prefix)) {
 			if objectName > marker {
 				objects = append(objects, objectName)
@@ -187,114 +823,789 @@ func (b bucket) ListObjects(prefix, marker, delimiter string, maxkeys int) (List
 	filteredObjects = RemoveDuplicates(filteredObjects)
 	sort.Strings(filteredObjects)
 	for _, objectName := range filteredObjects {
-		if len(results) >= maxkeys {
-			isTruncated = true
-			break
-		}
 		results = append(results, prefix+objectName)
 	}
-	results = RemoveDuplicates(results)
 	commonPrefixes = RemoveDuplicates(commonPrefixes)
 	sort.Strings(commonPrefixes)
+	return limitObjectNames(results, commonPrefixes, marker, maxkeys)
+}
 
-	listObjects := ListObjectsResults{}
-	listObjects.Objects = make(map[string]ObjectMetadata)
-	listObjects.CommonPrefixes = commonPrefixes
-	listObjects.IsTruncated = isTruncated
-
-	for _, objectName := range results {
-		objMetadata, err := b.readObjectMetadata(normalizeObjectName(objectName))
-		if err != nil {
-			return ListObjectsResults{}, err.Trace()
+// limitObjectNames - keep the first maxkeys of the sorted object names and common prefixes taken
+// together, as a page resumed after marker. Like object names, common prefixes are listed only if
+// they sort after marker, a common prefix returned as marker of the previous page is not repeated.
+// Next marker is the last name or common prefix kept, set only when truncated
+func limitObjectNames(objects, commonPrefixes []string, marker string, maxkeys int) ([]string, []string, bool, string) {
+	var prefixes []string
+	for _, commonPrefix := range commonPrefixes {
+		if commonPrefix > marker {
+			prefixes = append(prefixes, commonPrefix)
 		}
-		listObjects.Objects[objectName] = objMetadata
 	}
-	return listObjects, nil
+	results := []string{}
+	limitedPrefixes := []string{}
+	var nextMarker string
+	i, j := 0, 0
+	for i < len(objects) || j < len(prefixes) {
+		if len(results)+len(limitedPrefixes) >= maxkeys {
+			return results, limitedPrefixes, true, nextMarker
+		}
+		if j == len(prefixes) || (i < len(objects) && objects[i] < prefixes[j]) {
+			nextMarker = objects[i]
+			results = append(results, objects[i])
+			i++
+			continue
+		}
+		nextMarker = prefixes[j]
+		limitedPrefixes = append(limitedPrefixes, prefixes[j])
+		j++
+	}
+	return results, limitedPrefixes, false, ""
 }
 
-// ReadObject - open an object to read
-func (b bucket) ReadObject(objectName string) (reader io.ReadCloser, size int64, err *probe.Error) {
-	b.lock.Lock()
-	defer b.lock.Unlock()
-	reader, writer := io.Pipe()
+// maxObjectChanges - changes kept in the bucket metadata, older changes are dropped
+var maxObjectChanges = 10000
+
+// recordObjectChange - record an object change with the next sequence, previous change of the object
+// and changes of objects no longer in the bucket are dropped such that changes stay ordered by
+// sequence. Beyond maxObjectChanges the oldest changes are dropped and remembered as truncated
+func recordObjectChange(bucketMetadata BucketMetadata, objectName string, modified time.Time) BucketMetadata {
+	var changes []ObjectChange
+	for _, change := range bucketMetadata.Changes {
+		if change.Object == objectName {
+			continue
+		}
+		if _, ok := bucketMetadata.BucketObjects[change.Object]; !ok {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	bucketMetadata.Sequence = bucketMetadata.Sequence + 1
+	changes = append(changes, ObjectChange{
+		Object:   objectName,
+		Sequence: bucketMetadata.Sequence,
+		Modified: modified,
+	})
+	if drop := len(changes) - maxObjectChanges; drop > 0 {
+		for _, change := range changes[:drop] {
+			if change.Sequence > bucketMetadata.TruncatedSequence {
+				bucketMetadata.TruncatedSequence = change.Sequence
+			}
+			if change.Modified.After(bucketMetadata.TruncatedModified) {
+				bucketMetadata.TruncatedModified = change.Modified
+			}
+		}
+		changes = append([]ObjectChange(nil), changes[drop:]...)
+	}
+	bucketMetadata.Changes = changes
+	return bucketMetadata
+}
+
+// advanceGeneration - advance the generation of the bucket index, on every addition or removal of objects
+func advanceGeneration(bucketMetadata BucketMetadata) BucketMetadata {
+	bucketMetadata.Generation = bucketMetadata.Generation + 1
+	return bucketMetadata
+}
+
+// listObjectChangesSince - list objects changed after a given sequence, fails with
+// ObjectChangesTruncated if changes after the sequence have been dropped
+func listObjectChangesSince(bucketMetadata BucketMetadata, sequence uint64) ([]ObjectChange, *probe.Error) {
+	if sequence < bucketMetadata.TruncatedSequence {
+		return nil, probe.NewError(ObjectChangesTruncated{Bucket: bucketMetadata.Name, Sequence: bucketMetadata.TruncatedSequence})
+	}
+	start := sort.Search(len(bucketMetadata.Changes), func(i int) bool {
+		return bucketMetadata.Changes[i].Sequence > sequence
+	})
+	changes := []ObjectChange{}
+	for _, change := range bucketMetadata.Changes[start:] {
+		if _, ok := bucketMetadata.BucketObjects[change.Object]; ok {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// listObjectChangesAfter - list objects changed after a given time in sequence order, modification
+// times are not ordered by sequence hence every change is checked. Fails with ObjectChangesTruncated
+// if changes after the time have been dropped
+func listObjectChangesAfter(bucketMetadata BucketMetadata, t time.Time) ([]ObjectChange, *probe.Error) {
+	if bucketMetadata.TruncatedModified.After(t) {
+		return nil, probe.NewError(ObjectChangesTruncated{Bucket: bucketMetadata.Name, Sequence: bucketMetadata.TruncatedSequence})
+	}
+	changes := []ObjectChange{}
+	for _, change := range bucketMetadata.Changes {
+		if !change.Modified.After(t) {
+			continue
+		}
+		if _, ok := bucketMetadata.BucketObjects[change.Object]; ok {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// listObjectNamesWithoutPrefix - list object names and common prefixes from the bucket index when
+// prefix is empty, in a single pass without any prefix trimming
+func listObjectNamesWithoutPrefix(bucketMetadata BucketMetadata, marker, delimiter string, maxkeys int) ([]string, []string, bool, string) {
+	delimiter = strings.TrimSpace(delimiter)
+	seen := make(map[string]struct{})
+	prefixes := make(map[string]struct{})
+	var objects []string
+	addObject := func(objectName string) {
+		if objectName <= marker {
+			return
+		}
+		if delimiter != "" {
+			if i := strings.Index(objectName, delimiter); i >= 0 {
+				prefixes[objectName[:i+len(delimiter)]] = struct{}{}
+				return
+			}
+		}
+		if _, ok := seen[objectName]; !ok {
+			seen[objectName] = struct{}{}
+			objects = append(objects, objectName)
+		}
+	}
+	for objectName := range bucketMetadata.BucketObjects {
+		addObject(objectName)
+	}
+	sort.Strings(objects)
+	commonPrefixes := []string{}
+	for commonPrefix := range prefixes {
+		commonPrefixes = append(commonPrefixes, commonPrefix)
+	}
+	sort.Strings(commonPrefixes)
+	return limitObjectNames(objects, commonPrefixes, marker, maxkeys)
+}
+
+// ReadObject - open an object to read, progress is optional and if provided is
+// called once for every verified chunk. Decoding stops once ctx is cancelled
+func (b bucket) ReadObject(ctx context.Context, objectName string, progress ChunkProgressFunc) (reader io.ReadCloser, size int64, err *probe.Error) {
+	return b.readObjectWithOptions(ctx, objectName, ReadOptions{Progress: progress})
+}
+
+// readObject - open a whole object to read, data is decoded in a go-routine and verified once all
+// of it is read
+func (b bucket) readObject(ctx context.Context, objectName string, progress ChunkProgressFunc) (reader io.ReadCloser, objMetadata ObjectMetadata, err *probe.Error) {
+	defer b.rlockObject(objectName)()
+	// the request is recorded once the object is read, failures to open it right away
+	defer func(start time.Time) {
+		if err != nil {
+			b.stats.recordRequest(b.name, operationGetObject, start, 0, err)
+			return
+		}
+		reader = meteredReader{ReadCloser: reader, meter: b.newRequestMeter(operationGetObject, start)}
+	}(time.Now())
+	if b.isCachedNotFound(objectName) {
+		return nil, ObjectMetadata{}, probe.NewError(ObjectNotFound{Object: objectName})
+	}
 	// get list of objects
 	bucketMetadata, err := b.getBucketMetadata()
 	if err != nil {
-		return nil, 0, err.Trace()
+		return nil, ObjectMetadata{}, err.Trace()
 	}
 	// check if object exists
 	if _, ok := bucketMetadata.Buckets[b.getBucketName()].BucketObjects[objectName]; !ok {
-		return nil, 0, probe.NewError(ObjectNotFound{Object: objectName})
+		b.cacheNotFound(objectName)
+		return nil, ObjectMetadata{}, probe.NewError(ObjectNotFound{Object: objectName})
 	}
-	objMetadata, err := b.readObjectMetadata(normalizeObjectName(objectName))
+	objectPath := b.getObjectPath(objectName)
+	objMetadata, err = b.readObjectMetadata(objectPath)
 	if err != nil {
-		return nil, 0, err.Trace()
+		return nil, ObjectMetadata{}, err.Trace()
+	}
+	objMetadata.ReencodeRecommended, err = b.isReencodeRecommended(objMetadata)
+	if err != nil {
+		return nil, ObjectMetadata{}, err.Trace()
 	}
+	if objMetadata.ReencodeRecommended {
+		b.stats.recordReencode(objMetadata)
+	}
+	pipeReader, pipeWriter := io.Pipe()
 	// read and reply back to GetObject() request in a go-routine
-	go b.readObjectData(normalizeObjectName(objectName), writer, objMetadata)
-	return reader, objMetadata.Size, nil
+	go b.readObjectData(ctx, objectPath, pipeWriter, objMetadata, progress)
+	return pipeReader, objMetadata, nil
 }
 
-// WriteObject - write a new object into bucket
-func (b bucket) WriteObject(objectName string, objectData io.Reader, size int64, expectedMD5Sum string, metadata map[string]string, signature *signature4.Sign) (ObjectMetadata, *probe.Error) {
-	b.lock.Lock()
-	defer b.lock.Unlock()
+// ReadObjectTo - read an object into w without going through a pipe, decoded chunks are gathered
+// into vectored writes which use writev on network connections. Checksums are verified once all
+// data is written, on a mismatch w has already received the data
+func (b bucket) ReadObjectTo(objectName string, w io.Writer) (written int64, err *probe.Error) {
+	defer func(start time.Time) {
+		b.stats.recordRequest(b.name, operationGetObject, start, written, err)
+	}(time.Now())
+	objectPath, objMetadata, readers, err := b.openObjectReaders(objectName)
+	if err != nil {
+		return 0, err.Trace()
+	}
+	return b.readObjectTo(objectPath, objMetadata, readers, w)
+}
+
+// readObjectTo - decode the data of opened slice readers into w, verifying it against the md5sum
+// of objMetadata
+func (b bucket) readObjectTo(objectPath string, objMetadata ObjectMetadata, readers map[int]io.ReadCloser, w io.Writer) (int64, *probe.Error) {
+	if objMetadata.DataDisks == 0 || objMetadata.Compression != "" {
+		// replicated and compressed objects are not decoded chunk by chunk
+		reader, writer := io.Pipe()
+		defer reader.Close()
+		go b.readObjectReaders(context.Background(), objectPath, readers, writer, objMetadata, nil)
+		n, e := io.CopyN(w, reader, objMetadata.Size)
+		if e != nil {
+			return n, probe.NewError(e)
+		}
+		return n, nil
+	}
+	return b.readEncodedDataTo(readers, w, objMetadata, nil)
+}
+
+// openObjectReaders - metadata and opened slice readers of an indexed object. The object is locked
+// only until its slices are open, such that slow writes of the data read do not block writers
+func (b bucket) openObjectReaders(objectName string) (string, ObjectMetadata, map[int]io.ReadCloser, *probe.Error) {
+	defer b.rlockObject(objectName)()
+	objectPath, objMetadata, err := b.readIndexedObjectMetadata(objectName)
+	if err != nil {
+		return "", ObjectMetadata{}, nil, err.Trace()
+	}
+	readers, err := b.getObjectReaders(objectPath, "data")
+	if err != nil {
+		return "", ObjectMetadata{}, nil, err.Trace()
+	}
+	return objectPath, objMetadata, readers, nil
+}
+
+// readIndexedObjectMetadata - path and metadata of an object in the bucket index, objects not in the
+// index are not found. The caller holds the object lock
+func (b bucket) readIndexedObjectMetadata(objectName string) (string, ObjectMetadata, *probe.Error) {
+	if b.isCachedNotFound(objectName) {
+		return "", ObjectMetadata{}, probe.NewError(ObjectNotFound{Object: objectName})
+	}
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return "", ObjectMetadata{}, err.Trace()
+	}
+	if _, ok := bucketMetadata.Buckets[b.getBucketName()].BucketObjects[objectName]; !ok {
+		b.cacheNotFound(objectName)
+		return "", ObjectMetadata{}, probe.NewError(ObjectNotFound{Object: objectName})
+	}
+	objectPath := b.getObjectPath(objectName)
+	objMetadata, err := b.readObjectMetadata(objectPath)
+	if err != nil {
+		return "", ObjectMetadata{}, err.Trace()
+	}
+	return objectPath, objMetadata, nil
+}
+
+// ReadObjectRange - open length bytes of an object from start to read. Chunks of erasure coded objects
+// before the range are skipped without decoding them, replicated objects are read from the first
+// replica. Only whole object reads verify checksums, ranges of compressed objects are decompressed
+// from the beginning
+func (b bucket) ReadObjectRange(objectName string, start, length int64) (reader io.ReadCloser, err *probe.Error) {
+	defer b.rlockObject(objectName)()
+	// the request is recorded once the range is read, failures to open it right away
+	defer func(begin time.Time) {
+		if err != nil {
+			b.stats.recordRequest(b.name, operationGetObject, begin, 0, err)
+			return
+		}
+		reader = meteredReader{ReadCloser: reader, meter: b.newRequestMeter(operationGetObject, begin)}
+	}(time.Now())
+	objectPath, objMetadata, err := b.readIndexedObjectMetadata(objectName)
+	if err != nil {
+		return nil, err.Trace()
+	}
+	return b.readObjectRange(objectPath, objMetadata, start, length)
+}
+
+// ReadObjectPart - open a part of an object written by a multipart upload to read, returns the part size.
+// Part numbers are validated against the parts the object was assembled from, under the same lock
+// as the part is opened
+func (b bucket) ReadObjectPart(objectName string, partNumber int) (reader io.ReadCloser, size int64, err *probe.Error) {
+	defer b.rlockObject(objectName)()
+	defer func(begin time.Time) {
+		if err != nil {
+			b.stats.recordRequest(b.name, operationGetObject, begin, 0, err)
+			return
+		}
+		reader = meteredReader{ReadCloser: reader, meter: b.newRequestMeter(operationGetObject, begin)}
+	}(time.Now())
+	objectPath, objMetadata, err := b.readIndexedObjectMetadata(objectName)
+	if err != nil {
+		return nil, 0, err.Trace()
+	}
+	part, err := objMetadata.getPart(partNumber)
+	if err != nil {
+		return nil, 0, err.Trace()
+	}
+	reader, err = b.readObjectRange(objectPath, objMetadata, part.Offset, part.Size)
+	if err != nil {
+		return nil, 0, err.Trace()
+	}
+	return reader, part.Size, nil
+}
+
+// readObjectRange - open a range of an object to read like ReadObjectRange, the caller holds the
+// object lock
+func (b bucket) readObjectRange(objectPath string, objMetadata ObjectMetadata, start, length int64) (io.ReadCloser, *probe.Error) {
+	if start < 0 || length < 0 || start+length > objMetadata.Size {
+		return nil, probe.NewError(InvalidRange{Start: start, Length: length})
+	}
+	pipeReader, pipeWriter := io.Pipe()
+	if objMetadata.Compression != "" {
+		go b.readObjectData(context.Background(), objectPath, pipeWriter, objMetadata, nil)
+		if _, e := io.CopyN(ioutil.Discard, pipeReader, start); e != nil {
+			pipeReader.Close()
+			return nil, probe.NewError(e)
+		}
+		return rangeReadCloser{Reader: io.LimitReader(pipeReader, length), Closer: pipeReader}, nil
+	}
+	readers, err := b.getObjectReaders(objectPath, "data")
+	if err != nil {
+		return nil, err.Trace()
+	}
+	if objMetadata.DataDisks == 0 {
+		// replicated and single disk objects are stored as is
+		var orders []int
+		for order := range readers {
+			orders = append(orders, order)
+		}
+		if len(orders) == 0 {
+			return nil, probe.NewError(InsufficientReadQuorum{Bucket: b.getBucketName(), Disks: 0, Quorum: 1})
+		}
+		sort.Ints(orders)
+		// replicas failing to advance to start are skipped for the next one
+		var e error
+		for i, order := range orders {
+			if e = skipShardReader(readers[order], start); e != nil {
+				readers[order].Close()
+				continue
+			}
+			for _, other := range orders[i+1:] {
+				readers[other].Close()
+			}
+			return rangeReadCloser{Reader: io.LimitReader(readers[order], length), Closer: readers[order]}, nil
+		}
+		return nil, probe.NewError(e)
+	}
+	if len(readers) < int(objMetadata.DataDisks) {
+		for _, reader := range readers {
+			reader.Close()
+		}
+		return nil, probe.NewError(InsufficientReadQuorum{Bucket: b.getBucketName(), Disks: len(readers), Quorum: int(objMetadata.DataDisks)})
+	}
+	encoder, err := newEncoder(objMetadata.DataDisks, objMetadata.ParityDisks)
+	if err != nil {
+		return nil, err.Trace()
+	}
+	// slices are skipped up to the first chunk holding start, chunks up to the end of the range are decoded
+	var sliceOffset, dataOffset, skip int64
+	var firstChunk, chunks int
+	err = forEachChunk(objMetadata, encoder, func(chunkLength, sliceLen int) *probe.Error {
+		end := dataOffset + int64(chunkLength)
+		switch {
+		case end <= start:
+			sliceOffset += int64(sliceLen)
+			firstChunk++
+		case dataOffset < start+length:
+			if chunks == 0 {
+				skip = start - dataOffset
+			}
+			chunks++
+		}
+		dataOffset = end
+		return nil
+	})
+	if err != nil {
+		return nil, err.Trace()
+	}
+	go b.readEncodedRange(readers, pipeWriter, objMetadata, sliceOffset, firstChunk, chunks, skip, length)
+	return pipeReader, nil
+}
+
+// readEncodedRange - decode chunks of an erasure coded object from firstChunk, starting sliceOffset
+// into every slice, writing length bytes from skip into the first chunk
+func (b bucket) readEncodedRange(readers map[int]io.ReadCloser, writer *io.PipeWriter, objMetadata ObjectMetadata, sliceOffset int64, firstChunk, chunks int, skip, length int64) {
+	for _, reader := range readers {
+		defer reader.Close()
+	}
+	skipShardReaders(readers, sliceOffset)
+	decoder, err := b.newChunkDecoder(readers, objMetadata, firstChunk)
+	if err != nil {
+		writer.CloseWithError(probe.WrapError(err))
+		return
+	}
+	for i := 0; i < chunks; i++ {
+		decodedData, _, _, err := decoder.next(true)
+		if err != nil {
+			writer.CloseWithError(probe.WrapError(err))
+			return
+		}
+		decodedData = decodedData[skip:]
+		skip = 0
+		if int64(len(decodedData)) > length {
+			decodedData = decodedData[:length]
+		}
+		if _, err := writer.Write(decodedData); err != nil {
+			return
+		}
+		length -= int64(len(decodedData))
+	}
+	decoder.recordRead()
+	writer.Close()
+}
+
+// rangeReadCloser - reader limited to a range, closing the underlying reader
+type rangeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// readEncodedDataTo - decode an erasure coded object into w with vectored writes, a sampler verifies
+// only the slices of sampled chunks and not the checksums of the whole object
+func (b bucket) readEncodedDataTo(readers map[int]io.ReadCloser, w io.Writer, objMetadata ObjectMetadata, sampler *chunkSampler) (int64, *probe.Error) {
+	for _, reader := range readers {
+		defer reader.Close()
+	}
+	decoder, err := b.newChunkDecoder(readers, objMetadata, 0)
+	if err != nil {
+		return 0, err.Trace()
+	}
+	expectedMD5Sum, e := hex.DecodeString(objMetadata.MD5Sum)
+	if e != nil {
+		return 0, probe.NewError(e)
+	}
+	expectedSHA512Sum, e := hex.DecodeString(objMetadata.SHA512Sum)
+	if e != nil {
+		return 0, probe.NewError(e)
+	}
+	sumMD5 := md5.New()
+	sum512 := sha512.New()
+	var written, buffered int64
+	var buffers net.Buffers
+	flush := func() *probe.Error {
+		n, e := buffers.WriteTo(w)
+		written += n
+		buffers, buffered = nil, 0
+		if e != nil {
+			return probe.NewError(e)
+		}
+		return nil
+	}
+	for decoder.more() {
+		verify := sampler.verify(decoder.chunk, objMetadata.getSliceHashes(decoder.chunk))
+		decodedData, _, _, err := decoder.next(verify)
+		if err != nil {
+			return written, err.Trace()
+		}
+		sumMD5.Write(decodedData)
+		sum512.Write(decodedData)
+		// small chunks are gathered, large chunks are written as soon as they are decoded
+		buffers = append(buffers, decodedData)
+		buffered += int64(len(decodedData))
+		if buffered >= vectoredWriteSize {
+			if err := flush(); err != nil {
+				return written, err.Trace()
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return written, err.Trace()
+	}
+	decoder.recordRead()
+	if objMetadata.Reconstructed || sampler != nil {
+		return written, nil
+	}
+	if !bytes.Equal(expectedMD5Sum, sumMD5.Sum(nil)) || !bytes.Equal(expectedSHA512Sum, sum512.Sum(nil)) {
+		return written, probe.NewError(ChecksumMismatch{})
+	}
+	return written, nil
+}
+
+// WriteObject - write a new object into bucket, the write is aborted and its data purged once ctx
+// is cancelled
+func (b bucket) WriteObject(ctx context.Context, objectName string, objectData io.Reader, size int64, expectedMD5Sum string, metadata map[string]string, signature *signature4.Sign) (ObjectMetadata, *probe.Error) {
+	return b.writeObjectLocked(ctx, objectName, objectData, size, WriteOptions{
+		ExpectedMD5Sum: expectedMD5Sum,
+		Metadata:       metadata,
+		Signature:      signature,
+	})
+}
+
+// WriteObjectWithSHA512 - write object like WriteObject, with a hex encoded sha512sum already computed
+// by the caller. The supplied sha512sum is verified against the data, unless the bucket is configured
+// to trust supplied checksums, then it is stored without computing it again
+func (b bucket) WriteObjectWithSHA512(objectName string, objectData io.Reader, size int64, expectedMD5Sum, expectedSHA512Sum string, metadata map[string]string, signature *signature4.Sign) (ObjectMetadata, *probe.Error) {
+	if strings.TrimSpace(expectedSHA512Sum) == "" {
+		return ObjectMetadata{}, probe.NewError(InvalidArgument{})
+	}
+	return b.WriteObjectWithOptions(objectName, objectData, size, WriteOptions{
+		ExpectedMD5Sum:    expectedMD5Sum,
+		ExpectedSHA512Sum: expectedSHA512Sum,
+		Metadata:          metadata,
+		Signature:         signature,
+	})
+}
+
+// WriteObjectWithOptions - write a new object into bucket, verified and encoded as requested by opts
+func (b bucket) WriteObjectWithOptions(objectName string, objectData io.Reader, size int64, opts WriteOptions) (ObjectMetadata, *probe.Error) {
+	return b.writeObjectLocked(context.Background(), objectName, objectData, size, opts)
+}
+
+// writeObjectLocked - write the object holding its lock, recording the request
+func (b bucket) writeObjectLocked(ctx context.Context, objectName string, objectData io.Reader, size int64, opts WriteOptions) (ObjectMetadata, *probe.Error) {
+	defer b.lockObject(objectName)()
+	start := time.Now()
+	objMetadata, err := b.writeObjectWithOptions(ctx, objectName, objectData, size, opts)
+	b.stats.recordRequest(b.name, operationPutObject, start, objMetadata.Size, err)
+	return objMetadata, err
+}
+
+// writeObjectWithOptions - check the conditions of opts and write the object, caller holds the object lock
+func (b bucket) writeObjectWithOptions(ctx context.Context, objectName string, objectData io.Reader, size int64, opts WriteOptions) (ObjectMetadata, *probe.Error) {
+	if opts.Encryption != "" {
+		return ObjectMetadata{}, probe.NewError(NotImplemented{Function: "Encryption"})
+	}
+	if strings.TrimSpace(opts.IfMatch) != "" || strings.TrimSpace(opts.IfNoneMatch) != "" {
+		if err := b.checkWriteConditions(objectName, opts.IfMatch, opts.IfNoneMatch); err != nil {
+			return ObjectMetadata{}, err.Trace()
+		}
+	}
+	if opts.ContentType != "" {
+		// caller's metadata is left untouched
+		metadata := make(map[string]string)
+		for key, value := range opts.Metadata {
+			metadata[key] = value
+		}
+		metadata["contentType"] = strings.TrimSpace(opts.ContentType)
+		opts.Metadata = metadata
+	}
+	return b.writeObject(ctx, objectName, objectData, size, opts)
+}
+
+// checkWriteConditions - verify the object in the bucket index matches ifMatch and does not match ifNoneMatch
+func (b bucket) checkWriteConditions(objectName, ifMatch, ifNoneMatch string) *probe.Error {
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return err.Trace()
+	}
+	exists := false
+	if bucketMetadata != nil {
+		_, exists = bucketMetadata.Buckets[b.getBucketName()].BucketObjects[objectName]
+	}
+	var objMetadata ObjectMetadata
+	if exists {
+		objMetadata, err = b.readObjectMetadata(b.getObjectPath(objectName))
+		if err != nil {
+			return err.Trace()
+		}
+	}
+	if strings.TrimSpace(ifMatch) != "" && (!exists || !isETagMatch(objMetadata, ifMatch, false)) {
+		return probe.NewError(PreconditionFailed{Bucket: b.getBucketName(), Object: objectName})
+	}
+	if strings.TrimSpace(ifNoneMatch) != "" && exists && isETagMatch(objMetadata, ifNoneMatch, true) {
+		return probe.NewError(PreconditionFailed{Bucket: b.getBucketName(), Object: objectName})
+	}
+	return nil
+}
+
+// writeObject - write object data and metadata on all disks, caller holds the object or the bucket lock
+func (b bucket) writeObject(ctx context.Context, objectName string, objectData io.Reader, size int64, opts WriteOptions) (ObjectMetadata, *probe.Error) {
+	expectedMD5Sum, metadata, signature := opts.ExpectedMD5Sum, opts.Metadata, opts.Signature
 	if objectName == "" || objectData == nil {
 		return ObjectMetadata{}, probe.NewError(InvalidArgument{})
 	}
-	writers, err := b.getObjectWriters(normalizeObjectName(objectName), "data")
+	expectedSHA512Sum := strings.ToLower(strings.TrimSpace(opts.ExpectedSHA512Sum))
+	if expectedSHA512Sum != "" {
+		if sum, e := hex.DecodeString(expectedSHA512Sum); e != nil || len(sum) != sha512.Size {
+			return ObjectMetadata{}, probe.NewError(InvalidArgument{})
+		}
+	}
+	// crc32c is base64 encoded like the x-amz-checksum-crc32c header
+	var expectedCRC32C []byte
+	if sum := strings.TrimSpace(opts.ExpectedCRC32C); sum != "" {
+		var e error
+		if expectedCRC32C, e = base64.StdEncoding.DecodeString(sum); e != nil || len(expectedCRC32C) != crc32.Size {
+			return ObjectMetadata{}, probe.NewError(InvalidArgument{})
+		}
+	}
+	// a supplied sha512sum is trusted as is only if so configured, by default it is verified
+	trustSHA512 := expectedSHA512Sum != "" && b.trustSHA512
+	// reject names with invalid utf-8, they break listing and signature canonicalization, and names
+	// reserved for system use
+	if !IsValidObjectName(objectName) || (isReservedObjectName(objectName, b.reservedPrefixes) && !opts.uploadPart) {
+		return ObjectMetadata{}, probe.NewError(ObjectNameInvalid{Bucket: b.getBucketName(), Object: objectName})
+	}
+	objectPath := normalizeObjectName(objectName)
+	if opts.stagingPath != "" {
+		objectPath = opts.stagingPath
+	}
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	// without disks there is no bucket metadata, the write fails below
+	if bucketMetadata != nil {
+		if err := b.checkObjectMutable(bucketMetadata.Buckets[b.getBucketName()], objectName); err != nil {
+			return ObjectMetadata{}, err.Trace()
+		}
+		if !opts.skipObjectCount {
+			if err := b.checkObjectCount(bucketMetadata.Buckets[b.getBucketName()], objectName); err != nil {
+				return ObjectMetadata{}, err.Trace()
+			}
+		}
+	}
+	if b.preProvisionDirs {
+		if err := b.provisionObjectDirs(objectPath); err != nil {
+			return ObjectMetadata{}, err.Trace()
+		}
+	}
+	writers, err := b.getObjectWriters(objectPath, "data")
 	if err != nil {
 		return ObjectMetadata{}, err.Trace()
 	}
+	// nodes without any disks attached, misconfiguration
+	if len(writers) == 0 {
+		return ObjectMetadata{}, probe.NewError(NoDisksAvailable{Bucket: b.getBucketName(), Object: objectName})
+	}
 	sumMD5 := md5.New()
 	sum512 := sha512.New()
 	var sum256 hash.Hash
 	var mwriter io.Writer
 
-	if signature != nil {
+	hashWriters := []io.Writer{sumMD5}
+	if !trustSHA512 {
+		hashWriters = append(hashWriters, sum512)
+	}
+	if signature != nil || b.etagAlgorithm == etagSHA256 {
 		sum256 = sha256.New()
-		mwriter = io.MultiWriter(sumMD5, sum256, sum512)
+		hashWriters = append(hashWriters, sum256)
+	}
+	var sumCRC32C hash.Hash
+	if opts.ComputeCRC32C || expectedCRC32C != nil {
+		sumCRC32C = crc32.New(crc32.MakeTable(crc32.Castagnoli))
+		hashWriters = append(hashWriters, sumCRC32C)
+	}
+	// waitHashing returns once all the data written is hashed
+	waitHashing := func() {}
+	if b.parallelHashing {
+		pwriter := newParallelWriter(hashWriters...)
+		defer pwriter.Wait()
+		mwriter = pwriter
+		waitHashing = pwriter.Wait
 	} else {
-		mwriter = io.MultiWriter(sumMD5, sum512)
+		mwriter = io.MultiWriter(hashWriters...)
 	}
 	objMetadata := ObjectMetadata{}
 	objMetadata.Version = objectMetadataVersion
 	objMetadata.Created = time.Now().UTC()
+	switch {
 	// if total writers are only '1' do not compute erasure
-	switch len(writers) == 1 {
-	case true:
+	case len(writers) == 1:
 		mw := io.MultiWriter(writers[0], mwriter)
-		totalLength, err := io.Copy(mw, objectData)
+		totalLength, err := io.Copy(mw, contextReader{ctx: ctx, reader: objectData})
+		if err != nil {
+			CleanupWritersOnError(writers)
+			return ObjectMetadata{}, probe.NewError(err)
+		}
+		objMetadata.Size = totalLength
+	// small objects are replicated on a quorum of disks, erasure coding them is not worth it
+	case size > 0 && size <= b.smallObjectSize && !opts.uploadPart:
+		replicas := len(writers)/2 + 1
+		replicaWriters := []io.Writer{mwriter}
+		for _, writer := range writers[:replicas] {
+			replicaWriters = append(replicaWriters, writer)
+		}
+		totalLength, err := io.Copy(io.MultiWriter(replicaWriters...), contextReader{ctx: ctx, reader: objectData})
 		if err != nil {
 			CleanupWritersOnError(writers)
 			return ObjectMetadata{}, probe.NewError(err)
 		}
+		// purge writers not holding a replica
+		CleanupWritersOnError(writers[replicas:])
+		writers = writers[:replicas]
+		objMetadata.ReplicaDisks = uint8(replicas)
 		objMetadata.Size = totalLength
-	case false:
+	default:
 		// calculate data and parity dictated by total number of writers
-		k, m, err := b.getDataAndParity(len(writers))
+		k, m, err := dataAndParityHook(b, len(writers))
 		if err != nil {
 			CleanupWritersOnError(writers)
 			return ObjectMetadata{}, err.Trace()
 		}
+		if opts.ParityDisks > 0 {
+			if int(opts.ParityDisks) >= len(writers) {
+				CleanupWritersOnError(writers)
+				return ObjectMetadata{}, probe.NewError(InvalidArgument{})
+			}
+			k, m = uint8(len(writers)-int(opts.ParityDisks)), opts.ParityDisks
+			objMetadata.RequestedParityDisks = opts.ParityDisks
+		}
+		// guard against any future changes to the split logic
+		if err := checkDataAndParity(k, m, len(writers)); err != nil {
+			CleanupWritersOnError(writers)
+			return ObjectMetadata{}, err.Trace()
+		}
+		var blockHashes *treeHash
+		if b.treeHash {
+			blockHashes = new(treeHash)
+		}
+		hashes := new(sliceHashes)
+		if b.compression != "" {
+			// write compressed encoded data, checksums and size are of the uncompressed data
+			chunkSizes, totalLength, objectSize, err := b.writeCompressedObjectData(ctx, k, m, writers, objectData, size, mwriter, blockHashes, hashes)
+			if err != nil {
+				CleanupWritersOnError(writers)
+				return ObjectMetadata{}, err.Trace()
+			}
+			objMetadata.Compression = b.compression
+			objMetadata.StoredSize = int64(totalLength)
+			objMetadata.BlockSize = int(b.getBlockSize())
+			objMetadata.ChunkCount = len(chunkSizes)
+			objMetadata.ChunkSizes = getAdaptiveChunkSizes(chunkSizes, b.getBlockSize())
+			objMetadata.DataDisks = k
+			objMetadata.ParityDisks = m
+			objMetadata.Size = objectSize
+			objMetadata.TreeHash = blockHashes.getRoot()
+			objMetadata.SliceHashSidecar, objMetadata.sliceHashes = true, hashes
+			break
+		}
 		// write encoded data with k, m and writers
-		chunkCount, totalLength, err := b.writeObjectData(k, m, writers, objectData, size, mwriter)
+		chunkSizes, totalLength, err := b.writeObjectData(ctx, k, m, writers, objectData, size, mwriter, blockHashes, hashes)
 		if err != nil {
 			CleanupWritersOnError(writers)
 			return ObjectMetadata{}, err.Trace()
 		}
 		/// xlMetadata section
-		objMetadata.BlockSize = blockSize
-		objMetadata.ChunkCount = chunkCount
+		objMetadata.BlockSize = int(b.getBlockSize())
+		objMetadata.ChunkCount = len(chunkSizes)
+		objMetadata.ChunkSizes = getAdaptiveChunkSizes(chunkSizes, b.getBlockSize())
 		objMetadata.DataDisks = k
 		objMetadata.ParityDisks = m
 		objMetadata.Size = int64(totalLength)
+		objMetadata.TreeHash = blockHashes.getRoot()
+		objMetadata.SliceHashSidecar, objMetadata.sliceHashes = true, hashes
+	}
+	if objMetadata.SliceHashSidecar {
+		// slice hashes are committed along with the data slices
+		hashWriters, err := b.writeSliceHashes(objectPath, objMetadata.sliceHashes)
+		if err != nil {
+			CleanupWritersOnError(writers)
+			return ObjectMetadata{}, err.Trace()
+		}
+		writers = append(writers, hashWriters...)
 	}
 	objMetadata.Bucket = b.getBucketName()
 	objMetadata.Object = objectName
+	waitHashing()
 	dataMD5sum := sumMD5.Sum(nil)
-	dataSHA512sum := sum512.Sum(nil)
+	dataSHA512sum := hex.EncodeToString(sum512.Sum(nil))
+	if trustSHA512 {
+		dataSHA512sum = expectedSHA512Sum
+	}
 	if signature != nil {
+		// payload hash sent by the client must match the received payload
+		if !signature.DoesPayloadHashMatch(hex.EncodeToString(sum256.Sum(nil))) {
+			CleanupWritersOnError(writers)
+			return ObjectMetadata{}, probe.NewError(XAmzContentSHA256Mismatch{Bucket: b.getBucketName(), Object: objectName})
+		}
 		ok, err := signature.DoesSignatureMatch(hex.EncodeToString(sum256.Sum(nil)))
 		if err != nil {
 			// error occurred while doing signature calculation, we return and also cleanup any temporary writers.
@@ -309,71 +1620,767 @@ func (b bucket) WriteObject(objectName string, objectData io.Reader, size int64,
 			return ObjectMetadata{}, probe.NewError(SignDoesNotMatch{})
 		}
 	}
-	objMetadata.MD5Sum = hex.EncodeToString(dataMD5sum)
-	objMetadata.SHA512Sum = hex.EncodeToString(dataSHA512sum)
-
-	// Verify if the written object is equal to what is expected, only if it is requested as such
-	if strings.TrimSpace(expectedMD5Sum) != "" {
-		if err := b.isMD5SumEqual(strings.TrimSpace(expectedMD5Sum), objMetadata.MD5Sum); err != nil {
-			return ObjectMetadata{}, err.Trace()
-		}
+	objMetadata.MD5Sum = hex.EncodeToString(dataMD5sum)
+	objMetadata.SHA512Sum = dataSHA512sum
+	if sumCRC32C != nil {
+		objMetadata.CRC32C = base64.StdEncoding.EncodeToString(sumCRC32C.Sum(nil))
+	}
+	objMetadata.ETag = objMetadata.MD5Sum
+	if b.etagAlgorithm == etagSHA256 {
+		objMetadata.ETag = hex.EncodeToString(sum256.Sum(nil))
+	}
+	if opts.etag != "" {
+		objMetadata.ETag = opts.etag
+	}
+
+	// Verify if the written object is equal to what is expected, an empty expected md5sum requests no verification
+	if err := b.isMD5SumEqual(expectedMD5Sum, objMetadata.MD5Sum); err != nil {
+		CleanupWritersOnError(writers)
+		return ObjectMetadata{}, err.Trace()
+	}
+	if expectedSHA512Sum != "" && !trustSHA512 {
+		if err := isChecksumEqual(expectedSHA512Sum, objMetadata.SHA512Sum); err != nil {
+			CleanupWritersOnError(writers)
+			return ObjectMetadata{}, err.Trace()
+		}
+	}
+	if expectedCRC32C != nil {
+		if err := isChecksumBytesEqual(expectedCRC32C, sumCRC32C.Sum(nil)); err != nil {
+			CleanupWritersOnError(writers)
+			return ObjectMetadata{}, err.Trace()
+		}
+	}
+	objMetadata.Metadata, objMetadata.ContentType = normalizeMetadata(metadata)
+	objMetadata.Parts = opts.Parts
+	// commit fence: data slices and object metadata are synced under temporary names, such that an
+	// object being overwritten is left intact until both are durable. Data is then renamed into
+	// place and object metadata last, callers must add the object to the bucket index only after
+	// WriteObject returns successfully
+	if err := syncWriters(writers); err != nil {
+		return ObjectMetadata{}, probe.NewError(err)
+	}
+	if err := objectCommitHook(commitStageData); err != nil {
+		CleanupWritersOnError(writers)
+		return ObjectMetadata{}, err.Trace()
+	}
+	objMetadataWriters, err := b.stageObjectMetadata(objectPath, objMetadata)
+	if err != nil {
+		CleanupWritersOnError(writers)
+		return ObjectMetadata{}, err.Trace()
+	}
+	if err := syncWriters(objMetadataWriters); err != nil {
+		CleanupWritersOnError(writers)
+		return ObjectMetadata{}, probe.NewError(err)
+	}
+	if err := objectCommitHook(commitStageMetadata); err != nil {
+		CleanupWritersOnError(writers)
+		CleanupWritersOnError(objMetadataWriters)
+		return ObjectMetadata{}, err.Trace()
+	}
+	if err := renameWriters(writers); err != nil {
+		CleanupWritersOnError(objMetadataWriters)
+		return ObjectMetadata{}, probe.NewError(err)
+	}
+	if err := renameWriters(objMetadataWriters); err != nil {
+		return ObjectMetadata{}, probe.NewError(err)
+	}
+	// staged objects are not in place yet, the caller completes the commit
+	if opts.stagingPath != "" {
+		return objMetadata, nil
+	}
+	if err := b.removeLegacyObjectSlices(objectName); err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	b.forgetNotFound(objectName)
+	return objMetadata, nil
+}
+
+// stages of committing an object
+const (
+	commitStageData     = "data"
+	commitStageMetadata = "metadata"
+)
+
+// objectCommitHook - invoked once a stage of committing an object is durable, replaced in tests to
+// inject crashes between stages
+var objectCommitHook = func(stage string) *probe.Error {
+	return nil
+}
+
+// parallelWriter - fan out writes to each writer on its own goroutine, such that hashing the
+// same data is spread across cores. Writers must not fail, hashes never do
+type parallelWriter struct {
+	channels []chan []byte
+	wg       *sync.WaitGroup
+	once     *sync.Once
+}
+
+// newParallelWriter - instantiate a new parallel writer, at most parallelHashDepth writes are
+// queued per writer before Write blocks
+func newParallelWriter(writers ...io.Writer) *parallelWriter {
+	w := &parallelWriter{
+		wg:   new(sync.WaitGroup),
+		once: new(sync.Once),
+	}
+	for _, writer := range writers {
+		channel := make(chan []byte, parallelHashDepth)
+		w.channels = append(w.channels, channel)
+		w.wg.Add(1)
+		go func(writer io.Writer) {
+			defer w.wg.Done()
+			for p := range channel {
+				writer.Write(p)
+			}
+		}(writer)
+	}
+	return w
+}
+
+// Write - queue a copy of p to every writer, the copy is shared as writers only read it
+func (w *parallelWriter) Write(p []byte) (int, error) {
+	buf := make([]byte, len(p))
+	copy(buf, p)
+	for _, channel := range w.channels {
+		channel <- buf
+	}
+	return len(p), nil
+}
+
+// Wait - wait for all the queued writes to complete, no writes are allowed afterwards
+func (w *parallelWriter) Wait() {
+	w.once.Do(func() {
+		for _, channel := range w.channels {
+			close(channel)
+		}
+	})
+	w.wg.Wait()
+}
+
+// DeleteObject - delete an object, if ifMatch is not empty the object is deleted only if its
+// current ETag matches, verified under the same lock as the delete
+func (b bucket) DeleteObject(objectName, ifMatch string) (err *probe.Error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	defer lockMetadata(b.xlName)()
+	defer func(start time.Time) {
+		b.stats.recordRequest(b.name, operationDeleteObject, start, 0, err)
+	}(time.Now())
+	if objectName == "" {
+		return probe.NewError(InvalidArgument{})
+	}
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return err.Trace()
+	}
+	bucketObjects := bucketMetadata.Buckets[b.getBucketName()].BucketObjects
+	if _, ok := bucketObjects[objectName]; !ok {
+		return probe.NewError(ObjectNotFound{Object: objectName})
+	}
+	if err := b.checkObjectMutable(bucketMetadata.Buckets[b.getBucketName()], objectName); err != nil {
+		return err.Trace()
+	}
+	if strings.TrimSpace(ifMatch) != "" {
+		objMetadata, err := b.readObjectMetadata(b.getObjectPath(objectName))
+		if err != nil {
+			return err.Trace()
+		}
+		if !isETagMatch(objMetadata, ifMatch, false) {
+			return probe.NewError(PreconditionFailed{Bucket: b.getBucketName(), Object: objectName})
+		}
+	}
+	size, err := b.indexedObjectSize(bucketMetadata.Buckets[b.getBucketName()], objectName)
+	if err != nil {
+		return err.Trace()
+	}
+	// bucket index is updated first, such that the object is not visible while its slices are removed
+	delete(bucketObjects, objectName)
+	bucketMetadata.Buckets[b.getBucketName()] = advanceGeneration(addUsage(bucketMetadata.Buckets[b.getBucketName()], -size))
+	if err := b.saveBucketMetadata(bucketMetadata); err != nil {
+		return err.Trace()
+	}
+	return b.removeObjectSlices(b.getObjectPath(objectName), "", nil).Trace()
+}
+
+// IndexGeneration - current generation of the bucket index, to be passed to CommitObjects
+func (b bucket) IndexGeneration() (uint64, *probe.Error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return 0, err.Trace()
+	}
+	return bucketMetadata.Buckets[b.getBucketName()].Generation, nil
+}
+
+// CommitObjects - add objects written with WriteObject to the bucket index and remove objects from it
+// in a single index write, only if the index is still at the expected generation. Returns the new
+// generation, slices of removed objects are removed once the index is updated
+func (b bucket) CommitObjects(expectedGeneration uint64, additions, removals []string) (uint64, *probe.Error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	defer lockMetadata(b.xlName)()
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return 0, err.Trace()
+	}
+	metadata := bucketMetadata.Buckets[b.getBucketName()]
+	if metadata.Generation != expectedGeneration {
+		return 0, probe.NewError(PreconditionFailed{Bucket: b.getBucketName()})
+	}
+	removed := make(map[string]struct{})
+	var addedSize, releasedSize int64
+	for _, objectName := range removals {
+		if _, ok := metadata.BucketObjects[objectName]; !ok {
+			return 0, probe.NewError(ObjectNotFound{Object: objectName})
+		}
+		if err := b.checkObjectMutable(metadata, objectName); err != nil {
+			return 0, err.Trace()
+		}
+		removed[objectName] = struct{}{}
+		size, err := b.indexedObjectSize(metadata, objectName)
+		if err != nil {
+			return 0, err.Trace()
+		}
+		releasedSize += size
+	}
+	added := make(map[string]ObjectMetadata)
+	for _, objectName := range additions {
+		if _, ok := removed[objectName]; ok {
+			return 0, probe.NewError(InvalidArgument{})
+		}
+		objMetadata, err := b.readObjectMetadata(b.getObjectPath(objectName))
+		if err != nil {
+			return 0, err.Trace()
+		}
+		added[objectName] = objMetadata
+		// objects in the index were overwritten in place, their size is taken to be unchanged
+		if _, ok := metadata.BucketObjects[objectName]; !ok {
+			addedSize += objMetadata.Size
+		}
+	}
+	if err := b.checkQuota(metadata, addedSize, releasedSize); err != nil {
+		return 0, err.Trace()
+	}
+	for objectName := range removed {
+		delete(metadata.BucketObjects, objectName)
+	}
+	for _, objectName := range additions {
+		metadata.BucketObjects[objectName] = struct{}{}
+		metadata = recordObjectChange(metadata, objectName, added[objectName].Created)
+	}
+	metadata = addUsage(metadata, addedSize-releasedSize)
+	metadata = advanceGeneration(metadata)
+	bucketMetadata.Buckets[b.getBucketName()] = metadata
+	if err := b.saveBucketMetadata(bucketMetadata); err != nil {
+		return 0, err.Trace()
+	}
+	for _, objectName := range additions {
+		b.forgetNotFound(objectName)
+	}
+	for objectName := range removed {
+		if err := b.removeObjectSlices(b.getObjectPath(objectName), "", nil); err != nil {
+			return metadata.Generation, err.Trace()
+		}
+	}
+	return metadata.Generation, nil
+}
+
+// DeleteObjects - delete multiple objects, objects failing to delete do not stop the others from
+// being deleted and are reported in an AggregateError
+func (b bucket) DeleteObjects(objectNames []string) *probe.Error {
+	errs := make(map[string]error)
+	for _, objectName := range objectNames {
+		if err := b.DeleteObject(objectName, ""); err != nil {
+			errs[objectName] = err.ToGoError()
+		}
+	}
+	if len(errs) > 0 {
+		return probe.NewError(AggregateError{Errors: errs})
+	}
+	return nil
+}
+
+// TruncateObject - truncate an object to newSize bytes without re-uploading it, the retained data is
+// re-encoded with checksums recomputed and slices no longer holding data are removed
+func (b bucket) TruncateObject(objectName string, newSize int64) (ObjectMetadata, *probe.Error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	defer lockMetadata(b.xlName)()
+	if objectName == "" || newSize < 0 {
+		return ObjectMetadata{}, probe.NewError(InvalidArgument{})
+	}
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	if _, ok := bucketMetadata.Buckets[b.getBucketName()].BucketObjects[objectName]; !ok {
+		return ObjectMetadata{}, probe.NewError(ObjectNotFound{Object: objectName})
+	}
+	objectPath := b.getObjectPath(objectName)
+	objMetadata, err := b.readObjectMetadata(objectPath)
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	if newSize > objMetadata.Size {
+		return ObjectMetadata{}, probe.NewError(InvalidRange{Start: 0, Length: newSize})
+	}
+	if newSize == objMetadata.Size {
+		return objMetadata, nil
+	}
+	reader, writer := io.Pipe()
+	// closing the reader stops reading the data beyond newSize
+	defer reader.Close()
+	go b.readObjectData(context.Background(), objectPath, writer, objMetadata, nil)
+	newMetadata, err := b.writeObject(context.Background(), objectName, io.LimitReader(reader, newSize), newSize, WriteOptions{Metadata: objMetadata.Metadata})
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	if newMetadata.Size != newSize {
+		return ObjectMetadata{}, probe.NewError(InvalidRange{Start: 0, Length: newSize})
+	}
+	if newMetadata.ReplicaDisks > 0 {
+		// slices on disks not holding a replica are stale
+		if err := b.removeObjectSlices(normalizeObjectName(objectName), "data", b.replicaDiskOrders(int(newMetadata.ReplicaDisks))); err != nil {
+			return ObjectMetadata{}, err.Trace()
+		}
+	}
+	metadata := addUsage(bucketMetadata.Buckets[b.getBucketName()], newMetadata.Size-objMetadata.Size)
+	bucketMetadata.Buckets[b.getBucketName()] = recordObjectChange(metadata, objectName, newMetadata.Created)
+	if err := b.saveBucketMetadata(bucketMetadata); err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	return newMetadata, nil
+}
+
+// removeObjectSlices - remove object slices on all disks but the disks to keep, which are keyed by
+// node and order such that disks of the same order on other nodes are not kept
+func (b bucket) removeObjectSlices(objectName, objectMeta string, keep map[DiskOrder]struct{}) *probe.Error {
+	nodeSlice := 0
+	for _, node := range b.getNodes() {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return err.Trace()
+		}
+		for order, disk := range disks {
+			if _, ok := keep[DiskOrder{Node: node.GetHostname(), Disk: order}]; ok {
+				continue
+			}
+			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, order)
+			if err := disk.RemoveAll(filepath.Join(b.xlName, bucketSlice, objectName, objectMeta)); err != nil {
+				return err.Trace()
+			}
+		}
+		nodeSlice = nodeSlice + 1
+	}
+	return nil
+}
+
+// replicaDiskOrders - disks holding the replicas of a replicated object, replicas are written to the
+// lowest orders of the disks of the last node, see getObjectWriters()
+func (b bucket) replicaDiskOrders(replicas int) map[DiskOrder]struct{} {
+	diskOrders := make(map[DiskOrder]struct{})
+	nodes := b.getNodes()
+	if len(nodes) == 0 {
+		return diskOrders
+	}
+	last := nodes[len(nodes)-1]
+	for order := 0; order < replicas; order++ {
+		diskOrders[DiskOrder{Node: last.GetHostname(), Disk: order}] = struct{}{}
+	}
+	return diskOrders
+}
+
+// RenameObject - rename an object in place without re-writing its data, if newName already
+// exists it is overwritten only if requested
+func (b bucket) RenameObject(oldName, newName string, overwrite bool) (ObjectMetadata, *probe.Error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	defer lockMetadata(b.xlName)()
+	if oldName == "" || newName == "" {
+		return ObjectMetadata{}, probe.NewError(InvalidArgument{})
+	}
+	if !IsValidObjectName(newName) || isReservedObjectName(newName, b.reservedPrefixes) {
+		return ObjectMetadata{}, probe.NewError(ObjectNameInvalid{Bucket: b.getBucketName(), Object: newName})
+	}
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	bucketObjects := bucketMetadata.Buckets[b.getBucketName()].BucketObjects
+	if _, ok := bucketObjects[oldName]; !ok {
+		return ObjectMetadata{}, probe.NewError(ObjectNotFound{Object: oldName})
+	}
+	if oldName == newName {
+		return b.readObjectMetadata(b.getObjectPath(oldName))
+	}
+	if _, ok := bucketObjects[newName]; ok && !overwrite {
+		return ObjectMetadata{}, probe.NewError(ObjectExists{Object: newName})
+	}
+	// renaming removes the object under its old name and overwrites any object under the new name
+	for _, objectName := range []string{oldName, newName} {
+		if err := b.checkObjectMutable(bucketMetadata.Buckets[b.getBucketName()], objectName); err != nil {
+			return ObjectMetadata{}, err.Trace()
+		}
+	}
+	oldPath := b.getObjectPath(oldName)
+	objMetadata, err := b.readObjectMetadata(oldPath)
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	replacedSize, err := b.indexedObjectSize(bucketMetadata.Buckets[b.getBucketName()], newName)
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	if oldPath != normalizeObjectName(newName) {
+		if err := b.renameObjectSlices(oldPath, normalizeObjectName(newName)); err != nil {
+			return ObjectMetadata{}, err.Trace()
+		}
+	}
+	objMetadata.Object = newName
+	if err := b.writeObjectMetadata(normalizeObjectName(newName), objMetadata); err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	if err := b.removeLegacyObjectSlices(newName); err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	// bucket index is updated last, in a single write
+	delete(bucketObjects, oldName)
+	bucketObjects[newName] = struct{}{}
+	metadata := addUsage(bucketMetadata.Buckets[b.getBucketName()], -replacedSize)
+	bucketMetadata.Buckets[b.getBucketName()] = advanceGeneration(recordObjectChange(metadata, newName, time.Now().UTC()))
+	if err := b.saveBucketMetadata(bucketMetadata); err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	b.forgetNotFound(newName)
+	return objMetadata, nil
+}
+
+// renameObjectSlices - rename object slices on all disks, slices already under the new name are set
+// aside until every disk is renamed and removed only then, on error renamed and set aside slices are
+// restored
+func (b bucket) renameObjectSlices(oldName, newName string) *probe.Error {
+	type renamedSlice struct {
+		disk                        block.Block
+		oldPath, newPath, asidePath string
+	}
+	var renamed []renamedSlice
+	restore := func() {
+		for _, slice := range renamed {
+			slice.disk.Rename(slice.newPath, slice.oldPath)
+			if slice.asidePath != "" {
+				slice.disk.Rename(slice.asidePath, slice.newPath)
+			}
+		}
+	}
+	asideName := fmt.Sprintf("%s$%d", newName, rand.Int63())
+	nodeSlice := 0
+	for _, node := range b.getNodes() {
+		disks, err := node.ListDisks()
+		if err != nil {
+			restore()
+			return err.Trace()
+		}
+		for order, disk := range disks {
+			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, order)
+			slice := renamedSlice{
+				disk:      disk,
+				oldPath:   filepath.Join(b.xlName, bucketSlice, oldName),
+				newPath:   filepath.Join(b.xlName, bucketSlice, newName),
+				asidePath: filepath.Join(b.xlName, bucketSlice, asideName),
+			}
+			if err := disk.Rename(slice.newPath, slice.asidePath); err != nil {
+				if !os.IsNotExist(err.ToGoError()) {
+					restore()
+					return err.Trace()
+				}
+				slice.asidePath = ""
+			}
+			if err := disk.Rename(slice.oldPath, slice.newPath); err != nil {
+				if slice.asidePath != "" {
+					disk.Rename(slice.asidePath, slice.newPath)
+				}
+				restore()
+				return err.Trace()
+			}
+			renamed = append(renamed, slice)
+		}
+		nodeSlice = nodeSlice + 1
+	}
+	for _, slice := range renamed {
+		if slice.asidePath != "" {
+			slice.disk.RemoveAll(slice.asidePath)
+		}
+	}
+	return nil
+}
+
+// CopyObject - copy an object without re-encoding its data, slices are copied to the destination on
+// every disk and checksums, size and erasure coding of the source are preserved. Metadata of the source
+// is kept unless metadata is given, copying an object onto itself is only allowed to replace metadata
+func (b bucket) CopyObject(srcObject, dstObject string, metadata map[string]string) (ObjectMetadata, *probe.Error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	defer lockMetadata(b.xlName)()
+	if srcObject == "" || dstObject == "" {
+		return ObjectMetadata{}, probe.NewError(InvalidArgument{})
+	}
+	if !IsValidObjectName(dstObject) || isReservedObjectName(dstObject, b.reservedPrefixes) {
+		return ObjectMetadata{}, probe.NewError(ObjectNameInvalid{Bucket: b.getBucketName(), Object: dstObject})
+	}
+	if srcObject == dstObject && metadata == nil {
+		return ObjectMetadata{}, probe.NewError(InvalidCopyDest{Object: dstObject})
+	}
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	bucketObjects := bucketMetadata.Buckets[b.getBucketName()].BucketObjects
+	if _, ok := bucketObjects[srcObject]; !ok {
+		return ObjectMetadata{}, probe.NewError(ObjectNotFound{Object: srcObject})
+	}
+	if err := b.checkObjectMutable(bucketMetadata.Buckets[b.getBucketName()], dstObject); err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	srcPath := b.getObjectPath(srcObject)
+	objMetadata, err := b.readObjectMetadata(srcPath)
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	replacedSize, err := b.indexedObjectSize(bucketMetadata.Buckets[b.getBucketName()], dstObject)
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	if err := b.checkQuota(bucketMetadata.Buckets[b.getBucketName()], objMetadata.Size, replacedSize); err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	objMetadata.Object = dstObject
+	objMetadata.Created = time.Now().UTC()
+	objMetadata.MetadataModified = time.Time{}
+	if metadata != nil {
+		objMetadata.Metadata, objMetadata.ContentType = normalizeMetadata(metadata)
+	}
+	if srcPath == normalizeObjectName(dstObject) {
+		if err := b.writeObjectMetadata(srcPath, objMetadata); err != nil {
+			return ObjectMetadata{}, err.Trace()
+		}
+	} else if err := b.copyObjectSlices(srcPath, normalizeObjectName(dstObject), objMetadata); err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	if err := b.removeLegacyObjectSlices(dstObject); err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	// bucket index is updated last, in a single write
+	bucketObjects[dstObject] = struct{}{}
+	dstMetadata := addUsage(bucketMetadata.Buckets[b.getBucketName()], objMetadata.Size-replacedSize)
+	bucketMetadata.Buckets[b.getBucketName()] = advanceGeneration(recordObjectChange(dstMetadata, dstObject, objMetadata.Created))
+	if err := b.saveBucketMetadata(bucketMetadata); err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	b.forgetNotFound(dstObject)
+	return objMetadata, nil
+}
+
+// copyObjectSlices - copy data, slice hashes and tags of an object on all disks into a staging object
+// along with objMetadata, the staging object is renamed into place only once complete. Disks missing
+// a source slice do not keep a stale destination slice either
+func (b bucket) copyObjectSlices(srcObject, dstObject string, objMetadata ObjectMetadata) *probe.Error {
+	stagingName := fmt.Sprintf("%s$%d", dstObject, rand.Int63())
+	var writers []io.WriteCloser
+	nodeSlice := 0
+	for _, node := range b.getNodes() {
+		disks, err := node.ListDisks()
+		if err != nil {
+			CleanupWritersOnError(writers)
+			return err.Trace()
+		}
+		for order, disk := range disks {
+			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, order)
+			for _, objectMeta := range []string{"data", sliceHashesConfig, objectTagsConfig} {
+				reader, err := disk.Open(filepath.Join(b.xlName, bucketSlice, srcObject, objectMeta))
+				if err != nil {
+					continue
+				}
+				writer, err := disk.CreateFile(filepath.Join(b.xlName, bucketSlice, stagingName, objectMeta))
+				if err != nil {
+					reader.Close()
+					CleanupWritersOnError(writers)
+					return err.Trace()
+				}
+				writers = append(writers, writer)
+				_, e := io.Copy(writer, reader)
+				reader.Close()
+				if e != nil {
+					CleanupWritersOnError(writers)
+					return probe.NewError(e)
+				}
+			}
+		}
+		nodeSlice = nodeSlice + 1
+	}
+	if err := commitWriters(writers); err != nil {
+		b.removeObjectSlices(stagingName, "", nil)
+		return probe.NewError(err)
+	}
+	if err := b.writeObjectMetadata(stagingName, objMetadata); err != nil {
+		b.removeObjectSlices(stagingName, "", nil)
+		return err.Trace()
+	}
+	// the destination is replaced as a whole, it is restored if renaming fails on any disk
+	if err := b.renameObjectSlices(stagingName, dstObject); err != nil {
+		b.removeObjectSlices(stagingName, "", nil)
+		return err.Trace()
+	}
+	return nil
+}
+
+// isMD5SumEqual - returns error if md5sum mismatches, other its `nil`, see isMD5SumEqual
+func (b bucket) isMD5SumEqual(expectedMD5Sum, actualMD5Sum string) *probe.Error {
+	return isMD5SumEqual(expectedMD5Sum, actualMD5Sum)
+}
+
+// writeObjectMetadata - write additional object metadata
+func (b bucket) writeObjectMetadata(objectName string, objMetadata ObjectMetadata) *probe.Error {
+	objMetadataWriters, err := b.stageObjectMetadata(objectName, objMetadata)
+	if err != nil {
+		return err.Trace()
+	}
+	if err := commitWriters(objMetadataWriters); err != nil {
+		return probe.NewError(err)
+	}
+	return nil
+}
+
+// stageObjectMetadata - write object metadata under temporary names on all disks, returns the
+// writers to commit it with
+func (b bucket) stageObjectMetadata(objectName string, objMetadata ObjectMetadata) ([]io.WriteCloser, *probe.Error) {
+	if objMetadata.Object == "" {
+		return nil, probe.NewError(InvalidArgument{})
+	}
+	objMetadataWriters, err := b.getObjectWriters(objectName, objectMetadataConfig)
+	if err != nil {
+		return nil, err.Trace()
+	}
+	for _, objMetadataWriter := range objMetadataWriters {
+		jenc := json.NewEncoder(objMetadataWriter)
+		if err := jenc.Encode(&objMetadata); err != nil {
+			// Close writers and purge all temporary entries
+			CleanupWritersOnError(objMetadataWriters)
+			return nil, probe.NewError(err)
+		}
+	}
+	return objMetadataWriters, nil
+}
+
+// UpdateObjectMetadata - replace user metadata of an object without re-writing its data, the object
+// is considered modified at the time of the update while its data and ETag are unchanged
+func (b bucket) UpdateObjectMetadata(objectName string, metadata map[string]string) (ObjectMetadata, *probe.Error) {
+	defer b.lockObject(objectName)()
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	if _, ok := bucketMetadata.Buckets[b.getBucketName()].BucketObjects[objectName]; !ok {
+		return ObjectMetadata{}, probe.NewError(ObjectNotFound{Object: objectName})
 	}
-	objMetadata.Metadata = metadata
-	// write object specific metadata
-	if err := b.writeObjectMetadata(normalizeObjectName(objectName), objMetadata); err != nil {
-		// purge all writers, when control flow reaches here
-		CleanupWritersOnError(writers)
+	objectPath := b.getObjectPath(objectName)
+	objMetadata, err := b.readObjectMetadata(objectPath)
+	if err != nil {
 		return ObjectMetadata{}, err.Trace()
 	}
-	// close all writers, when control flow reaches here
-	for _, writer := range writers {
-		writer.Close()
+	objMetadata.Metadata, objMetadata.ContentType = normalizeMetadata(metadata)
+	objMetadata.MetadataModified = time.Now().UTC()
+	if err := b.writeObjectMetadata(objectPath, objMetadata); err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	// the update is listed as a change of the object at its new last modified time
+	defer lockMetadata(b.xlName)()
+	bucketMetadata, err = b.getBucketMetadata()
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	if _, ok := bucketMetadata.Buckets[b.getBucketName()].BucketObjects[objectName]; ok {
+		bucketMetadata.Buckets[b.getBucketName()] = recordObjectChange(bucketMetadata.Buckets[b.getBucketName()], objectName, objMetadata.LastModified())
+		if err := b.saveBucketMetadata(bucketMetadata); err != nil {
+			return ObjectMetadata{}, err.Trace()
+		}
 	}
 	return objMetadata, nil
 }
 
-// isMD5SumEqual - returns error if md5sum mismatches, other its `nil`
-func (b bucket) isMD5SumEqual(expectedMD5Sum, actualMD5Sum string) *probe.Error {
-	if strings.TrimSpace(expectedMD5Sum) != "" && strings.TrimSpace(actualMD5Sum) != "" {
-		expectedMD5SumBytes, err := hex.DecodeString(expectedMD5Sum)
-		if err != nil {
-			return probe.NewError(err)
-		}
-		actualMD5SumBytes, err := hex.DecodeString(actualMD5Sum)
-		if err != nil {
-			return probe.NewError(err)
-		}
-		if !bytes.Equal(expectedMD5SumBytes, actualMD5SumBytes) {
-			return probe.NewError(BadDigest{})
+// GetObjectPartMetadata - ETag, size and offset of a part of an object written by a multipart upload
+func (b bucket) GetObjectPartMetadata(objectName string, partNumber int) (PartMetadata, *probe.Error) {
+	defer b.rlockObject(objectName)()
+	objMetadata, err := b.readObjectMetadata(b.getObjectPath(objectName))
+	if err != nil {
+		return PartMetadata{}, err.Trace()
+	}
+	return objMetadata.getPart(partNumber)
+}
+
+// getPart - part of an object by its number, objects not written by a multipart upload have no parts
+func (o ObjectMetadata) getPart(partNumber int) (PartMetadata, *probe.Error) {
+	for _, part := range o.Parts {
+		if part.PartNumber == partNumber {
+			return part, nil
 		}
-		return nil
 	}
-	return probe.NewError(InvalidArgument{})
+	return PartMetadata{}, probe.NewError(InvalidPart{})
 }
 
-// writeObjectMetadata - write additional object metadata
-func (b bucket) writeObjectMetadata(objectName string, objMetadata ObjectMetadata) *probe.Error {
-	if objMetadata.Object == "" {
-		return probe.NewError(InvalidArgument{})
+// SetObjectTags - set tags of an object, replaces any previous tags
+func (b bucket) SetObjectTags(objectName string, tags map[string]string) *probe.Error {
+	defer b.lockObject(objectName)()
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return err.Trace()
 	}
-	objMetadataWriters, err := b.getObjectWriters(objectName, objectMetadataConfig)
+	if _, ok := bucketMetadata.Buckets[b.getBucketName()].BucketObjects[objectName]; !ok {
+		return probe.NewError(ObjectNotFound{Object: objectName})
+	}
+	writers, err := b.getObjectWriters(b.getObjectPath(objectName), objectTagsConfig)
 	if err != nil {
 		return err.Trace()
 	}
-	for _, objMetadataWriter := range objMetadataWriters {
-		jenc := json.NewEncoder(objMetadataWriter)
-		if err := jenc.Encode(&objMetadata); err != nil {
-			// Close writers and purge all temporary entries
-			CleanupWritersOnError(objMetadataWriters)
+	for _, writer := range writers {
+		if err := json.NewEncoder(writer).Encode(tags); err != nil {
+			CleanupWritersOnError(writers)
 			return probe.NewError(err)
 		}
 	}
-	for _, objMetadataWriter := range objMetadataWriters {
-		objMetadataWriter.Close()
+	for _, writer := range writers {
+		writer.Close()
 	}
 	return nil
 }
 
+// GetObjectTags - get tags of an object
+func (b bucket) GetObjectTags(objectName string) (map[string]string, *probe.Error) {
+	defer b.rlockObject(objectName)()
+	return b.readObjectTags(b.getObjectPath(objectName))
+}
+
+// readObjectTags - read object tags, objects never tagged have no tags
+func (b bucket) readObjectTags(objectName string) (map[string]string, *probe.Error) {
+	readers, err := b.getObjectReaders(objectName, objectTagsConfig)
+	if err != nil {
+		if os.IsNotExist(err.ToGoError()) {
+			return map[string]string{}, nil
+		}
+		return nil, err.Trace()
+	}
+	for _, reader := range readers {
+		defer reader.Close()
+	}
+	var e error
+	for _, reader := range readers {
+		tags := make(map[string]string)
+		if e = json.NewDecoder(reader).Decode(&tags); e == nil {
+			return tags, nil
+		}
+	}
+	return nil, probe.NewError(e)
+}
+
 // readObjectMetadata - read object metadata
 func (b bucket) readObjectMetadata(objectName string) (ObjectMetadata, *probe.Error) {
 	if objectName == "" {
@@ -392,6 +2399,13 @@ func (b bucket) readObjectMetadata(objectName string) (ObjectMetadata, *probe.Er
 		for _, objMetadataReader := range objMetadataReaders {
 			jdec := json.NewDecoder(objMetadataReader)
 			if err = jdec.Decode(&objMetadata); err == nil {
+				// objects written before the content type was recorded
+				if objMetadata.ContentType == "" {
+					_, objMetadata.ContentType = normalizeMetadata(objMetadata.Metadata)
+				}
+				if objMetadata.SliceHashSidecar {
+					objMetadata.sliceHashes = b.newSliceHashLoader(objectName, objMetadata)
+				}
 				return objMetadata, nil
 			}
 		}
@@ -399,19 +2413,140 @@ func (b bucket) readObjectMetadata(objectName string) (ObjectMetadata, *probe.Er
 	}
 }
 
-// TODO - This a temporary normalization of objectNames, need to find a better way
-//
-// normalizedObjectName - all objectNames with "/" get normalized to a simple objectName
+// normalizeObjectName - name the slices of an object are stored under on disk, a single path
+// component in which '%', '/' and '-' are percent-encoded such that distinct object names never
+// collide
 //
 // example:
-// user provided value - "this/is/my/deep/directory/structure"
-// xl normalized value - "this-is-my-deep-directory-structure"
+// user provided value - "this/is/my-deep/directory"
+// xl normalized value - "this%2Fis%2Fmy%2Ddeep%2Fdirectory"
 //
 func normalizeObjectName(objectName string) string {
-	// replace every '/' with '-'
+	return objectNameEncoder.Replace(objectName)
+}
+
+var objectNameEncoder = strings.NewReplacer("%", "%25", "/", "%2F", "-", "%2D")
+
+// denormalizeObjectName - object name from the name its slices are stored under on disk
+func denormalizeObjectName(objectPath string) string {
+	return objectNameDecoder.Replace(objectPath)
+}
+
+var objectNameDecoder = strings.NewReplacer("%25", "%", "%2F", "/", "%2D", "-")
+
+// legacyObjectName - name objects were stored under before names were encoded, every '/' replaced
+// with '-' such that "a/b" and "a-b" collided
+func legacyObjectName(objectName string) string {
 	return strings.Replace(objectName, "/", "-", -1)
 }
 
+// getObjectPath - name the slices of an existing object are found under on disk, objects written
+// before names were encoded are still found under their legacy name
+func (b bucket) getObjectPath(objectName string) string {
+	objectPath := normalizeObjectName(objectName)
+	legacyPath := legacyObjectName(objectName)
+	if legacyPath == objectPath || b.isNamesEncoded() || b.hasObjectMetadata(objectPath) || !b.isLegacyObject(objectName) {
+		return objectPath
+	}
+	return legacyPath
+}
+
+// namesEncoded - whether all objects of a bucket are stored under their encoded name, shared by all
+// copies of the bucket
+type namesEncoded struct {
+	lock    *sync.RWMutex
+	encoded bool
+}
+
+// isNamesEncoded - all objects of the bucket are known to be stored under their encoded name
+func (b bucket) isNamesEncoded() bool {
+	if b.namesEncoded == nil {
+		return false
+	}
+	b.namesEncoded.lock.RLock()
+	defer b.namesEncoded.lock.RUnlock()
+	return b.namesEncoded.encoded
+}
+
+// setNamesEncoded - no longer look up objects under their legacy name
+func (b bucket) setNamesEncoded(encoded bool) {
+	b.namesEncoded.lock.Lock()
+	defer b.namesEncoded.lock.Unlock()
+	b.namesEncoded.encoded = encoded
+}
+
+// migrateObjectNames - move the slices of objects still stored under their legacy name to their
+// encoded name, once for every bucket. Buckets created since names are encoded have none
+func (b bucket) migrateObjectNames() *probe.Error {
+	defer lockMetadata(b.xlName)()
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return err.Trace()
+	}
+	metadata, ok := bucketMetadata.Buckets[b.getBucketName()]
+	if !ok {
+		return probe.NewError(BucketNotFound{Bucket: b.getBucketName()})
+	}
+	if !metadata.NamesEncoded {
+		for objectName := range metadata.BucketObjects {
+			objectPath := normalizeObjectName(objectName)
+			if legacyObjectName(objectName) == objectPath || b.hasObjectMetadata(objectPath) || !b.isLegacyObject(objectName) {
+				continue
+			}
+			if err := b.renameObjectSlices(legacyObjectName(objectName), objectPath); err != nil {
+				return err.Trace()
+			}
+		}
+		metadata.NamesEncoded = true
+		bucketMetadata.Buckets[b.getBucketName()] = metadata
+		if err := b.saveBucketMetadata(bucketMetadata); err != nil {
+			return err.Trace()
+		}
+	}
+	b.setNamesEncoded(true)
+	return nil
+}
+
+// hasObjectMetadata - object metadata exists on any disk under the given on disk name
+func (b bucket) hasObjectMetadata(objectPath string) bool {
+	readers, err := b.getObjectReaders(objectPath, objectMetadataConfig)
+	if err != nil {
+		return false
+	}
+	for _, reader := range readers {
+		reader.Close()
+	}
+	return len(readers) > 0
+}
+
+// isLegacyObject - object is stored under its legacy name, legacy names shared by several objects
+// belong to the one whose metadata is stored there
+func (b bucket) isLegacyObject(objectName string) bool {
+	readers, err := b.getObjectReaders(legacyObjectName(objectName), objectMetadataConfig)
+	if err != nil {
+		return false
+	}
+	for _, reader := range readers {
+		defer reader.Close()
+	}
+	for _, reader := range readers {
+		var objMetadata ObjectMetadata
+		if e := json.NewDecoder(reader).Decode(&objMetadata); e == nil {
+			return objMetadata.Object == objectName
+		}
+	}
+	return false
+}
+
+// removeLegacyObjectSlices - remove the slices of an object stored under its legacy name, once the
+// object is written again under its encoded name
+func (b bucket) removeLegacyObjectSlices(objectName string) *probe.Error {
+	if legacyObjectName(objectName) == normalizeObjectName(objectName) || b.isNamesEncoded() || !b.isLegacyObject(objectName) {
+		return nil
+	}
+	return b.removeObjectSlices(legacyObjectName(objectName), "", nil).Trace()
+}
+
 // getDataAndParity - calculate k, m (data and parity) values from number of disks
 func (b bucket) getDataAndParity(totalWriters int) (k uint8, m uint8, err *probe.Error) {
 	if totalWriters <= 1 {
@@ -428,58 +2563,233 @@ func (b bucket) getDataAndParity(totalWriters int) (k uint8, m uint8, err *probe
 	return k, m, nil
 }
 
-// writeObjectData -
-func (b bucket) writeObjectData(k, m uint8, writers []io.WriteCloser, objectData io.Reader, size int64, hashWriter io.Writer) (int, int, *probe.Error) {
+// dataAndParityHook - calculates k, m (data and parity) of objects written, replaced in tests to
+// verify writes are guarded against k, m not adding up to the number of disks
+var dataAndParityHook = bucket.getDataAndParity
+
+// checkDataAndParity - verify k, m (data and parity) add up to number of disks
+func checkDataAndParity(k, m uint8, totalWriters int) *probe.Error {
+	if int(k)+int(m) != totalWriters {
+		return probe.NewError(DataParityMismatch{K: k, M: m, Writers: totalWriters})
+	}
+	return nil
+}
+
+// writeObjectData - encode and write chunks of objectData, ctx is checked before every chunk
+func (b bucket) writeObjectData(ctx context.Context, k, m uint8, writers []io.WriteCloser, objectData io.Reader, size int64, hashWriter io.Writer, blockHashes *treeHash, hashes *sliceHashes) ([]int64, int, *probe.Error) {
 	encoder, err := newEncoder(k, m)
 	if err != nil {
-		return 0, 0, err.Trace()
+		return nil, 0, err.Trace()
+	}
+	maxChunkSize := b.getBlockSize()
+	chunkSize := maxChunkSize
+	if size < 0 && maxChunkSize > minAdaptiveBlockSize {
+		// size is unknown, small streams should not waste a full block
+		chunkSize = minAdaptiveBlockSize
 	}
-	chunkSize := int64(10 * 1024 * 1024)
-	chunkCount := 0
+	var chunkSizes []int64
 	totalLength := 0
 
+	// batch encoded blocks per disk into fewer writes, the stream written to a disk is unchanged
+	shardWriters := make([]io.Writer, len(writers))
+	var batchWriters []*bufio.Writer
+	for i, writer := range writers {
+		shardWriters[i] = writer
+		if b.batchShardWrites {
+			batchWriter := bufio.NewWriterSize(writer, shardBatchSize)
+			batchWriters = append(batchWriters, batchWriter)
+			shardWriters[i] = batchWriter
+		}
+	}
+
 	var e error
 	for e == nil {
+		// client went away, caller purges the writers
+		if err := ctx.Err(); err != nil {
+			return nil, 0, probe.NewError(err)
+		}
 		var length int
-		inputData := make([]byte, chunkSize)
-		length, e = objectData.Read(inputData)
+		buffer := getChunkBuffer(chunkSize)
+		inputData := *buffer
+		// chunks are always read in full, reads decode them by block size
+		length, e = io.ReadFull(objectData, inputData)
+		if e == io.ErrUnexpectedEOF {
+			e = io.EOF
+		}
+		if length == 0 {
+			putChunkBuffer(buffer)
+		}
 		if length != 0 {
 			encodedBlocks, err := encoder.Encode(inputData[0:length])
 			if err != nil {
-				return 0, 0, err.Trace()
+				putChunkBuffer(buffer)
+				return nil, 0, err.Trace()
 			}
 			if _, err := hashWriter.Write(inputData[0:length]); err != nil {
-				return 0, 0, probe.NewError(err)
+				putChunkBuffer(buffer)
+				return nil, 0, probe.NewError(err)
 			}
+			blockHashes.addBlock(inputData[0:length])
+			hashes.addChunk(encodedBlocks)
+			// blocks of a chunk are written to all disks at once, all writes complete before the
+			// next chunk such that a failed chunk is never followed by another one
+			var wg sync.WaitGroup
+			errs := make([]error, len(encodedBlocks))
 			for blockIndex, block := range encodedBlocks {
-				errCh := make(chan error, 1)
-				go func(writer io.Writer, reader io.Reader, errCh chan<- error) {
-					defer close(errCh)
-					_, err := io.Copy(writer, reader)
-					errCh <- err
-				}(writers[blockIndex], bytes.NewReader(block), errCh)
-				if err := <-errCh; err != nil {
+				wg.Add(1)
+				go func(blockIndex int, writer io.Writer, reader io.Reader) {
+					defer wg.Done()
+					_, errs[blockIndex] = io.Copy(writer, reader)
+				}(blockIndex, shardWriters[blockIndex], bytes.NewReader(block))
+			}
+			wg.Wait()
+			// data blocks are slices of the chunk buffer, it is recycled only once they are written
+			putChunkBuffer(buffer)
+			for _, err := range errs {
+				if err != nil {
 					// Returning error is fine here CleanupErrors() would cleanup writers
-					return 0, 0, probe.NewError(err)
+					return nil, 0, probe.NewError(err)
 				}
 			}
 			totalLength += length
-			chunkCount = chunkCount + 1
+			chunkSizes = append(chunkSizes, int64(length))
+			if chunkSize < maxChunkSize {
+				chunkSize = chunkSize * 2
+				if chunkSize > maxChunkSize {
+					chunkSize = maxChunkSize
+				}
+			}
 		}
 	}
 	if e != io.EOF {
-		return 0, 0, probe.NewError(e)
+		return nil, 0, probe.NewError(e)
+	}
+	for _, batchWriter := range batchWriters {
+		if err := batchWriter.Flush(); err != nil {
+			return nil, 0, probe.NewError(err)
+		}
+	}
+	return chunkSizes, totalLength, nil
+}
+
+// chunkBuffers - pool of buffers chunks are read into by writeObjectData
+var chunkBuffers = sync.Pool{}
+
+// getChunkBuffer - buffer of size bytes from the pool, allocated if none of at least size is pooled
+func getChunkBuffer(size int64) *[]byte {
+	if buffer, ok := chunkBuffers.Get().(*[]byte); ok && int64(cap(*buffer)) >= size {
+		*buffer = (*buffer)[:size]
+		return buffer
+	}
+	buffer := make([]byte, size)
+	return &buffer
+}
+
+// putChunkBuffer - return a buffer to the pool for reuse
+func putChunkBuffer(buffer *[]byte) {
+	chunkBuffers.Put(buffer)
+}
+
+// writeCompressedObjectData - compress and write encoded data, returns chunk sizes, compressed
+// length and uncompressed length
+func (b bucket) writeCompressedObjectData(ctx context.Context, k, m uint8, writers []io.WriteCloser, objectData io.Reader, size int64, hashWriter io.Writer, blockHashes *treeHash, hashes *sliceHashes) ([]int64, int, int64, *probe.Error) {
+	reader, writer := io.Pipe()
+	lengthCh := make(chan int64, 1)
+	go func() {
+		defer close(lengthCh)
+		compressor := compressionCodecs[b.compression].newWriter(writer)
+		length, err := io.Copy(compressor, io.TeeReader(objectData, hashWriter))
+		if err == nil {
+			err = compressor.Close()
+		}
+		lengthCh <- length
+		writer.CloseWithError(err)
+	}()
+	chunkSizes, totalLength, err := b.writeObjectData(ctx, k, m, writers, reader, size, ioutil.Discard, blockHashes, hashes)
+	if err != nil {
+		// unblock the compressor
+		reader.CloseWithError(probe.WrapError(err))
+		return nil, 0, 0, err.Trace()
+	}
+	return chunkSizes, totalLength, <-lengthCh, nil
+}
+
+// getAdaptiveChunkSizes - chunk sizes to be recorded in metadata, only needed when chunks other
+// than the last one are not of the block size
+func getAdaptiveChunkSizes(chunkSizes []int64, blockSize int64) []int64 {
+	for i := 0; i < len(chunkSizes)-1; i++ {
+		if chunkSizes[i] != blockSize {
+			return chunkSizes
+		}
+	}
+	return nil
+}
+
+// compressionCodec - compressor and decompressor of a codec for data at rest
+type compressionCodec struct {
+	newWriter func(io.Writer) io.WriteCloser
+	newReader func(io.Reader) (io.ReadCloser, error)
+}
+
+// compressionCodecs - supported codecs by the name recorded in object metadata
+var compressionCodecs = map[string]compressionCodec{
+	compressionGzip: {
+		newWriter: func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		newReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	},
+	compressionZlib: {
+		newWriter: func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+		newReader: zlib.NewReader,
+	},
+}
+
+// decompressWriter - decompress everything written to it into an underlying writer
+type decompressWriter struct {
+	*io.PipeWriter
+	errCh chan error
+}
+
+// newDecompressWriter - decompressing writer for a given compression codec, objects compressed with
+// a codec not known to this version cannot be read
+func newDecompressWriter(writer io.Writer, codec string) (*decompressWriter, *probe.Error) {
+	compression, ok := compressionCodecs[codec]
+	if !ok {
+		return nil, probe.NewError(UnsupportedCompression{Codec: codec})
 	}
-	return chunkCount, totalLength, nil
+	reader, pipeWriter := io.Pipe()
+	w := &decompressWriter{PipeWriter: pipeWriter, errCh: make(chan error, 1)}
+	go func() {
+		decompressor, err := compression.newReader(reader)
+		if err == nil {
+			_, err = io.Copy(writer, decompressor)
+		}
+		// unblock any pending writes if decompression failed
+		reader.CloseWithError(err)
+		w.errCh <- err
+	}()
+	return w, nil
 }
 
-// readObjectData -
-func (b bucket) readObjectData(objectName string, writer *io.PipeWriter, objMetadata ObjectMetadata) {
+// Close - end of compressed data, waits for decompression to finish
+func (w *decompressWriter) Close() error {
+	w.PipeWriter.Close()
+	return <-w.errCh
+}
+
+// readObjectData - decode objectName into writer verifying its checksums, ctx is checked before
+// every chunk and the writer closed with its error once cancelled
+func (b bucket) readObjectData(ctx context.Context, objectName string, writer *io.PipeWriter, objMetadata ObjectMetadata, progress ChunkProgressFunc) {
 	readers, err := b.getObjectReaders(objectName, "data")
 	if err != nil {
 		writer.CloseWithError(probe.WrapError(err))
 		return
 	}
+	b.readObjectReaders(ctx, objectName, readers, writer, objMetadata, progress)
+}
+
+// readObjectReaders - read an object like readObjectData from slice readers already opened, the
+// readers are closed once read
+func (b bucket) readObjectReaders(ctx context.Context, objectName string, readers map[int]io.ReadCloser, writer *io.PipeWriter, objMetadata ObjectMetadata, progress ChunkProgressFunc) {
 	for _, reader := range readers {
 		defer reader.Close()
 	}
@@ -498,35 +2808,106 @@ func (b bucket) readObjectData(objectName string, writer *io.PipeWriter, objMeta
 		}
 	}
 	hasher := md5.New()
-	sum512hasher := sha256.New()
+	sum512hasher := sha512.New()
 	mwriter := io.MultiWriter(writer, hasher, sum512hasher)
-	switch len(readers) > 1 {
-	case true:
-		encoder, err := newEncoder(objMetadata.DataDisks, objMetadata.ParityDisks)
+	// progress channel is buffered to hold all chunks, reading never blocks on a slow callback
+	progressCh := make(chan ChunkProgress, objMetadata.ChunkCount+1)
+	defer close(progressCh)
+	if progress != nil {
+		go func() {
+			for p := range progressCh {
+				progress(p)
+			}
+		}()
+	}
+	switch {
+	case objMetadata.ReplicaDisks > 0:
+		if objMetadata.Reconstructed {
+			expectedMd5sum = nil
+		}
+		if err := ctx.Err(); err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+		if err := b.readReplicatedData(objectName, readers, expectedMd5sum, mwriter); err != nil {
+			writer.CloseWithError(probe.WrapError(err))
+			return
+		}
+	case objMetadata.DataDisks > 0:
+		// nothing is streamed unless enough slices are left to decode the object
+		if len(readers) < int(objMetadata.DataDisks) {
+			err := probe.NewError(InsufficientReadQuorum{Bucket: b.getBucketName(), Disks: len(readers), Quorum: int(objMetadata.DataDisks)})
+			writer.CloseWithError(probe.WrapError(err))
+			return
+		}
+		decoder, err := b.newChunkDecoder(readers, objMetadata, 0)
 		if err != nil {
 			writer.CloseWithError(probe.WrapError(err))
 			return
 		}
-		totalLeft := objMetadata.Size
-		for i := 0; i < objMetadata.ChunkCount; i++ {
-			decodedData, err := b.decodeEncodedData(totalLeft, int64(objMetadata.BlockSize), readers, encoder, writer)
+		dataWriter := mwriter
+		var decompressor *decompressWriter
+		if objMetadata.Compression != "" {
+			decompressor, err = newDecompressWriter(mwriter, objMetadata.Compression)
+			if err != nil {
+				writer.CloseWithError(probe.WrapError(err))
+				return
+			}
+			defer decompressor.CloseWithError(io.ErrClosedPipe)
+			dataWriter = decompressor
+		}
+		for i := 0; decoder.more(); i++ {
+			if err := ctx.Err(); err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+			decodedData, shards, missing, err := decoder.next(true)
 			if err != nil {
 				writer.CloseWithError(probe.WrapError(err))
 				return
 			}
-			if _, err := io.Copy(mwriter, bytes.NewReader(decodedData)); err != nil {
+			if _, err := io.Copy(dataWriter, bytes.NewReader(decodedData)); err != nil {
+				writer.CloseWithError(probe.WrapError(probe.NewError(err)))
+				return
+			}
+			// shards of the chunk served are verified in the background, never delaying the read
+			if b.isParitySampled() {
+				b.stats.paritySamples.enqueue(paritySample{
+					bucket:  b,
+					object:  objMetadata.Object,
+					encoder: decoder.encoder,
+					shards:  shards,
+					missing: missing,
+					length:  len(decodedData),
+				})
+			}
+			if progress != nil {
+				progressCh <- ChunkProgress{
+					Index:  i,
+					Bytes:  int64(len(decodedData)),
+					MD5Sum: hex.EncodeToString(hasher.Sum(nil)),
+				}
+			}
+		}
+		if decompressor != nil {
+			if err := decompressor.Close(); err != nil {
 				writer.CloseWithError(probe.WrapError(probe.NewError(err)))
 				return
 			}
-			totalLeft = totalLeft - int64(objMetadata.BlockSize)
 		}
-	case false:
-		_, err := io.Copy(writer, readers[0])
+		decoder.recordRead()
+	default:
+		_, err := io.Copy(writer, contextReader{ctx: ctx, reader: readers[0]})
 		if err != nil {
 			writer.CloseWithError(probe.WrapError(probe.NewError(err)))
 			return
 		}
 	}
+	// checksums of objects with reconstructed metadata are unknown
+	if objMetadata.Reconstructed {
+		writer.Close()
+		return
+	}
 	// check if decodedData md5sum matches
 	if !bytes.Equal(expectedMd5sum, hasher.Sum(nil)) {
 		writer.CloseWithError(probe.WrapError(probe.NewError(ChecksumMismatch{})))
@@ -540,8 +2921,41 @@ func (b bucket) readObjectData(objectName string, writer *io.PipeWriter, objMeta
 	return
 }
 
-// decodeEncodedData -
-func (b bucket) decodeEncodedData(totalLeft, blockSize int64, readers map[int]io.ReadCloser, encoder encoder, writer *io.PipeWriter) ([]byte, *probe.Error) {
+// readReplicatedData - read a replicated object from the first replica matching its md5sum
+func (b bucket) readReplicatedData(objectName string, readers map[int]io.ReadCloser, expectedMd5sum []byte, writer io.Writer) *probe.Error {
+	var orders []int
+	for order := range readers {
+		orders = append(orders, order)
+	}
+	sort.Ints(orders)
+	for _, order := range orders {
+		replica, err := ioutil.ReadAll(readers[order])
+		if err != nil {
+			continue
+		}
+		// md5sum of objects with reconstructed metadata is unknown, the first replica is used
+		replicaMd5sum := md5.Sum(replica)
+		if expectedMd5sum != nil && !bytes.Equal(expectedMd5sum, replicaMd5sum[:]) {
+			continue
+		}
+		if _, err := io.Copy(writer, bytes.NewReader(replica)); err != nil {
+			return probe.NewError(err)
+		}
+		return nil
+	}
+	return probe.NewError(ObjectCorrupted{Object: objectName})
+}
+
+// decodeEncodedData - decode a chunk, also returns the shards which were missing. Shards not matching
+// their expected hash are discarded as missing, such that they are reconstructed from the others
+func (b bucket) decodeEncodedData(totalLeft, blockSize int64, readers map[int]io.ReadCloser, encoder encoder, expectedHashes []string, writer *io.PipeWriter) ([]byte, []int, *probe.Error) {
+	decodedData, _, missing, err := b.decodeEncodedShards(totalLeft, blockSize, readers, encoder, expectedHashes, writer)
+	return decodedData, missing, err
+}
+
+// decodeEncodedShards - decode a chunk like decodeEncodedData, also returns the shards in disk order.
+// Missing shards may have been reconstructed in place by decoding
+func (b bucket) decodeEncodedShards(totalLeft, blockSize int64, readers map[int]io.ReadCloser, encoder encoder, expectedHashes []string, writer *io.PipeWriter) ([]byte, [][]byte, []int, *probe.Error) {
 	var curBlockSize int64
 	if blockSize < totalLeft {
 		curBlockSize = blockSize
@@ -550,40 +2964,109 @@ func (b bucket) decodeEncodedData(totalLeft, blockSize int64, readers map[int]io
 	}
 	curChunkSize, err := encoder.GetEncodedBlockLen(int(curBlockSize))
 	if err != nil {
-		return nil, err.Trace()
+		return nil, nil, nil, err.Trace()
 	}
 	encodedBytes := make([][]byte, encoder.k+encoder.m)
-	errCh := make(chan error, len(readers))
+	reconcileShardReaders(readers, len(encodedBytes))
+	// shards are read at most readParallelism at a time, all at once by default
+	var slots chan struct{}
+	if b.readParallelism > 0 {
+		slots = make(chan struct{}, b.readParallelism)
+	}
+	type sliceRead struct {
+		order int
+		data  []byte
+		err   error
+	}
+	// buffered such that reads completing after their timeout never block
+	readCh := make(chan sliceRead, len(readers))
+	timeoutCh := make(chan int, len(readers))
 	var errRet error
 	var readCnt int
 
 	for i, reader := range readers {
 		go func(reader io.Reader, i int) {
-			encodedBytes[i] = make([]byte, curChunkSize)
-			_, err := io.ReadFull(reader, encodedBytes[i])
-			if err != nil {
-				encodedBytes[i] = nil
-				errCh <- err
-				return
+			release := func() {}
+			if slots != nil {
+				slots <- struct{}{}
+				var once sync.Once
+				release = func() { once.Do(func() { <-slots }) }
+				defer release()
+			}
+			// every shard is given the read timeout from the moment it is read, a stuck disk
+			// gives up its slot once timed out
+			if b.readTimeout > 0 {
+				timer := time.AfterFunc(b.readTimeout, func() {
+					release()
+					timeoutCh <- i
+				})
+				defer timer.Stop()
 			}
-			errCh <- nil
+			data := make([]byte, curChunkSize)
+			_, err := io.ReadFull(reader, data)
+			readCh <- sliceRead{order: i, data: data, err: err}
 		}(reader, i)
-		// read through errCh for any errors
-		err := <-errCh
-		if err != nil {
-			errRet = err
-		} else {
+	}
+	pending := make(map[int]struct{})
+	for i := range readers {
+		pending[i] = struct{}{}
+	}
+	for len(pending) > 0 {
+		select {
+		case read := <-readCh:
+			if _, ok := pending[read.order]; !ok {
+				continue
+			}
+			delete(pending, read.order)
+			if read.err != nil {
+				errRet = read.err
+				continue
+			}
+			if read.order < len(expectedHashes) && hashSlice(read.data) != expectedHashes[read.order] {
+				b.stats.recordCorruptSlice(read.order)
+				errRet = SliceCorrupted{Disk: read.order}
+				continue
+			}
+			encodedBytes[read.order] = read.data
 			readCnt++
+		case i := <-timeoutCh:
+			if _, ok := pending[i]; !ok {
+				continue
+			}
+			// position of a stuck disk in its slice is unknown, it is not read any further
+			delete(readers, i)
+			delete(pending, i)
+			errRet = DiskReadTimedOut{Disk: i}
 		}
 	}
 	if readCnt < int(encoder.k) {
-		return nil, probe.NewError(errRet)
+		// too few shards could be read, without any of them failing if the others are missing
+		if errRet == nil {
+			errRet = InsufficientReadQuorum{Bucket: b.getBucketName(), Disks: readCnt, Quorum: int(encoder.k)}
+		}
+		return nil, nil, nil, probe.NewError(errRet)
+	}
+	var missing []int
+	for i := range encodedBytes {
+		if encodedBytes[i] == nil {
+			missing = append(missing, i)
+		}
 	}
 	decodedData, err := encoder.Decode(encodedBytes, int(curBlockSize))
 	if err != nil {
-		return nil, err.Trace()
+		return nil, nil, nil, err.Trace()
+	}
+	return decodedData, encodedBytes, missing, nil
+}
+
+// reconcileShardReaders - drop readers not mapping to a shard of the current encoding, such as
+// stale slices left behind on disk by a prior encoding with more disks, readers are closed by the caller
+func reconcileShardReaders(readers map[int]io.ReadCloser, totalShards int) {
+	for order := range readers {
+		if order < 0 || order >= totalShards {
+			delete(readers, order)
+		}
 	}
-	return decodedData, nil
 }
 
 // getObjectReaders -
@@ -592,33 +3075,103 @@ func (b bucket) getObjectReaders(objectName, objectMeta string) (map[int]io.Read
 	var disks map[int]block.Block
 	var err *probe.Error
 	nodeSlice := 0
-	for _, node := range b.nodes {
+	for _, node := range b.getNodes() {
 		disks, err = node.ListDisks()
 		if err != nil {
 			return nil, err.Trace()
 		}
 		for order, disk := range disks {
+			if _, ok := b.excludedDisks[order]; ok {
+				continue
+			}
 			var objectSlice io.ReadCloser
 			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, order)
 			objectPath := filepath.Join(b.xlName, bucketSlice, objectName, objectMeta)
 			objectSlice, err = disk.Open(objectPath)
 			if err == nil {
-				readers[order] = objectSlice
+				readers[order] = b.limitShardReader(objectSlice, disk.GetPath())
 			}
 		}
 		nodeSlice = nodeSlice + 1
 	}
-	if err != nil {
+	// missing slices on some disks are fine, as long as some could be opened
+	if err != nil && len(readers) == 0 {
 		return nil, err.Trace()
 	}
+	// every disk was excluded from the read, there is nothing to read from
+	if len(readers) == 0 {
+		return nil, probe.NewError(InsufficientReadQuorum{Bucket: b.getBucketName(), Disks: 0, Quorum: 1})
+	}
 	return readers, nil
 }
 
+// skipShardReaders - advance every shard reader by offset, seeking where possible, readers failing
+// to advance are dropped
+func skipShardReaders(readers map[int]io.ReadCloser, offset int64) {
+	if offset == 0 {
+		return
+	}
+	for order, reader := range readers {
+		if e := skipShardReader(reader, offset); e != nil {
+			delete(readers, order)
+		}
+	}
+}
+
+// skipShardReader - advance a shard reader by offset, seeking where possible. Shards shorter than
+// offset fail to advance
+func skipShardReader(reader io.Reader, offset int64) error {
+	if seeker, ok := reader.(io.Seeker); ok {
+		if end, e := seeker.Seek(0, os.SEEK_END); e == nil {
+			if end < offset {
+				return io.ErrUnexpectedEOF
+			}
+			_, e = seeker.Seek(offset, os.SEEK_SET)
+			return e
+		}
+	}
+	_, e := io.CopyN(ioutil.Discard, reader, offset)
+	return e
+}
+
+// provisionObjectDirs - create object directory on all disks in parallel, such that
+// writers do not have to create them lazily one disk after the other
+func (b bucket) provisionObjectDirs(objectName string) *probe.Error {
+	var wg sync.WaitGroup
+	var errs []*probe.Error
+	var errLock sync.Mutex
+	nodeSlice := 0
+	for _, node := range b.getNodes() {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return err.Trace()
+		}
+		for order, disk := range disks {
+			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, order)
+			wg.Add(1)
+			go func(disk block.Block, objectPath string) {
+				defer wg.Done()
+				if err := disk.MakeDir(objectPath); err != nil {
+					errLock.Lock()
+					errs = append(errs, err.Trace(objectPath))
+					errLock.Unlock()
+				}
+			}(disk, filepath.Join(b.xlName, bucketSlice, objectName))
+		}
+		nodeSlice = nodeSlice + 1
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
 // getObjectWriters -
 func (b bucket) getObjectWriters(objectName, objectMeta string) ([]io.WriteCloser, *probe.Error) {
 	var writers []io.WriteCloser
 	nodeSlice := 0
-	for _, node := range b.nodes {
+	for _, node := range b.getNodes() {
 		disks, err := node.ListDisks()
 		if err != nil {
 			return nil, err.Trace()
@@ -0,0 +1,204 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/minio/minio/pkg/crypto/sha512"
+	"github.com/minio/minio/pkg/probe"
+)
+
+// ChecksumReader - reads object data verifying its md5sum and sha512sum as it is read. A mismatch is
+// returned by the read reaching the end of the data instead of io.EOF, data read before it is not
+// to be trusted
+type ChecksumReader struct {
+	reader  io.Reader
+	closers []io.Closer
+
+	sumMD5            hash.Hash
+	sum512            hash.Hash
+	expectedMD5Sum    []byte
+	expectedSHA512Sum []byte
+	err               error
+	meter             *requestMeter
+}
+
+// ReadObjectWithChecksum - open an object to read, data is decoded as it is read from the returned
+// reader and verified against the checksums of the object once all of it is read. Unlike ReadObject
+// no go-routine and pipe are involved, the reader must be closed by the caller
+func (b bucket) ReadObjectWithChecksum(objectName string) (reader *ChecksumReader, size int64, err *probe.Error) {
+	defer b.rlockObject(objectName)()
+	// the request is recorded once the object is read, failures to open it right away
+	defer func(start time.Time) {
+		if err != nil {
+			b.stats.recordRequest(b.name, operationGetObject, start, 0, err)
+			return
+		}
+		reader.meter = b.newRequestMeter(operationGetObject, start)
+	}(time.Now())
+	if b.isCachedNotFound(objectName) {
+		return nil, 0, probe.NewError(ObjectNotFound{Object: objectName})
+	}
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return nil, 0, err.Trace()
+	}
+	if _, ok := bucketMetadata.Buckets[b.getBucketName()].BucketObjects[objectName]; !ok {
+		b.cacheNotFound(objectName)
+		return nil, 0, probe.NewError(ObjectNotFound{Object: objectName})
+	}
+	objectPath := b.getObjectPath(objectName)
+	objMetadata, err := b.readObjectMetadata(objectPath)
+	if err != nil {
+		return nil, 0, err.Trace()
+	}
+	reader, err = b.newChecksumReader(objectPath, objMetadata)
+	if err != nil {
+		return nil, 0, err.Trace()
+	}
+	return reader, objMetadata.Size, nil
+}
+
+// newChecksumReader - checksum reader over the data of an object, decoding it from its slices
+func (b bucket) newChecksumReader(objectName string, objMetadata ObjectMetadata) (*ChecksumReader, *probe.Error) {
+	expectedMD5Sum, e := hex.DecodeString(objMetadata.MD5Sum)
+	if e != nil {
+		return nil, probe.NewError(e)
+	}
+	expectedSHA512Sum, e := hex.DecodeString(objMetadata.SHA512Sum)
+	if e != nil {
+		return nil, probe.NewError(e)
+	}
+	readers, err := b.getObjectReaders(objectName, "data")
+	if err != nil {
+		return nil, err.Trace()
+	}
+	r := &ChecksumReader{
+		sumMD5:            md5.New(),
+		sum512:            sha512.New(),
+		expectedMD5Sum:    expectedMD5Sum,
+		expectedSHA512Sum: expectedSHA512Sum,
+	}
+	for _, reader := range readers {
+		r.closers = append(r.closers, reader)
+	}
+	switch {
+	case objMetadata.ReplicaDisks > 0:
+		// replicas are small, the first one matching its md5sum is read into memory
+		var replica bytes.Buffer
+		if err := b.readReplicatedData(objectName, readers, expectedMD5Sum, &replica); err != nil {
+			r.Close()
+			return nil, err.Trace()
+		}
+		r.reader = &replica
+	case objMetadata.DataDisks > 0:
+		if len(readers) < int(objMetadata.DataDisks) {
+			r.Close()
+			return nil, probe.NewError(InsufficientReadQuorum{Bucket: b.getBucketName(), Disks: len(readers), Quorum: int(objMetadata.DataDisks)})
+		}
+		decoder, err := b.newChunkDecoder(readers, objMetadata, 0)
+		if err != nil {
+			r.Close()
+			return nil, err.Trace()
+		}
+		r.reader = &decodeReader{decoder: decoder}
+		if objMetadata.Compression != "" {
+			compression, ok := compressionCodecs[objMetadata.Compression]
+			if !ok {
+				r.Close()
+				return nil, probe.NewError(UnsupportedCompression{Codec: objMetadata.Compression})
+			}
+			decompressor, e := compression.newReader(r.reader)
+			if e != nil {
+				r.Close()
+				return nil, probe.NewError(e)
+			}
+			r.reader = decompressor
+			r.closers = append(r.closers, decompressor)
+		}
+	case len(readers) == 1:
+		for _, reader := range readers {
+			r.reader = reader
+		}
+	default:
+		r.Close()
+		return nil, probe.NewError(ObjectCorrupted{Object: objectName})
+	}
+	return r, nil
+}
+
+// Read - read decoded data, checksums are verified once the end of the data is reached
+func (r *ChecksumReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.reader.Read(p)
+	r.sumMD5.Write(p[:n])
+	r.sum512.Write(p[:n])
+	if err == io.EOF {
+		if !bytes.Equal(r.expectedMD5Sum, r.sumMD5.Sum(nil)) || !bytes.Equal(r.expectedSHA512Sum, r.sum512.Sum(nil)) {
+			err = ChecksumMismatch{}
+		}
+	}
+	r.err = err
+	r.meter.count(n, err)
+	return n, err
+}
+
+// Close - close the slices of the object being read
+func (r *ChecksumReader) Close() error {
+	r.meter.close()
+	var err error
+	for _, closer := range r.closers {
+		if e := closer.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// decodeReader - decodes the chunks of an erasure coded object from its slices as they are read
+type decodeReader struct {
+	decoder *chunkDecoder
+	buffer  []byte
+}
+
+// Read - read from the chunk decoded last, the next chunk is decoded once it is read
+func (r *decodeReader) Read(p []byte) (int, error) {
+	for len(r.buffer) == 0 {
+		if !r.decoder.more() {
+			return 0, io.EOF
+		}
+		decodedData, _, _, err := r.decoder.next(true)
+		if err != nil {
+			return 0, probe.WrapError(err)
+		}
+		r.buffer = decodedData
+		if !r.decoder.more() {
+			r.decoder.recordRead()
+		}
+	}
+	n := copy(p, r.buffer)
+	r.buffer = r.buffer[n:]
+	return n, nil
+}
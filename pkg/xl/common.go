@@ -19,7 +19,9 @@ package xl
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"io"
+	"os"
 	"regexp"
 	"sort"
 	"strings"
@@ -76,6 +78,24 @@ func IsValidObjectName(object string) bool {
 	return true
 }
 
+// isReservedObjectName - verify if object name is reserved for system use, names colliding with
+// internal files or path components once normalized are always reserved
+func isReservedObjectName(object string, reservedPrefixes []string) bool {
+	switch normalizeObjectName(object) {
+	case "data", objectMetadataConfig, bucketMetadataConfig, ".", "..":
+		return true
+	}
+	if strings.HasPrefix(object, multipartPrefix) {
+		return true
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix != "" && strings.HasPrefix(object, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsValidPrefix - verify prefix name is correct, an empty prefix is valid
 func IsValidPrefix(prefix string) bool {
 	if strings.TrimSpace(prefix) == "" {
@@ -104,6 +124,19 @@ func NewProxyWriter(w io.Writer) *ProxyWriter {
 	return &ProxyWriter{writer: w, writtenBytes: nil}
 }
 
+// contextReader - reader failing with the error of ctx once it is cancelled
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
 // Delimiter delims the string at delimiter
 func Delimiter(object, delimiter string) string {
 	readBuffer := bytes.NewBufferString(object)
@@ -188,3 +221,38 @@ func CleanupWritersOnError(writers []io.WriteCloser) {
 		writer.(*atomic.File).CloseAndPurge()
 	}
 }
+
+// commitWriters sync writers to disk and commit them, on error the writers not yet
+// committed are purged
+func commitWriters(writers []io.WriteCloser) error {
+	if err := syncWriters(writers); err != nil {
+		return err
+	}
+	return renameWriters(writers)
+}
+
+// syncWriters sync writers to disk leaving them uncommitted under their temporary names, on
+// error all writers are purged
+func syncWriters(writers []io.WriteCloser) error {
+	for _, writer := range writers {
+		if err := writer.(*atomic.File).Sync(); err != nil {
+			CleanupWritersOnError(writers)
+			return err
+		}
+	}
+	return nil
+}
+
+// renameWriters commit synced writers by renaming them into place, on error the failed writer and
+// the writers not yet committed are purged
+func renameWriters(writers []io.WriteCloser) error {
+	for i, writer := range writers {
+		if err := writer.Close(); err != nil {
+			// the failed writer is closed already, its temporary file is removed by name
+			os.Remove(writer.(*atomic.File).Name())
+			CleanupWritersOnError(writers[i+1:])
+			return err
+		}
+	}
+	return nil
+}
@@ -61,6 +61,26 @@ func SaveConfig(a *Config) *probe.Error {
 	return nil
 }
 
+// checkConfig - verify options of a config are within their bounds, unset options take their defaults
+func checkConfig(config *Config) *probe.Error {
+	if _, ok := compressionCodecs[config.Compression]; config.Compression != "" && !ok {
+		return probe.NewError(InvalidArgument{})
+	}
+	if config.ParityVerifyRate < 0 || config.ParityVerifyRate > 1 {
+		return probe.NewError(InvalidArgument{})
+	}
+	if config.BlockSize != 0 && !isValidBlockSize(config.BlockSize) {
+		return probe.NewError(InvalidArgument{})
+	}
+	if config.SmallObjectSize < 0 || config.ShardReadsPerDisk < 0 || config.NotFoundCacheSize < 0 {
+		return probe.NewError(InvalidArgument{})
+	}
+	if config.DiskReadTimeout < 0 || config.NotFoundCacheTTL < 0 {
+		return probe.NewError(InvalidArgument{})
+	}
+	return nil
+}
+
 // LoadConfig load xl config
 func LoadConfig() (*Config, *probe.Error) {
 	xlConfigPath, err := getXLConfigPath()
@@ -0,0 +1,127 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import (
+	"io"
+
+	"github.com/minio/minio/pkg/probe"
+)
+
+// chunkDecoder - decodes the chunks of an erasure coded object one after another from its slices, all
+// reads decoding objects share it. Shards missing or discarded as corrupt are tracked, such that the
+// read is recorded as degraded once done
+type chunkDecoder struct {
+	bucket      bucket
+	readers     map[int]io.ReadCloser
+	encoder     encoder
+	objMetadata ObjectMetadata
+	chunk       int
+	totalLeft   int64
+
+	// a read is degraded if any data shard had to be reconstructed from parity
+	degraded      bool
+	degradedDisks map[int]struct{}
+}
+
+// newChunkDecoder - decoder of the chunks of an object from firstChunk on, readers are positioned at
+// the start of the slices of firstChunk
+func (b bucket) newChunkDecoder(readers map[int]io.ReadCloser, objMetadata ObjectMetadata, firstChunk int) (*chunkDecoder, *probe.Error) {
+	encoder, err := newEncoder(objMetadata.DataDisks, objMetadata.ParityDisks)
+	if err != nil {
+		return nil, err.Trace()
+	}
+	// decoded data is compressed, size on disk differs from the object size
+	totalLeft := objMetadata.Size
+	if objMetadata.Compression != "" {
+		totalLeft = objMetadata.StoredSize
+	}
+	d := &chunkDecoder{
+		bucket:        b,
+		readers:       readers,
+		encoder:       encoder,
+		objMetadata:   objMetadata,
+		totalLeft:     totalLeft,
+		degradedDisks: make(map[int]struct{}),
+	}
+	for d.chunk < firstChunk {
+		d.totalLeft -= d.chunkSize()
+		d.chunk++
+	}
+	return d, nil
+}
+
+// chunkSize - size of the next chunk, chunks of streams written without a known size have varying sizes
+func (d *chunkDecoder) chunkSize() int64 {
+	if len(d.objMetadata.ChunkSizes) > 0 {
+		return d.objMetadata.ChunkSizes[d.chunk]
+	}
+	return int64(d.objMetadata.BlockSize)
+}
+
+// more - whether chunks are left to be decoded
+func (d *chunkDecoder) more() bool {
+	return d.chunk < d.objMetadata.ChunkCount
+}
+
+// next - decode the next chunk, its shards are verified against their slice hashes unless verify is
+// false. Also returns the shards in disk order and the orders of the shards which were missing, missing
+// shards may have been reconstructed in place by decoding
+func (d *chunkDecoder) next(verify bool) ([]byte, [][]byte, []int, *probe.Error) {
+	chunkSize := d.chunkSize()
+	var expectedHashes []string
+	if verify {
+		expectedHashes = d.objMetadata.getSliceHashes(d.chunk)
+	}
+	decodedData, shards, missing, err := d.bucket.decodeEncodedShards(d.totalLeft, chunkSize, d.readers, d.encoder, expectedHashes, nil)
+	if err != nil {
+		return nil, nil, nil, err.Trace()
+	}
+	for _, order := range missing {
+		if order < int(d.objMetadata.DataDisks) {
+			d.degraded = true
+		}
+		d.degradedDisks[order] = struct{}{}
+	}
+	d.totalLeft = d.totalLeft - chunkSize
+	d.chunk++
+	return decodedData, shards, missing, nil
+}
+
+// recordRead - record the read of the chunks decoded and the redundancy left to the object
+func (d *chunkDecoder) recordRead() {
+	b := d.bucket
+	b.stats.recordRead(b.getBucketName(), d.degraded, d.degradedDisks)
+	b.stats.recordRedundancy(b.getBucketName(), d.objMetadata.Object, d.objMetadata.DataDisks, d.objMetadata.ParityDisks, len(d.degradedDisks))
+}
+
+// readChunkShards - read the slices of a chunk as is from every reader, neither verified nor decoded.
+// Readers failing are dropped and their errors returned by disk order
+func readChunkShards(readers map[int]io.ReadCloser, totalShards, sliceLen int) ([][]byte, map[int]error) {
+	shards := make([][]byte, totalShards)
+	errs := make(map[int]error)
+	for order, reader := range readers {
+		shard := make([]byte, sliceLen)
+		if _, e := io.ReadFull(reader, shard); e != nil {
+			errs[order] = e
+			delete(readers, order)
+			continue
+		}
+		shards[order] = shard
+	}
+	return shards, errs
+}
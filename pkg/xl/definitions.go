@@ -16,7 +16,12 @@
 
 package xl
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"github.com/minio/minio/pkg/s3/signature4"
+)
 
 // ObjectMetadata container for object on xl system
 type ObjectMetadata struct {
@@ -32,15 +37,242 @@ type ObjectMetadata struct {
 	// erasure
 	DataDisks   uint8 `json:"sys.erasureK"`
 	ParityDisks uint8 `json:"sys.erasureM"`
-	BlockSize   int   `json:"sys.blockSize"`
-	ChunkCount  int   `json:"sys.chunkCount"`
+	// parity disks requested at write, zero if disks were split evenly between data and parity
+	RequestedParityDisks uint8 `json:"sys.requestedErasureM,omitempty"`
+	BlockSize            int   `json:"sys.blockSize"`
+	ChunkCount           int   `json:"sys.chunkCount"`
+	// size of every chunk, set only when written without a known size and chunks vary in size
+	ChunkSizes []int64 `json:"sys.chunkSizes,omitempty"`
+
+	// replication, set only for small objects which are not erasure coded
+	ReplicaDisks uint8 `json:"sys.replicaDisks"`
+
+	// compression, size is of the uncompressed data while stored size is of the compressed data
+	Compression string `json:"sys.compression,omitempty"`
+	StoredSize  int64  `json:"sys.storedSize,omitempty"`
 
 	// checksums
 	MD5Sum    string `json:"sys.md5sum"`
 	SHA512Sum string `json:"sys.sha512sum"`
+	// base64 encoded crc32c (castagnoli) like the x-amz-checksum-crc32c header, set only when
+	// requested at write
+	CRC32C string `json:"sys.crc32c,omitempty"`
+	// hex digest of the bucket's ETag algorithm, objects written before it was recorded use md5sum
+	ETag string `json:"sys.etag,omitempty"`
+	// merkle tree root over the stored blocks, set only when written with tree hashing
+	TreeHash string `json:"sys.treeHash,omitempty"`
+	// sha256 of every encoded block as stored by chunk and disk order, set only for erasure coded
+	// objects written before the hashes were kept beside the data slices
+	SliceHashes [][]string `json:"sys.sliceHashes,omitempty"`
+	// hashes of the encoded blocks are kept beside the data slice on every disk
+	SliceHashSidecar bool `json:"sys.sliceHashSidecar,omitempty"`
+	sliceHashes      *sliceHashes
+
+	// parts in the order assembled, set only for objects written by a multipart upload
+	Parts []PartMetadata `json:"sys.parts,omitempty"`
 
 	// metadata
 	Metadata map[string]string `json:"metadata"`
+	// content type, also kept as the "contentType" metadata if given at write
+	ContentType string `json:"contentType,omitempty"`
+	// last update of metadata alone without re-writing data, zero if never updated
+	MetadataModified time.Time `json:"metadataModified"`
+
+	// expiry from the matching bucket lifecycle rule, computed on every read and never stored
+	Expiration       time.Time `json:"-"`
+	ExpirationRuleID string    `json:"-"`
+
+	// erasure coding parameters no longer match the current disks, computed on read and never stored
+	ReencodeRecommended bool `json:"-"`
+
+	// metadata is reconstructed from the data slices while RecoverObject reads them, checksums are not
+	// known yet. Never stored nor returned to readers
+	Reconstructed bool `json:"-"`
+
+	// tags are stored apart from the object metadata, set only when listing with tags
+	Tags map[string]string `json:"-"`
+}
+
+// LastModified - time of the last change to either the object data or its metadata
+func (o ObjectMetadata) LastModified() time.Time {
+	if o.MetadataModified.After(o.Created) {
+		return o.MetadataModified
+	}
+	return o.Created
+}
+
+// checksumCRC32CHeader - header the crc32c of an object is sent and returned in, like AWS S3
+const checksumCRC32CHeader = "x-amz-checksum-crc32c"
+
+// ChecksumHeaders - x-amz-checksum-* headers echoing the checksums requested at write, empty if
+// none was requested
+func (o ObjectMetadata) ChecksumHeaders() map[string]string {
+	headers := make(map[string]string)
+	if o.CRC32C != "" {
+		headers[checksumCRC32CHeader] = o.CRC32C
+	}
+	return headers
+}
+
+// GetMetadataValue - look up metadata case-insensitively, the Content-Type header is looked up as the
+// content type of the object
+func (o ObjectMetadata) GetMetadataValue(key string) (string, bool) {
+	if value, ok := o.Metadata[key]; ok {
+		return value, true
+	}
+	if strings.EqualFold(key, "content-type") && o.ContentType != "" {
+		return o.ContentType, true
+	}
+	for k, value := range o.Metadata {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// ByteRange - a range of bytes of an object
+type ByteRange struct {
+	Start  int64
+	Length int64
+}
+
+// IntegrityManifest container for checksums and encoding parameters of an object,
+// for external verification of the data without reading it
+type IntegrityManifest struct {
+	Bucket string `json:"bucket"`
+	Object string `json:"object"`
+	Size   int64  `json:"size"`
+
+	// checksums
+	MD5Sum    string `json:"md5sum"`
+	SHA512Sum string `json:"sha512sum"`
+
+	// encoding
+	DataDisks    uint8   `json:"erasureK"`
+	ParityDisks  uint8   `json:"erasureM"`
+	BlockSize    int     `json:"blockSize"`
+	ChunkCount   int     `json:"chunkCount"`
+	ChunkSizes   []int64 `json:"chunkSizes,omitempty"`
+	ReplicaDisks uint8   `json:"replicaDisks"`
+	Compression  string  `json:"compression,omitempty"`
+	StoredSize   int64   `json:"storedSize,omitempty"`
+}
+
+// DecodeCostEstimate container for the estimated CPU cost of decoding an object
+type DecodeCostEstimate struct {
+	ChunkCount  int
+	DataDisks   uint8
+	ParityDisks uint8
+	// galois field multiply-adds to reconstruct all data and parity slices of every chunk
+	Operations int64
+}
+
+// ConsistencyReport container for bucket metadata consistency across disks
+type ConsistencyReport struct {
+	Bucket     string
+	Disks      int               // number of disks checked
+	Unreadable []int             // disks whose bucket metadata could not be read
+	OutOfSync  []DiskConsistency // disks which disagree with the majority of disks
+}
+
+// DiskOrder container for the position of a disk, the node it is attached to and its order on the node
+type DiskOrder struct {
+	Node string
+	Disk int
+}
+
+// DiskConsistency container for a disk out of sync with the majority of disks
+type DiskConsistency struct {
+	Disk    int
+	Missing bool     // bucket is missing in the disk's metadata
+	Fields  []string // divergent bucket metadata fields
+}
+
+// ChunkProgress per chunk verification status reported while reading an object
+type ChunkProgress struct {
+	Index  int
+	Bytes  int64
+	MD5Sum string // running md5sum of all the data read so far
+}
+
+// ChunkProgressFunc callback invoked for every chunk read
+type ChunkProgressFunc func(ChunkProgress)
+
+// ReadOptions tuning of a single object read, zero values read like ReadObject
+type ReadOptions struct {
+	// shards of a chunk read concurrently, zero reads all shards of a chunk at once
+	Parallelism int
+	// chunks decoded ahead of the caller, zero decodes only as fast as data is read
+	ReadAhead int
+	// whole object is read into a temporary file and its checksums verified before any data is returned
+	VerifyBeforeServe bool
+	// range to read, zero offset and length read the whole object, zero length reads up to its end
+	Offset int64
+	Length int64
+	// called once for every verified chunk of whole object reads
+	Progress ChunkProgressFunc
+	// bytes returned at most, zero returns any size. Larger reads fail with EntityTooLarge, or are
+	// truncated to their first MaxSize bytes if TruncateToMaxSize is set
+	MaxSize           int64
+	TruncateToMaxSize bool
+	// disk orders left out of the read as if they had failed, for reproducible failure tests
+	ExcludeDisks []int
+}
+
+// VerifySample - subset of chunks verified by sampled reads, exactly the rate rounded up of all
+// chunks are verified
+type VerifySample struct {
+	// fraction of chunks verified, greater than zero and at most one
+	Rate float64
+	// chunks are picked at random, else evenly strided starting with the first chunk
+	Random bool
+}
+
+// SampleCoverage - chunks of an object verified by a sampled read, objects not erasure coded are
+// verified in full and count as a single chunk
+type SampleCoverage struct {
+	Chunks   int
+	Verified int
+}
+
+// WriteOptions of a single object write, zero values write like WriteObject without verification
+type WriteOptions struct {
+	// hex encoded checksums the written data must match, empty checksums are not verified
+	ExpectedMD5Sum    string
+	ExpectedSHA512Sum string
+	// base64 encoded crc32c (castagnoli) the written data must match, as sent in the
+	// x-amz-checksum-crc32c header. Implies ComputeCRC32C
+	ExpectedCRC32C string
+	// crc32c (castagnoli) of the data is computed and stored with the object
+	ComputeCRC32C bool
+	// user metadata stored with the object
+	Metadata map[string]string
+	// signature of the request the payload hash is verified against, 'nil' skips verification
+	Signature *signature4.Sign
+	// stored as the "contentType" metadata, overriding the one in Metadata
+	ContentType string
+	// parity disks of erasure coded objects, zero splits disks evenly between data and parity
+	ParityDisks uint8
+	// server side encryption algorithm, no algorithm is supported yet and any value is rejected
+	Encryption string
+	// parts the object is assembled from by a multipart upload, stored with its metadata
+	Parts []PartMetadata
+	// etags the existing object must match, "*" requires the object to exist
+	IfMatch string
+	// etags the existing object must not match, "*" requires the object not to exist
+	IfNoneMatch string
+	// the write is not counted against the maximum object count of the bucket, for parts which
+	// are not in the bucket index and for writes whose count the caller already verified
+	skipObjectCount bool
+	// the object is a part of a multipart upload, written under a name reserved for parts and
+	// always erasure coded such that parts can be stitched together once the upload completes
+	uploadPart bool
+	// slices are written under this name instead of the normalized object name and left for the
+	// caller to rename into place
+	stagingPath string
+	// stored as the object ETag instead of the digest of its data
+	etag string
 }
 
 // Metadata container for xl metadata
@@ -63,6 +295,47 @@ type BucketMetadata struct {
 	Multiparts    map[string]MultiPartSession `json:"multiparts"`
 	Metadata      map[string]string           `json:"metadata"`
 	BucketObjects map[string]struct{}         `json:"objects"`
+	// digest used as ETag for new objects, md5 if not set
+	ETagAlgorithm string `json:"etagAlgorithm,omitempty"`
+	// last change sequence and changes ordered by sequence, only the latest change of an object is kept
+	Sequence uint64         `json:"sequence,omitempty"`
+	Changes  []ObjectChange `json:"changes,omitempty"`
+	// latest sequence and modification time of the changes dropped once more than maxObjectChanges
+	TruncatedSequence uint64    `json:"truncatedSequence,omitempty"`
+	TruncatedModified time.Time `json:"truncatedModified,omitempty"`
+	// lifecycle rules expiring objects after a number of days from their creation
+	LifecycleRules []LifecycleRule `json:"lifecycleRules,omitempty"`
+	// advanced on every addition or removal of objects, for optimistic concurrency on the index
+	Generation uint64 `json:"generation,omitempty"`
+	// objects can no longer be overwritten or deleted once this long has passed since their creation
+	ImmutableAfter time.Duration `json:"immutableAfter,omitempty"`
+	// objects the bucket may hold at most, zero is unlimited
+	MaxObjectCount int `json:"maxObjectCount,omitempty"`
+	// bytes of objects and of parts of uploads in progress the bucket may hold, zero is unlimited
+	Quota int64 `json:"quota,omitempty"`
+	// bytes of the objects in the index, kept while the bucket has a quota
+	Usage int64 `json:"usage,omitempty"`
+	// all objects are stored under their encoded name, unset for buckets which may still hold objects
+	// stored under their legacy name
+	NamesEncoded bool `json:"namesEncoded,omitempty"`
+	// size of the chunks new objects are erasure coded in and placement of their shards on nodes, taken
+	// from the config when the bucket is made. Unset for buckets made before they were kept
+	BlockSize int64  `json:"blockSize,omitempty"`
+	Placement string `json:"placement,omitempty"`
+}
+
+// LifecycleRule container for an expiration rule applied to objects matching a prefix
+type LifecycleRule struct {
+	ID     string `json:"id"`
+	Prefix string `json:"prefix"`
+	Days   int    `json:"days"`
+}
+
+// ObjectChange container for an object created or modified in a bucket
+type ObjectChange struct {
+	Object   string    `json:"object"`
+	Sequence uint64    `json:"sequence"`
+	Modified time.Time `json:"modified"`
 }
 
 // ListObjectsResults container for list objects response
@@ -70,6 +343,8 @@ type ListObjectsResults struct {
 	Objects        map[string]ObjectMetadata `json:"objects"`
 	CommonPrefixes []string                  `json:"commonPrefixes"`
 	IsTruncated    bool                      `json:"isTruncated"`
+	// last object name or common prefix listed, the marker to list the next page with if truncated
+	NextMarker string `json:"nextMarker,omitempty"`
 }
 
 // MultiPartSession multipart session
@@ -78,6 +353,9 @@ type MultiPartSession struct {
 	Initiated  time.Time               `json:"initiated"`
 	Parts      map[string]PartMetadata `json:"parts"`
 	TotalParts int                     `json:"total-parts"`
+	// upload was completed into the object of its name, the session is only kept until its parts
+	// are removed
+	Completed bool `json:"completed,omitempty"`
 }
 
 // PartMetadata - various types of individual part resources
@@ -86,6 +364,8 @@ type PartMetadata struct {
 	LastModified time.Time
 	ETag         string
 	Size         int64
+	// offset of the part in the object assembled from all parts, set once an upload is completed
+	Offset int64
 }
 
 // CompletePart - completed part container
@@ -16,7 +16,11 @@
 
 package xl
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
 
 // SignDoesNotMatch - signature does not match.
 type SignDoesNotMatch struct{}
@@ -25,6 +29,24 @@ func (e SignDoesNotMatch) Error() string {
 	return "Signature does not match."
 }
 
+// AggregateError errors of individual items of a batch operation, keyed by item
+type AggregateError struct {
+	Errors map[string]error
+}
+
+func (e AggregateError) Error() string {
+	var keys []string
+	for key := range e.Errors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var details []string
+	for _, key := range keys {
+		details = append(details, key+": "+e.Errors[key].Error())
+	}
+	return fmt.Sprintf("%d items failed: %s", len(keys), strings.Join(details, "; "))
+}
+
 // InvalidArgument invalid argument
 type InvalidArgument struct{}
 
@@ -118,6 +140,13 @@ func (e BadDigest) Error() string {
 	return "Bad digest"
 }
 
+// MissingDigest expected digest given without an actual digest to verify it against
+type MissingDigest struct{}
+
+func (e MissingDigest) Error() string {
+	return "Missing digest to verify against"
+}
+
 // ParityOverflow parity over flow
 type ParityOverflow struct{}
 
@@ -125,6 +154,67 @@ func (e ParityOverflow) Error() string {
 	return "Parity overflow"
 }
 
+// DataParityMismatch data and parity do not add up to total disks
+type DataParityMismatch struct {
+	K       uint8
+	M       uint8
+	Writers int
+}
+
+func (e DataParityMismatch) Error() string {
+	return fmt.Sprintf("Data %d and parity %d do not match total writers %d", e.K, e.M, e.Writers)
+}
+
+// DiskReadTimedOut reading a slice from a disk did not complete within the disk read timeout
+type DiskReadTimedOut struct {
+	Disk int
+}
+
+func (e DiskReadTimedOut) Error() string {
+	return fmt.Sprintf("Read from disk %d timed out", e.Disk)
+}
+
+// NoDisksAvailable no disks available to write an object
+type NoDisksAvailable struct {
+	Bucket string
+	Object string
+}
+
+func (e NoDisksAvailable) Error() string {
+	return "No disks available to write object: " + e.Bucket + "#" + e.Object
+}
+
+// InsufficientReadQuorum bucket metadata or object data could not be read from enough disks
+type InsufficientReadQuorum struct {
+	Bucket string
+	Disks  int
+	Quorum int
+}
+
+func (e InsufficientReadQuorum) Error() string {
+	return fmt.Sprintf("Read from %d disks of bucket %s, a quorum of %d is required", e.Disks, e.Bucket, e.Quorum)
+}
+
+// XAmzContentSHA256Mismatch payload hash sent by the client does not match the received payload
+type XAmzContentSHA256Mismatch struct {
+	Bucket string
+	Object string
+}
+
+func (e XAmzContentSHA256Mismatch) Error() string {
+	return "The provided 'x-amz-content-sha256' header does not match what was computed: " + e.Bucket + "#" + e.Object
+}
+
+// PreconditionFailed object does not match the conditions requested
+type PreconditionFailed struct {
+	Bucket string
+	Object string
+}
+
+func (e PreconditionFailed) Error() string {
+	return "At least one of the preconditions you specified did not hold: " + e.Bucket + "#" + e.Object
+}
+
 // ChecksumMismatch checksum mismatch
 type ChecksumMismatch struct{}
 
@@ -200,6 +290,12 @@ type BucketNameInvalid GenericBucketError
 // TooManyBuckets - total buckets exceeded
 type TooManyBuckets GenericBucketError
 
+// TooManyObjects - maximum object count of bucket exceeded
+type TooManyObjects GenericBucketError
+
+// QuotaExceeded - quota of bucket exceeded
+type QuotaExceeded GenericBucketError
+
 /// Object related errors
 
 // EntityTooLarge - object size exceeds maximum limit
@@ -212,6 +308,9 @@ type EntityTooLarge struct {
 // ObjectNameInvalid - object name provided is invalid
 type ObjectNameInvalid GenericObjectError
 
+// ObjectImmutable - object is past the bucket's grace period and can no longer be overwritten or deleted
+type ObjectImmutable GenericObjectError
+
 // InvalidDigest - md5 in request header invalid
 type InvalidDigest DigestError
 
@@ -257,11 +356,26 @@ func (e TooManyBuckets) Error() string {
 	return "Bucket limit exceeded beyond 100, cannot create bucket: " + e.Bucket
 }
 
+// Return string an error formatted as the given text
+func (e TooManyObjects) Error() string {
+	return "Object limit of bucket exceeded, cannot create object in bucket: " + e.Bucket
+}
+
+// Return string an error formatted as the given text
+func (e QuotaExceeded) Error() string {
+	return "Quota of bucket exceeded, cannot store data in bucket: " + e.Bucket
+}
+
 // Return string an error formatted as the given text
 func (e ObjectNameInvalid) Error() string {
 	return "Object name invalid: " + e.Bucket + "#" + e.Object
 }
 
+// Return string an error formatted as the given text
+func (e ObjectImmutable) Error() string {
+	return "Object is immutable: " + e.Bucket + "#" + e.Object
+}
+
 // Return string an error formatted as the given text
 func (e EntityTooLarge) Error() string {
 	return e.Bucket + "#" + e.Object + "with " + e.Size + "reached maximum allowed size limit " + e.MaxSize
@@ -305,6 +419,16 @@ func (e InvalidRange) Error() string {
 	return fmt.Sprintf("Invalid range start:%d length:%d", e.Start, e.Length)
 }
 
+// ObjectChangesTruncated changes after the requested sequence or time are no longer all recorded
+type ObjectChangesTruncated struct {
+	Bucket   string
+	Sequence uint64
+}
+
+func (e ObjectChangesTruncated) Error() string {
+	return fmt.Sprintf("Changes of bucket %s are only recorded after sequence %d", e.Bucket, e.Sequence)
+}
+
 /// Multipart related errors
 
 // InvalidUploadID invalid upload id
@@ -323,6 +447,25 @@ func (e InvalidPart) Error() string {
 	return "One or more of the specified parts could not be found"
 }
 
+// PartCorrupted stored data of a part no longer matches its ETag
+type PartCorrupted struct {
+	UploadID   string
+	PartNumber int
+}
+
+func (e PartCorrupted) Error() string {
+	return fmt.Sprintf("Part %d of upload %s does not match its ETag", e.PartNumber, e.UploadID)
+}
+
+// UnsupportedCompression object is compressed with a codec not known to this version
+type UnsupportedCompression struct {
+	Codec string
+}
+
+func (e UnsupportedCompression) Error() string {
+	return "Unsupported compression codec " + e.Codec + ", object may have been written by a newer version"
+}
+
 // InvalidPartOrder parts are not ordered as Requested
 type InvalidPartOrder struct {
 	UploadID string
@@ -338,3 +481,30 @@ type MalformedXML struct{}
 func (e MalformedXML) Error() string {
 	return "Malformed XML"
 }
+
+// MalformedArchive archive entry is not of the form written by bucket export
+type MalformedArchive struct {
+	Entry string
+}
+
+func (e MalformedArchive) Error() string {
+	return "Malformed archive entry: " + e.Entry
+}
+
+// InvalidCopyDest object copied onto itself without replacing its metadata
+type InvalidCopyDest struct {
+	Object string
+}
+
+func (e InvalidCopyDest) Error() string {
+	return "Object copied onto itself without replacing its metadata: " + e.Object
+}
+
+// SliceCorrupted encoded block read from a disk does not match its stored checksum
+type SliceCorrupted struct {
+	Disk int
+}
+
+func (e SliceCorrupted) Error() string {
+	return fmt.Sprintf("Slice read from disk %d found corrupted", e.Disk)
+}
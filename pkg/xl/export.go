@@ -0,0 +1,206 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio/pkg/probe"
+)
+
+// every object is archived as two entries, its metadata followed by its data
+const (
+	archiveMetadataPrefix = "metadata/"
+	archiveDataPrefix     = "data/"
+)
+
+// ExportBucket - write all objects of the bucket into w as a tar stream, in the order of their names.
+// Object data is decoded and verified against its md5sum while written, on a mismatch the export
+// stops with ChecksumMismatch
+func (b bucket) ExportBucket(w io.Writer) *probe.Error {
+	b.lock.RLock()
+	bucketMetadata, err := b.getBucketMetadata()
+	b.lock.RUnlock()
+	if err != nil {
+		return err.Trace()
+	}
+	var objectNames []string
+	for objectName := range bucketMetadata.Buckets[b.getBucketName()].BucketObjects {
+		objectNames = append(objectNames, objectName)
+	}
+	sort.Strings(objectNames)
+
+	tw := tar.NewWriter(w)
+	for _, objectName := range objectNames {
+		// metadata and slices are read under a single object lock, such that a concurrent
+		// overwrite cannot pair the metadata of one version with the data of another
+		objectPath, objMetadata, readers, err := b.openObjectReaders(objectName)
+		if err != nil {
+			return err.Trace(objectName)
+		}
+		if err := b.exportObject(tw, objectName, objectPath, objMetadata, readers); err != nil {
+			return err.Trace(objectName)
+		}
+	}
+	if e := tw.Close(); e != nil {
+		return probe.NewError(e)
+	}
+	return nil
+}
+
+// exportObject - write the metadata and data entries of an object with opened slice readers into tw
+func (b bucket) exportObject(tw *tar.Writer, objectName, objectPath string, objMetadata ObjectMetadata, readers map[int]io.ReadCloser) *probe.Error {
+	metadata, e := json.Marshal(objMetadata)
+	if e == nil {
+		e = tw.WriteHeader(&tar.Header{
+			Name:    archiveMetadataPrefix + objectName,
+			Mode:    0600,
+			Size:    int64(len(metadata)),
+			ModTime: objMetadata.LastModified(),
+		})
+	}
+	if e == nil {
+		_, e = tw.Write(metadata)
+	}
+	if e == nil {
+		e = tw.WriteHeader(&tar.Header{
+			Name:    archiveDataPrefix + objectName,
+			Mode:    0600,
+			Size:    objMetadata.Size,
+			ModTime: objMetadata.LastModified(),
+		})
+	}
+	if e != nil {
+		for _, reader := range readers {
+			reader.Close()
+		}
+		return probe.NewError(e)
+	}
+	// the data is verified against its md5sum while decoded
+	if _, err := b.readObjectTo(objectPath, objMetadata, readers, tw); err != nil {
+		return err.Trace()
+	}
+	return nil
+}
+
+// maxImportCommitAttempts - number of times the index update of an import is retried while other
+// writers advance the index generation
+const maxImportCommitAttempts = 8
+
+// ImportBucket - write all objects of a tar stream written by ExportBucket into the bucket, replacing
+// objects of the same name. Data is verified against the exported md5sum, objects are added to the
+// bucket index once all of them are written. On failure the slices of imported objects not in the
+// index are removed again
+func (b bucket) ImportBucket(r io.Reader) *probe.Error {
+	var objectNames []string
+	err := b.importObjects(r, &objectNames)
+	if err == nil && len(objectNames) > 0 {
+		err = b.commitImportedObjects(objectNames)
+	}
+	if err != nil {
+		b.purgeUnindexedObjects(objectNames)
+		return err.Trace()
+	}
+	return nil
+}
+
+// importObjects - write the objects of a tar stream into the bucket, appending the name of every
+// object to objectNames before it is written
+func (b bucket) importObjects(r io.Reader, objectNames *[]string) *probe.Error {
+	tr := tar.NewReader(r)
+	var objMetadata *ObjectMetadata
+	for {
+		header, e := tr.Next()
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			return probe.NewError(e)
+		}
+		switch {
+		case strings.HasPrefix(header.Name, archiveMetadataPrefix) && objMetadata == nil:
+			metadata, e := ioutil.ReadAll(tr)
+			if e != nil {
+				return probe.NewError(e)
+			}
+			objMetadata = &ObjectMetadata{}
+			if e := json.Unmarshal(metadata, objMetadata); e != nil {
+				return probe.NewError(MalformedArchive{Entry: header.Name})
+			}
+			if objMetadata.Object != strings.TrimPrefix(header.Name, archiveMetadataPrefix) {
+				return probe.NewError(MalformedArchive{Entry: header.Name})
+			}
+		case strings.HasPrefix(header.Name, archiveDataPrefix) && objMetadata != nil:
+			objectName := strings.TrimPrefix(header.Name, archiveDataPrefix)
+			if objectName != objMetadata.Object || header.Size != objMetadata.Size {
+				return probe.NewError(MalformedArchive{Entry: header.Name})
+			}
+			*objectNames = append(*objectNames, objectName)
+			if _, err := b.WriteObject(context.Background(), objectName, tr, header.Size, objMetadata.MD5Sum, objMetadata.Metadata, nil); err != nil {
+				return err.Trace(objectName)
+			}
+			objMetadata = nil
+		default:
+			return probe.NewError(MalformedArchive{Entry: header.Name})
+		}
+	}
+	if objMetadata != nil {
+		return probe.NewError(MalformedArchive{Entry: archiveMetadataPrefix + objMetadata.Object})
+	}
+	return nil
+}
+
+// commitImportedObjects - add imported objects to the bucket index, retrying while the generation
+// is advanced by concurrent writers
+func (b bucket) commitImportedObjects(objectNames []string) *probe.Error {
+	var err *probe.Error
+	for attempt := 0; attempt < maxImportCommitAttempts; attempt++ {
+		var generation uint64
+		generation, err = b.IndexGeneration()
+		if err != nil {
+			return err.Trace()
+		}
+		if _, err = b.CommitObjects(generation, objectNames, nil); err == nil {
+			return nil
+		}
+		if _, ok := err.ToGoError().(PreconditionFailed); !ok {
+			return err.Trace()
+		}
+	}
+	return err.Trace()
+}
+
+// purgeUnindexedObjects - remove the slices of objects not in the bucket index. Objects in the
+// index were overwritten in place and are left as written
+func (b bucket) purgeUnindexedObjects(objectNames []string) {
+	for _, objectName := range objectNames {
+		unlock := b.lockObject(objectName)
+		bucketMetadata, err := b.getBucketMetadata()
+		if err == nil {
+			if _, ok := bucketMetadata.Buckets[b.getBucketName()].BucketObjects[objectName]; !ok {
+				b.removeObjectSlices(normalizeObjectName(objectName), "", nil)
+			}
+		}
+		unlock()
+	}
+}
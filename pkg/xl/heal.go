@@ -17,10 +17,15 @@
 package xl
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"path/filepath"
+	"reflect"
+	"sort"
 
+	"github.com/hashicorp/go-version"
 	"github.com/minio/minio/pkg/probe"
 	"github.com/minio/minio/pkg/xl/block"
 )
@@ -67,3 +72,312 @@ func (xl API) healBuckets() *probe.Error {
 	}
 	return nil
 }
+
+// quorumBucketMetadata select bucket metadata agreed upon by a majority of disks, bucket by bucket,
+// also returns the copies which disagree with the majority. The highest version held by a majority
+// of disks is selected, disks is the number of disks a majority is counted of. Fails with
+// InsufficientReadQuorum if no version or no value of a bucket is held by more than half of the disks
+func quorumBucketMetadata(metadatas map[int]*AllBuckets, disks int) (*AllBuckets, []int, *probe.Error) {
+	var orders []int
+	for order := range metadatas {
+		orders = append(orders, order)
+	}
+	sort.Ints(orders)
+	required := disks/2 + 1
+
+	diverged := make(map[int]struct{})
+	versions := make(map[string][]int)
+	for _, order := range orders {
+		versions[metadatas[order].Version] = append(versions[metadatas[order].Version], order)
+	}
+	quorum := new(AllBuckets)
+	quorum.Buckets = make(map[string]BucketMetadata)
+	agreed, found := 0, false
+	for metadataVersion, versionOrders := range versions {
+		if len(versionOrders) > agreed {
+			agreed = len(versionOrders)
+		}
+		if len(versionOrders) >= required && (!found || isNewerVersion(metadataVersion, quorum.Version)) {
+			quorum.Version, found = metadataVersion, true
+		}
+	}
+	if !found {
+		return nil, nil, probe.NewError(InsufficientReadQuorum{Disks: agreed, Quorum: required})
+	}
+	for metadataVersion, versionOrders := range versions {
+		if metadataVersion == quorum.Version {
+			continue
+		}
+		for _, order := range versionOrders {
+			diverged[order] = struct{}{}
+		}
+	}
+
+	buckets := make(map[string]struct{})
+	for _, metadata := range metadatas {
+		for bucket := range metadata.Buckets {
+			buckets[bucket] = struct{}{}
+		}
+	}
+	for bucket := range buckets {
+		// copies are grouped by value, a copy missing the bucket votes for its absence
+		type candidate struct {
+			metadata BucketMetadata
+			present  bool
+			orders   []int
+		}
+		var candidates []*candidate
+		for _, order := range orders {
+			bucketMetadata, present := metadatas[order].Buckets[bucket]
+			var vote *candidate
+			for _, c := range candidates {
+				if c.present == present && (!present || reflect.DeepEqual(c.metadata, bucketMetadata)) {
+					vote = c
+					break
+				}
+			}
+			if vote == nil {
+				vote = &candidate{metadata: bucketMetadata, present: present}
+				candidates = append(candidates, vote)
+			}
+			vote.orders = append(vote.orders, order)
+		}
+		var majority *candidate
+		agreed := 0
+		for _, c := range candidates {
+			if len(c.orders) > agreed {
+				agreed = len(c.orders)
+			}
+			if len(c.orders) < required {
+				continue
+			}
+			if majority == nil || c.metadata.Generation > majority.metadata.Generation {
+				majority = c
+			}
+		}
+		if majority == nil {
+			return nil, nil, probe.NewError(InsufficientReadQuorum{Bucket: bucket, Disks: agreed, Quorum: required})
+		}
+		if majority.present {
+			quorum.Buckets[bucket] = majority.metadata
+		}
+		for _, c := range candidates {
+			if c == majority {
+				continue
+			}
+			for _, order := range c.orders {
+				diverged[order] = struct{}{}
+			}
+		}
+	}
+	var minority []int
+	for order := range diverged {
+		minority = append(minority, order)
+	}
+	sort.Ints(minority)
+	return quorum, minority, nil
+}
+
+// isNewerVersion - metadata version a is newer than b, versions failing to parse are the oldest
+func isNewerVersion(a, b string) bool {
+	versionA, e := version.NewVersion(a)
+	if e != nil {
+		return false
+	}
+	versionB, e := version.NewVersion(b)
+	if e != nil {
+		return true
+	}
+	return versionA.GreaterThan(versionB)
+}
+
+// RepairBucketMetadata rewrite bucket metadata on disks which disagree with the majority of disks,
+// returns the disks which were repaired
+func (xl API) RepairBucketMetadata() ([]DiskOrder, *probe.Error) {
+	// disks of all nodes, copies are numbered by their position in this list
+	var names []string
+	for name := range xl.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var disks []block.Block
+	var diskOrders []DiskOrder
+	for _, name := range names {
+		nDisks, err := xl.nodes[name].ListDisks()
+		if err != nil {
+			return nil, err.Trace()
+		}
+		var orders []int
+		for order := range nDisks {
+			orders = append(orders, order)
+		}
+		sort.Ints(orders)
+		for _, order := range orders {
+			disks = append(disks, nDisks[order])
+			diskOrders = append(diskOrders, DiskOrder{Node: name, Disk: order})
+		}
+	}
+	metadatas := make(map[int]*AllBuckets)
+	var unreadable []int
+	for copy, disk := range disks {
+		reader, err := disk.Open(filepath.Join(xl.config.XLName, bucketMetadataConfig))
+		if err != nil {
+			unreadable = append(unreadable, copy)
+			continue
+		}
+		metadata := new(AllBuckets)
+		if err := json.NewDecoder(reader).Decode(metadata); err != nil {
+			unreadable = append(unreadable, copy)
+		} else {
+			metadatas[copy] = metadata
+		}
+		reader.Close()
+	}
+	if len(metadatas) == 0 {
+		return nil, probe.NewError(InvalidArgument{})
+	}
+	quorum, minority, err := quorumBucketMetadata(metadatas, len(metadatas))
+	if err != nil {
+		return nil, err.Trace()
+	}
+	minority = append(minority, unreadable...)
+	sort.Ints(minority)
+
+	var repaired []DiskOrder
+	for _, copy := range minority {
+		bucketMetadataWriter, err := disks[copy].CreateFile(filepath.Join(xl.config.XLName, bucketMetadataConfig))
+		if err != nil {
+			return nil, err.Trace()
+		}
+		jenc := json.NewEncoder(bucketMetadataWriter)
+		if err := jenc.Encode(quorum); err != nil {
+			bucketMetadataWriter.CloseAndPurge()
+			return nil, probe.NewError(err)
+		}
+		bucketMetadataWriter.Close()
+		repaired = append(repaired, diskOrders[copy])
+	}
+	return repaired, nil
+}
+
+// repairBucketMetadata rewrite the bucket metadata agreed upon by majority of disks on the disks
+// which disagree with it, fail to decode it or miss it. Copies are read again once the metadata is
+// locked, such that updates committed meanwhile are not overwritten. Repair is best effort, it is
+// skipped while the metadata is being updated and a disk failing the write is left to be repaired
+// on a later read
+func (b bucket) repairBucketMetadata(disks map[int]block.Block) {
+	unlock, ok := tryLockMetadata(b.xlName)
+	if !ok {
+		return
+	}
+	defer unlock()
+	metadatas, unreadable, err := b.readBucketMetadataCopies(disks)
+	if err != nil {
+		return
+	}
+	quorum, minority, err := quorumBucketMetadata(metadatas, len(disks))
+	if err != nil {
+		return
+	}
+	for _, order := range append(minority, unreadable...) {
+		bucketMetadataWriter, err := disks[order].CreateFile(filepath.Join(b.xlName, bucketMetadataConfig))
+		if err != nil {
+			continue
+		}
+		if err := json.NewEncoder(bucketMetadataWriter).Encode(quorum); err != nil {
+			bucketMetadataWriter.CloseAndPurge()
+			continue
+		}
+		commitWriters([]io.WriteCloser{bucketMetadataWriter})
+	}
+}
+
+// CheckMetadataConsistency compare bucket metadata on every disk against the metadata agreed
+// upon by majority of disks, reports the disks out of sync and the fields which diverge
+func (b bucket) CheckMetadataConsistency() (ConsistencyReport, *probe.Error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	report := ConsistencyReport{Bucket: b.name}
+	metadatas := make(map[int]*AllBuckets)
+	for _, node := range b.getNodes() {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return ConsistencyReport{}, err.Trace()
+		}
+		for order, disk := range disks {
+			report.Disks++
+			reader, err := disk.Open(filepath.Join(b.xlName, bucketMetadataConfig))
+			if err != nil {
+				report.Unreadable = append(report.Unreadable, order)
+				continue
+			}
+			metadata := new(AllBuckets)
+			if err := json.NewDecoder(reader).Decode(metadata); err != nil {
+				report.Unreadable = append(report.Unreadable, order)
+			} else {
+				metadatas[order] = metadata
+			}
+			reader.Close()
+		}
+	}
+	sort.Ints(report.Unreadable)
+	if len(metadatas) == 0 {
+		return ConsistencyReport{}, probe.NewError(InvalidArgument{})
+	}
+	quorum, minority, err := quorumBucketMetadata(metadatas, len(metadatas))
+	if err != nil {
+		return ConsistencyReport{}, err.Trace()
+	}
+	majority, inMajority := quorum.Buckets[b.name]
+	for _, order := range minority {
+		bucketMetadata, ok := metadatas[order].Buckets[b.name]
+		if !ok && !inMajority {
+			// disk diverges only in other buckets
+			continue
+		}
+		disk := DiskConsistency{Disk: order, Missing: !ok}
+		if ok && inMajority {
+			fields, err := divergentFields(majority, bucketMetadata)
+			if err != nil {
+				return ConsistencyReport{}, err.Trace()
+			}
+			if len(fields) == 0 {
+				continue
+			}
+			disk.Fields = fields
+		}
+		report.OutOfSync = append(report.OutOfSync, disk)
+	}
+	return report, nil
+}
+
+// divergentFields return the json field names which differ between two bucket metadata
+func divergentFields(expected, actual BucketMetadata) ([]string, *probe.Error) {
+	var expectedFields, actualFields map[string]json.RawMessage
+	for _, v := range []struct {
+		metadata BucketMetadata
+		fields   *map[string]json.RawMessage
+	}{{expected, &expectedFields}, {actual, &actualFields}} {
+		data, err := json.Marshal(v.metadata)
+		if err != nil {
+			return nil, probe.NewError(err)
+		}
+		if err := json.Unmarshal(data, v.fields); err != nil {
+			return nil, probe.NewError(err)
+		}
+	}
+	var fields []string
+	for field, value := range expectedFields {
+		if !bytes.Equal(value, actualFields[field]) {
+			fields = append(fields, field)
+		}
+	}
+	for field := range actualFields {
+		if _, ok := expectedFields[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
@@ -0,0 +1,55 @@
+/*
+ * Minio Cloud Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import (
+	"os"
+	"time"
+
+	"github.com/minio/minio/pkg/probe"
+)
+
+// isObjectImmutable - verify if an object is past the grace period from its creation, after which
+// it may no longer be overwritten or deleted
+func isObjectImmutable(created time.Time, immutableAfter time.Duration) bool {
+	if immutableAfter <= 0 {
+		return false
+	}
+	return !time.Now().UTC().Before(created.Add(immutableAfter))
+}
+
+// checkObjectMutable - verify an existing object of the bucket may still be overwritten or deleted,
+// objects not in the bucket index are always mutable
+func (b bucket) checkObjectMutable(bucketMetadata BucketMetadata, objectName string) *probe.Error {
+	if bucketMetadata.ImmutableAfter <= 0 {
+		return nil
+	}
+	if _, ok := bucketMetadata.BucketObjects[objectName]; !ok {
+		return nil
+	}
+	objMetadata, err := b.readObjectMetadata(b.getObjectPath(objectName))
+	if err != nil {
+		if os.IsNotExist(err.ToGoError()) {
+			return nil
+		}
+		return err.Trace()
+	}
+	if isObjectImmutable(objMetadata.Created, bucketMetadata.ImmutableAfter) {
+		return probe.NewError(ObjectImmutable{Bucket: b.getBucketName(), Object: objectName})
+	}
+	return nil
+}
@@ -17,6 +17,7 @@
 package xl
 
 import (
+	"context"
 	"io"
 
 	"github.com/minio/minio/pkg/probe"
@@ -43,10 +44,11 @@ type CloudStorage interface {
 	ListObjects(string, BucketResourcesMetadata) ([]ObjectMetadata, BucketResourcesMetadata, *probe.Error)
 
 	// Object operations
-	GetObject(w io.Writer, bucket, object string, start, length int64) (int64, *probe.Error)
+	GetObject(ctx context.Context, w io.Writer, bucket, object string, start, length int64) (int64, *probe.Error)
 	GetObjectMetadata(bucket, object string) (ObjectMetadata, *probe.Error)
 	// bucket, object, expectedMD5Sum, size, reader, metadata, signature
-	CreateObject(string, string, string, int64, io.Reader, map[string]string, *signature4.Sign) (ObjectMetadata, *probe.Error)
+	CreateObject(context.Context, string, string, string, int64, io.Reader, map[string]string, *signature4.Sign) (ObjectMetadata, *probe.Error)
+	DeleteObjects(bucket string, objects []string) *probe.Error
 
 	Multipart
 }
@@ -55,8 +57,8 @@ type CloudStorage interface {
 type Multipart interface {
 	NewMultipartUpload(bucket, key, contentType string) (string, *probe.Error)
 	AbortMultipartUpload(bucket, key, uploadID string) *probe.Error
-	CreateObjectPart(string, string, string, int, string, string, int64, io.Reader, *signature4.Sign) (string, *probe.Error)
-	CompleteMultipartUpload(bucket, key, uploadID string, data io.Reader, signature *signature4.Sign) (ObjectMetadata, *probe.Error)
+	CreateObjectPart(context.Context, string, string, string, int, string, string, int64, io.Reader, *signature4.Sign) (string, *probe.Error)
+	CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, data io.Reader, signature *signature4.Sign) (ObjectMetadata, *probe.Error)
 	ListMultipartUploads(string, BucketMultipartResourcesMetadata) (BucketMultipartResourcesMetadata, *probe.Error)
 	ListObjectParts(string, string, ObjectResourcesMetadata) (ObjectResourcesMetadata, *probe.Error)
 }
@@ -0,0 +1,71 @@
+/*
+ * Minio Cloud Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// isValidLifecycleRules - verify all rules expire objects after at least one day
+func isValidLifecycleRules(rules []LifecycleRule) bool {
+	for _, rule := range rules {
+		if rule.Days <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// getObjectExpiration - expiry of an object from the matching lifecycle rule, when more than one
+// rule matches the earliest expiry wins. Like S3 the expiry is rounded up to the next midnight UTC
+func getObjectExpiration(rules []LifecycleRule, object string, created time.Time) (time.Time, string) {
+	var matched *LifecycleRule
+	for i := range rules {
+		if !strings.HasPrefix(object, rules[i].Prefix) {
+			continue
+		}
+		if matched == nil || rules[i].Days < matched.Days {
+			matched = &rules[i]
+		}
+	}
+	if matched == nil {
+		return time.Time{}, ""
+	}
+	expiry := created.UTC().AddDate(0, 0, matched.Days)
+	midnight := time.Date(expiry.Year(), expiry.Month(), expiry.Day(), 0, 0, 0, 0, time.UTC)
+	if expiry.After(midnight) {
+		midnight = midnight.AddDate(0, 0, 1)
+	}
+	return midnight, matched.ID
+}
+
+// setObjectExpiration - set expiry of object metadata from the bucket lifecycle rules
+func setObjectExpiration(objMetadata ObjectMetadata, rules []LifecycleRule) ObjectMetadata {
+	objMetadata.Expiration, objMetadata.ExpirationRuleID = getObjectExpiration(rules, objMetadata.Object, objMetadata.Created)
+	return objMetadata
+}
+
+// ExpirationHeader - value of x-amz-expiration for the object, empty if no lifecycle rule expires it
+func (o ObjectMetadata) ExpirationHeader() string {
+	if o.Expiration.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("expiry-date=\"%s\", rule-id=\"%s\"", o.Expiration.Format(http.TimeFormat), o.ExpirationRuleID)
+}
@@ -0,0 +1,224 @@
+/*
+ * Minio Cloud Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio/pkg/probe"
+)
+
+// operations recorded in request metrics
+const (
+	operationGetObject    = "GetObject"
+	operationPutObject    = "PutObject"
+	operationHeadObject   = "HeadObject"
+	operationDeleteObject = "DeleteObject"
+	operationListObjects  = "ListObjects"
+)
+
+// metricsNamespace - prefix of all exported metric names
+const metricsNamespace = "xl_bucket"
+
+// latencyBuckets - upper bounds in seconds of the request latency histogram
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
+// operationMetrics - counters of a single operation on a bucket
+type operationMetrics struct {
+	requests   int64
+	bytes      int64
+	latencySum float64
+	latencies  []int64
+	errors     map[string]int64
+}
+
+// recordRequest - record an operation on bucket, bytes are counted only for successful requests
+func (r *readStats) recordRequest(bucket, operation string, start time.Time, bytes int64, err *probe.Error) {
+	if r == nil {
+		return
+	}
+	latency := time.Since(start).Seconds()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.operations[bucket] == nil {
+		r.operations[bucket] = make(map[string]*operationMetrics)
+	}
+	metrics, ok := r.operations[bucket][operation]
+	if !ok {
+		metrics = &operationMetrics{
+			latencies: make([]int64, len(latencyBuckets)),
+			errors:    make(map[string]int64),
+		}
+		r.operations[bucket][operation] = metrics
+	}
+	metrics.requests++
+	metrics.latencySum += latency
+	for i, bound := range latencyBuckets {
+		if latency <= bound {
+			metrics.latencies[i]++
+		}
+	}
+	if err != nil {
+		metrics.errors[getErrorType(err)]++
+		return
+	}
+	metrics.bytes += bytes
+}
+
+// requestMeter - records a read request once the data it opened is read to its end, fails to be
+// read or is closed, with the bytes read until then
+type requestMeter struct {
+	lock   sync.Mutex
+	record func(bytes int64, err *probe.Error)
+	bytes  int64
+	done   bool
+}
+
+// newRequestMeter - meter of a read request on the bucket started at start
+func (b bucket) newRequestMeter(operation string, start time.Time) *requestMeter {
+	return &requestMeter{
+		record: func(bytes int64, err *probe.Error) {
+			b.stats.recordRequest(b.name, operation, start, bytes, err)
+		},
+	}
+}
+
+// count - count bytes read, the request is recorded on the first error, io.EOF completes it
+func (m *requestMeter) count(n int, err error) {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.done {
+		return
+	}
+	m.bytes += int64(n)
+	switch {
+	case err == nil:
+		return
+	case err == io.EOF:
+		m.record(m.bytes, nil)
+	default:
+		m.record(m.bytes, probe.NewError(err))
+	}
+	m.done = true
+}
+
+// close - record the request with the bytes read so far, if not recorded already
+func (m *requestMeter) close() {
+	m.count(0, io.EOF)
+}
+
+// meteredReader - reader recording the read request it was opened by once it completes
+type meteredReader struct {
+	io.ReadCloser
+	meter *requestMeter
+}
+
+func (r meteredReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.meter.count(n, err)
+	return n, err
+}
+
+func (r meteredReader) Close() error {
+	r.meter.close()
+	return r.ReadCloser.Close()
+}
+
+// getErrorType - type name of the underlying error, used as the error label
+func getErrorType(err *probe.Error) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", err.ToGoError()), "*")
+}
+
+// MetricsText - render request counts, latencies, bytes transferred and errors of the bucket
+// in prometheus text exposition format
+func (b bucket) MetricsText() string {
+	if b.stats == nil {
+		return ""
+	}
+	b.stats.lock.Lock()
+	defer b.stats.lock.Unlock()
+
+	operations := b.stats.operations[b.name]
+	var names []string
+	for operation := range operations {
+		names = append(names, operation)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	labels := func(operation string) string {
+		return fmt.Sprintf("bucket=%s,operation=%s", quoteLabelValue(b.name), quoteLabelValue(operation))
+	}
+	writeHeader := func(name, help, kind string) {
+		fmt.Fprintf(&buf, "# HELP %s_%s %s\n", metricsNamespace, name, help)
+		fmt.Fprintf(&buf, "# TYPE %s_%s %s\n", metricsNamespace, name, kind)
+	}
+
+	writeHeader("requests_total", "Total number of requests.", "counter")
+	for _, operation := range names {
+		fmt.Fprintf(&buf, "%s_requests_total{%s} %d\n", metricsNamespace, labels(operation), operations[operation].requests)
+	}
+	writeHeader("request_duration_seconds", "Request latency in seconds.", "histogram")
+	for _, operation := range names {
+		metrics := operations[operation]
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(&buf, "%s_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", metricsNamespace,
+				labels(operation), strconv.FormatFloat(bound, 'g', -1, 64), metrics.latencies[i])
+		}
+		fmt.Fprintf(&buf, "%s_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", metricsNamespace,
+			labels(operation), metrics.requests)
+		fmt.Fprintf(&buf, "%s_request_duration_seconds_sum{%s} %s\n", metricsNamespace, labels(operation),
+			strconv.FormatFloat(metrics.latencySum, 'g', -1, 64))
+		fmt.Fprintf(&buf, "%s_request_duration_seconds_count{%s} %d\n", metricsNamespace, labels(operation), metrics.requests)
+	}
+	writeHeader("bytes_total", "Total number of object bytes transferred.", "counter")
+	for _, operation := range names {
+		fmt.Fprintf(&buf, "%s_bytes_total{%s} %d\n", metricsNamespace, labels(operation), operations[operation].bytes)
+	}
+	writeHeader("request_errors_total", "Total number of failed requests by error type.", "counter")
+	for _, operation := range names {
+		var errorTypes []string
+		for errorType := range operations[operation].errors {
+			errorTypes = append(errorTypes, errorType)
+		}
+		sort.Strings(errorTypes)
+		for _, errorType := range errorTypes {
+			fmt.Fprintf(&buf, "%s_request_errors_total{%s,error=%s} %d\n", metricsNamespace, labels(operation),
+				quoteLabelValue(errorType), operations[operation].errors[errorType])
+		}
+	}
+	return buf.String()
+}
+
+// labelValueEscaper - escapes of label values in prometheus text exposition format, only backslash,
+// double quote and line feed are escaped
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quoteLabelValue - label value escaped and quoted for prometheus text exposition format
+func quoteLabelValue(value string) string {
+	return `"` + labelValueEscaper.Replace(value) + `"`
+}
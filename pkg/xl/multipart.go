@@ -18,6 +18,7 @@ package xl
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"crypto/sha512"
 	"encoding/base64"
@@ -49,7 +50,7 @@ func (xl API) NewMultipartUpload(bucket, key, contentType string) (string, *prob
 	if !IsValidBucket(bucket) {
 		return "", probe.NewError(BucketNameInvalid{Bucket: bucket})
 	}
-	if !IsValidObjectName(key) {
+	if !IsValidObjectName(key) || isReservedObjectName(key, xl.config.ReservedObjectPrefixes) {
 		return "", probe.NewError(ObjectNameInvalid{Object: key})
 	}
 	//	if len(xl.config.NodeDiskMap) > 0 {
@@ -109,10 +110,11 @@ func (xl API) AbortMultipartUpload(bucket, key, uploadID string) *probe.Error {
 }
 
 // CreateObjectPart - create a part in a multipart session
-func (xl API) CreateObjectPart(bucket, key, uploadID string, partID int, contentType, expectedMD5Sum string, size int64, data io.Reader, signature *signature4.Sign) (string, *probe.Error) {
-	xl.lock.Lock()
-	etag, err := xl.createObjectPart(bucket, key, uploadID, partID, "", expectedMD5Sum, size, data, signature)
-	xl.lock.Unlock()
+func (xl API) CreateObjectPart(ctx context.Context, bucket, key, uploadID string, partID int, contentType, expectedMD5Sum string, size int64, data io.Reader, signature *signature4.Sign) (string, *probe.Error) {
+	// parts are uploaded concurrently, uploads of the same part are serialized
+	unlock := xl.lockObject(uploadID + "/" + strconv.Itoa(partID))
+	etag, err := xl.createObjectPart(ctx, bucket, key, uploadID, partID, "", expectedMD5Sum, size, data, signature)
+	unlock()
 	// possible free
 	debug.FreeOSMemory()
 
@@ -120,7 +122,7 @@ func (xl API) CreateObjectPart(bucket, key, uploadID string, partID int, content
 }
 
 // createObject - internal wrapper function called by CreateObjectPart
-func (xl API) createObjectPart(bucket, key, uploadID string, partID int, contentType, expectedMD5Sum string, size int64, data io.Reader, signature *signature4.Sign) (string, *probe.Error) {
+func (xl API) createObjectPart(ctx context.Context, bucket, key, uploadID string, partID int, contentType, expectedMD5Sum string, size int64, data io.Reader, signature *signature4.Sign) (string, *probe.Error) {
 	if !IsValidBucket(bucket) {
 		return "", probe.NewError(BucketNameInvalid{Bucket: bucket})
 	}
@@ -146,7 +148,7 @@ func (xl API) createObjectPart(bucket, key, uploadID string, partID int, content
 				}
 				expectedMD5Sum = hex.EncodeToString(expectedMD5SumBytes)
 			}
-			partMetadata, err := xl.putObjectPart(bucket, key, expectedMD5Sum, uploadID, partID, data, size, metadata, signature)
+			partMetadata, err := xl.putObjectPart(ctx, bucket, key, expectedMD5Sum, uploadID, partID, data, size, metadata, signature)
 			if err != nil {
 				return "", err.Trace()
 			}
@@ -156,16 +158,24 @@ func (xl API) createObjectPart(bucket, key, uploadID string, partID int, content
 	if !xl.storedBuckets.Exists(bucket) {
 		return "", probe.NewError(BucketNotFound{Bucket: bucket})
 	}
+	xl.lock.RLock()
 	strBucket := xl.storedBuckets.Get(bucket).(storedBucket)
 	// Verify upload id
 	if strBucket.multiPartSession[key].UploadID != uploadID {
+		xl.lock.RUnlock()
 		return "", probe.NewError(InvalidUploadID{UploadID: uploadID})
 	}
 
 	// get object key
-	parts := strBucket.partMetadata[key]
-	if _, ok := parts[partID]; ok {
-		return parts[partID].ETag, nil
+	if part, ok := strBucket.partMetadata[key][partID]; ok {
+		xl.lock.RUnlock()
+		return part.ETag, nil
+	}
+	usage := strBucket.usage()
+	partCache := xl.multiPartObjects[uploadID]
+	xl.lock.RUnlock()
+	if quota := strBucket.bucketMetadata.Quota; quota > 0 && usage+size > quota {
+		return "", probe.NewError(QuotaExceeded{Bucket: bucket})
 	}
 
 	if contentType == "" {
@@ -194,7 +204,7 @@ func (xl API) createObjectPart(bucket, key, uploadID string, partID int, content
 		if length != 0 {
 			hash.Write(byteBuffer[0:length])
 			sha256hash.Write(byteBuffer[0:length])
-			ok := xl.multiPartObjects[uploadID].Append(partID, byteBuffer[0:length])
+			ok := partCache.Append(partID, byteBuffer[0:length])
 			if !ok {
 				return "", probe.NewError(InternalError{})
 			}
@@ -203,7 +213,7 @@ func (xl API) createObjectPart(bucket, key, uploadID string, partID int, content
 		}
 	}
 	if totalLength != size {
-		xl.multiPartObjects[uploadID].Delete(partID)
+		partCache.Delete(partID)
 		return "", probe.NewError(IncompleteBody{Bucket: bucket, Object: key})
 	}
 	if err != io.EOF {
@@ -238,8 +248,15 @@ func (xl API) createObjectPart(bucket, key, uploadID string, partID int, content
 		Size:         totalLength,
 	}
 
-	parts[partID] = newPart
-	strBucket.partMetadata[key] = parts
+	xl.lock.Lock()
+	defer xl.lock.Unlock()
+	strBucket = xl.storedBuckets.Get(bucket).(storedBucket)
+	// the upload may have been completed or aborted while the part was read
+	if strBucket.multiPartSession[key].UploadID != uploadID {
+		partCache.Delete(partID)
+		return "", probe.NewError(InvalidUploadID{UploadID: uploadID})
+	}
+	strBucket.partMetadata[key][partID] = newPart
 	multiPartSession := strBucket.multiPartSession[key]
 	multiPartSession.TotalParts++
 	strBucket.multiPartSession[key] = multiPartSession
@@ -258,10 +275,33 @@ func (xl API) cleanupMultipartSession(bucket, key, uploadID string) {
 	xl.storedBuckets.Set(bucket, storedBucket)
 }
 
-func (xl API) mergeMultipart(parts *CompleteMultipartUpload, uploadID string, fullObjectWriter *io.PipeWriter) {
+// verifyMultipartParts - verify the stored data of every part still matches the ETag recorded
+// when it was uploaded, such that corruption since upload fails completion before assembling
+func verifyMultipartParts(storedParts map[int]PartMetadata, partCache *data.Cache, uploadID string, parts *CompleteMultipartUpload) *probe.Error {
+	for _, part := range parts.Part {
+		storedPart, ok := storedParts[part.PartNumber]
+		if !ok {
+			return probe.NewError(InvalidPart{})
+		}
+		object, ok := partCache.Get(part.PartNumber)
+		if !ok {
+			return probe.NewError(InvalidPart{})
+		}
+		hasher := md5.New()
+		if _, err := io.Copy(hasher, bytes.NewReader(object)); err != nil {
+			return probe.NewError(err)
+		}
+		if hex.EncodeToString(hasher.Sum(nil)) != storedPart.ETag {
+			return probe.NewError(PartCorrupted{UploadID: uploadID, PartNumber: part.PartNumber})
+		}
+	}
+	return nil
+}
+
+func mergeMultipart(parts *CompleteMultipartUpload, partCache *data.Cache, fullObjectWriter *io.PipeWriter) {
 	for _, part := range parts.Part {
 		recvMD5 := part.ETag
-		object, ok := xl.multiPartObjects[uploadID].Get(part.PartNumber)
+		object, ok := partCache.Get(part.PartNumber)
 		if ok == false {
 			fullObjectWriter.CloseWithError(probe.WrapError(probe.NewError(InvalidPart{})))
 			return
@@ -289,30 +329,35 @@ func (xl API) mergeMultipart(parts *CompleteMultipartUpload, uploadID string, fu
 }
 
 // CompleteMultipartUpload - complete a multipart upload and persist the data
-func (xl API) CompleteMultipartUpload(bucket, key, uploadID string, data io.Reader, signature *signature4.Sign) (ObjectMetadata, *probe.Error) {
-	xl.lock.Lock()
-	defer xl.lock.Unlock()
-	size := int64(xl.multiPartObjects[uploadID].Stats().Bytes)
-	fullObjectReader, err := xl.completeMultipartUploadV2(bucket, key, uploadID, data, signature)
+func (xl API) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, data io.Reader, signature *signature4.Sign) (ObjectMetadata, *probe.Error) {
+	// the upload is completed once, the assembled object is written as any other
+	defer xl.lockObject(bucket + "/" + key)()
+	fullObjectReader, size, parts, err := xl.completeMultipartUploadV2(bucket, key, uploadID, data, signature)
 	if err != nil {
 		return ObjectMetadata{}, err.Trace()
 	}
-	objectMetadata, err := xl.createObject(bucket, key, "", "", size, fullObjectReader, nil)
+	// part boundaries are retained with the object metadata, such that individual parts can be
+	// looked up later on
+	objectMetadata, err := xl.createObject(ctx, bucket, key, "", size, fullObjectReader, nil, parts, nil)
 	if err != nil {
 		// No need to call internal cleanup functions here, caller should call AbortMultipartUpload()
 		// which would in-turn cleanup properly in accordance with S3 Spec
 		return ObjectMetadata{}, err.Trace()
 	}
+	xl.lock.Lock()
 	xl.cleanupMultipartSession(bucket, key, uploadID)
+	xl.lock.Unlock()
 	return objectMetadata, nil
 }
 
-func (xl API) completeMultipartUploadV2(bucket, key, uploadID string, data io.Reader, signature *signature4.Sign) (io.Reader, *probe.Error) {
+// completeMultipartUploadV2 - verify the parts of the upload and return a reader assembling them, along
+// with the size of the object and the parts making it up
+func (xl API) completeMultipartUploadV2(bucket, key, uploadID string, data io.Reader, signature *signature4.Sign) (io.Reader, int64, []PartMetadata, *probe.Error) {
 	if !IsValidBucket(bucket) {
-		return nil, probe.NewError(BucketNameInvalid{Bucket: bucket})
+		return nil, 0, nil, probe.NewError(BucketNameInvalid{Bucket: bucket})
 	}
 	if !IsValidObjectName(key) {
-		return nil, probe.NewError(ObjectNameInvalid{Object: key})
+		return nil, 0, nil, probe.NewError(ObjectNameInvalid{Object: key})
 	}
 
 	// TODO: multipart support for xl is broken, since we haven't finalized the format in which
@@ -324,39 +369,64 @@ func (xl API) completeMultipartUploadV2(bucket, key, uploadID string, data io.Re
 	//	}
 
 	if !xl.storedBuckets.Exists(bucket) {
-		return nil, probe.NewError(BucketNotFound{Bucket: bucket})
-	}
-	storedBucket := xl.storedBuckets.Get(bucket).(storedBucket)
-	// Verify upload id
-	if storedBucket.multiPartSession[key].UploadID != uploadID {
-		return nil, probe.NewError(InvalidUploadID{UploadID: uploadID})
+		return nil, 0, nil, probe.NewError(BucketNotFound{Bucket: bucket})
 	}
 	partBytes, err := ioutil.ReadAll(data)
 	if err != nil {
-		return nil, probe.NewError(err)
+		return nil, 0, nil, probe.NewError(err)
 	}
 	if signature != nil {
 		partHashBytes := sha256.Sum256(partBytes)
 		ok, err := signature.DoesSignatureMatch(hex.EncodeToString(partHashBytes[:]))
 		if err != nil {
-			return nil, err.Trace()
+			return nil, 0, nil, err.Trace()
 		}
 		if !ok {
-			return nil, probe.NewError(SignDoesNotMatch{})
+			return nil, 0, nil, probe.NewError(SignDoesNotMatch{})
 		}
 	}
 	parts := &CompleteMultipartUpload{}
 	if err := xml.Unmarshal(partBytes, parts); err != nil {
-		return nil, probe.NewError(MalformedXML{})
+		return nil, 0, nil, probe.NewError(MalformedXML{})
 	}
 	if !sort.IsSorted(completedParts(parts.Part)) {
-		return nil, probe.NewError(InvalidPartOrder{})
+		return nil, 0, nil, probe.NewError(InvalidPartOrder{})
+	}
+
+	xl.lock.RLock()
+	storedBucket := xl.storedBuckets.Get(bucket).(storedBucket)
+	// Verify upload id
+	if storedBucket.multiPartSession[key].UploadID != uploadID {
+		xl.lock.RUnlock()
+		return nil, 0, nil, probe.NewError(InvalidUploadID{UploadID: uploadID})
+	}
+	// parts completed are copied, such that parts uploaded meanwhile do not affect them
+	storedParts := make(map[int]PartMetadata)
+	for _, part := range parts.Part {
+		if storedPart, ok := storedBucket.partMetadata[key][part.PartNumber]; ok {
+			storedParts[part.PartNumber] = storedPart
+		}
+	}
+	partCache := xl.multiPartObjects[uploadID]
+	xl.lock.RUnlock()
+	if err := verifyMultipartParts(storedParts, partCache, uploadID, parts); err != nil {
+		return nil, 0, nil, err.Trace()
+	}
+
+	// offsets of the parts in the assembled object
+	var objectParts []PartMetadata
+	var offset int64
+	for _, part := range parts.Part {
+		partMetadata := storedParts[part.PartNumber]
+		partMetadata.Offset = offset
+		offset += partMetadata.Size
+		objectParts = append(objectParts, partMetadata)
 	}
 
 	fullObjectReader, fullObjectWriter := io.Pipe()
-	go xl.mergeMultipart(parts, uploadID, fullObjectWriter)
+	go mergeMultipart(parts, partCache, fullObjectWriter)
 
-	return fullObjectReader, nil
+	return fullObjectReader, int64(partCache.Stats().Bytes), objectParts, nil
 }
 
 // byKey is a sortable interface for UploadMetadata slice
@@ -369,8 +439,8 @@ func (a byKey) Less(i, j int) bool { return a[i].Key < a[j].Key }
 // ListMultipartUploads - list incomplete multipart sessions for a given bucket
 func (xl API) ListMultipartUploads(bucket string, resources BucketMultipartResourcesMetadata) (BucketMultipartResourcesMetadata, *probe.Error) {
 	// TODO handle delimiter, low priority
-	xl.lock.Lock()
-	defer xl.lock.Unlock()
+	xl.lock.RLock()
+	defer xl.lock.RUnlock()
 
 	if !IsValidBucket(bucket) {
 		return BucketMultipartResourcesMetadata{}, probe.NewError(BucketNameInvalid{Bucket: bucket})
@@ -444,8 +514,8 @@ func (a partNumber) Less(i, j int) bool { return a[i].PartNumber < a[j].PartNumb
 // ListObjectParts - list parts from incomplete multipart session for a given object
 func (xl API) ListObjectParts(bucket, key string, resources ObjectResourcesMetadata) (ObjectResourcesMetadata, *probe.Error) {
 	// Verify upload id
-	xl.lock.Lock()
-	defer xl.lock.Unlock()
+	xl.lock.RLock()
+	defer xl.lock.RUnlock()
 
 	if !IsValidBucket(bucket) {
 		return ObjectResourcesMetadata{}, probe.NewError(BucketNameInvalid{Bucket: bucket})
@@ -504,11 +574,5 @@ func (xl API) ListObjectParts(bucket, key string, resources ObjectResourcesMetad
 
 // evictedPart - call back function called by caching module during individual cache evictions
 func (xl API) evictedPart(a ...interface{}) {
-	// loop through all buckets
-	buckets := xl.storedBuckets.GetAll()
-	for bucketName, bucket := range buckets {
-		b := bucket.(storedBucket)
-		xl.storedBuckets.Set(bucketName, b)
-	}
 	debug.FreeOSMemory()
 }
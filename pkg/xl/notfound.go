@@ -0,0 +1,111 @@
+/*
+ * Minio Cloud Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultNotFoundCacheSize - maximum number of nonexistent objects remembered, if not configured
+const defaultNotFoundCacheSize = 1024
+
+// notFoundCache - objects recently found not to exist, each remembered until it expires
+type notFoundCache struct {
+	lock    *sync.Mutex
+	expires map[string]time.Time
+}
+
+// newNotFoundCache - instantiate a new not found cache
+func newNotFoundCache() *notFoundCache {
+	return &notFoundCache{
+		lock:    new(sync.Mutex),
+		expires: make(map[string]time.Time),
+	}
+}
+
+// add - remember key as not found until ttl elapses, when full expired keys are dropped
+// first and then the keys expiring soonest
+func (n *notFoundCache) add(key string, ttl time.Duration, size int) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	now := time.Now()
+	if _, ok := n.expires[key]; !ok && len(n.expires) >= size {
+		for k, expiry := range n.expires {
+			if !now.Before(expiry) {
+				delete(n.expires, k)
+			}
+		}
+		for len(n.expires) >= size {
+			var oldest string
+			var oldestExpiry time.Time
+			for k, expiry := range n.expires {
+				if oldest == "" || expiry.Before(oldestExpiry) {
+					oldest, oldestExpiry = k, expiry
+				}
+			}
+			delete(n.expires, oldest)
+		}
+	}
+	n.expires[key] = now.Add(ttl)
+}
+
+// contains - verify if key is remembered as not found
+func (n *notFoundCache) contains(key string) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	expiry, ok := n.expires[key]
+	if !ok {
+		return false
+	}
+	if !time.Now().Before(expiry) {
+		delete(n.expires, key)
+		return false
+	}
+	return true
+}
+
+// remove - forget key, it exists now
+func (n *notFoundCache) remove(key string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	delete(n.expires, key)
+}
+
+// isCachedNotFound - verify if object was recently found not to exist
+func (b bucket) isCachedNotFound(objectName string) bool {
+	if b.stats == nil || b.notFoundTTL <= 0 {
+		return false
+	}
+	return b.stats.notFound.contains(b.name + "/" + objectName)
+}
+
+// cacheNotFound - remember object as not existing
+func (b bucket) cacheNotFound(objectName string) {
+	if b.stats == nil || b.notFoundTTL <= 0 {
+		return
+	}
+	b.stats.notFound.add(b.name+"/"+objectName, b.notFoundTTL, b.notFoundSize)
+}
+
+// forgetNotFound - forget object as not existing, called once it is written
+func (b bucket) forgetNotFound(objectName string) {
+	if b.stats == nil {
+		return
+	}
+	b.stats.notFound.remove(b.name + "/" + objectName)
+}
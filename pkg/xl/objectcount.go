@@ -0,0 +1,34 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import "github.com/minio/minio/pkg/probe"
+
+// checkObjectCount - verify writing an object does not take the bucket beyond its maximum object
+// count, counted from the bucket index. Overwrites of objects already in the index do not add to it
+func (b bucket) checkObjectCount(bucketMetadata BucketMetadata, objectName string) *probe.Error {
+	if bucketMetadata.MaxObjectCount <= 0 {
+		return nil
+	}
+	if _, ok := bucketMetadata.BucketObjects[objectName]; ok {
+		return nil
+	}
+	if len(bucketMetadata.BucketObjects) >= bucketMetadata.MaxObjectCount {
+		return probe.NewError(TooManyObjects{Bucket: b.getBucketName()})
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import "sync"
+
+// objectLocker - locks of the objects of a bucket, keyed by normalized object name. Locks are
+// dropped once released by all holders, such that only objects in use are tracked
+type objectLocker struct {
+	lock  *sync.Mutex
+	locks map[string]*objectLock
+}
+
+// objectLock - lock of a single object and the number of its holders and waiters
+type objectLock struct {
+	*sync.RWMutex
+	refs int
+}
+
+// newObjectLocker - instantiate a new object locker
+func newObjectLocker() *objectLocker {
+	return &objectLocker{
+		lock:  new(sync.Mutex),
+		locks: make(map[string]*objectLock),
+	}
+}
+
+// get - reference the lock of an object, created on first use
+func (l *objectLocker) get(objectName string) *objectLock {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	objLock, ok := l.locks[objectName]
+	if !ok {
+		objLock = &objectLock{RWMutex: new(sync.RWMutex)}
+		l.locks[objectName] = objLock
+	}
+	objLock.refs++
+	return objLock
+}
+
+// put - drop a reference to the lock of an object, the lock is forgotten with its last reference
+func (l *objectLocker) put(objectName string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	objLock := l.locks[objectName]
+	objLock.refs--
+	if objLock.refs == 0 {
+		delete(l.locks, objectName)
+	}
+}
+
+// lockObject - hold the bucket read lock and the write lock of an object, writes of the same object
+// are serialized while other objects are read and written concurrently. Returns the function
+// releasing both locks
+func (b bucket) lockObject(objectName string) func() {
+	objectName = normalizeObjectName(objectName)
+	b.lock.RLock()
+	objLock := b.objectLocks.get(objectName)
+	objLock.Lock()
+	return func() {
+		objLock.Unlock()
+		b.objectLocks.put(objectName)
+		b.lock.RUnlock()
+	}
+}
+
+// rlockObject - hold the bucket read lock and the read lock of an object, reads of the same object
+// proceed concurrently. Returns the function releasing both locks
+func (b bucket) rlockObject(objectName string) func() {
+	objectName = normalizeObjectName(objectName)
+	b.lock.RLock()
+	objLock := b.objectLocks.get(objectName)
+	objLock.RLock()
+	return func() {
+		objLock.RUnlock()
+		b.objectLocks.put(objectName)
+		b.lock.RUnlock()
+	}
+}
+
+// lockObject - hold the write lock of an object kept by the API, keyed by bucket and object name.
+// The API lock only guards the state kept in memory and is never held while objects are read or
+// written. Returns the function releasing the lock
+func (xl API) lockObject(objectKey string) func() {
+	objLock := xl.objectLocks.get(objectKey)
+	objLock.Lock()
+	return func() {
+		objLock.Unlock()
+		xl.objectLocks.put(objectKey)
+	}
+}
+
+// rlockObject - hold the read lock of an object kept by the API. Returns the function releasing it
+func (xl API) rlockObject(objectKey string) func() {
+	objLock := xl.objectLocks.get(objectKey)
+	objLock.RLock()
+	return func() {
+		objLock.RUnlock()
+		xl.objectLocks.put(objectKey)
+	}
+}
+
+// metadataLocks - locks of the bucket metadata keyed by XL name. Metadata of all buckets of an XL
+// is kept in a single file, and bucket values are recreated on every listing, so the locks are kept
+// apart from them. A lock is held while its channel is full
+var metadataLocks = struct {
+	sync.Mutex
+	locks map[string]chan struct{}
+}{locks: make(map[string]chan struct{})}
+
+// getMetadataLock - lock of the bucket metadata of an XL, created on first use
+func getMetadataLock(xlName string) chan struct{} {
+	metadataLocks.Lock()
+	defer metadataLocks.Unlock()
+	lock, ok := metadataLocks.locks[xlName]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		metadataLocks.locks[xlName] = lock
+	}
+	return lock
+}
+
+// lockMetadata - serialize read-modify-write of the bucket metadata of an XL, such that concurrent
+// updates do not lose each other's changes. Returns the function releasing the lock
+func lockMetadata(xlName string) func() {
+	lock := getMetadataLock(xlName)
+	lock <- struct{}{}
+	return func() { <-lock }
+}
+
+// tryLockMetadata - lock the bucket metadata of an XL only if no update is in progress
+func tryLockMetadata(xlName string) (func(), bool) {
+	lock := getMetadataLock(xlName)
+	select {
+	case lock <- struct{}{}:
+		return func() { <-lock }, true
+	default:
+		return nil, false
+	}
+}
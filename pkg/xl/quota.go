@@ -0,0 +1,93 @@
+/*
+ * Minio Cloud Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import (
+	"os"
+
+	"github.com/minio/minio/pkg/probe"
+)
+
+// bucketUsage - bytes held by a bucket, of the objects in its index and of the parts of its uploads
+// in progress
+func bucketUsage(metadata BucketMetadata) int64 {
+	usage := metadata.Usage
+	for _, session := range metadata.Multiparts {
+		for _, part := range session.Parts {
+			usage += part.Size
+		}
+	}
+	return usage
+}
+
+// addUsage - account size bytes of objects added to the bucket index, negative for objects removed.
+// Usage is only kept while the bucket has a quota, it is computed anew when a quota is set
+func addUsage(metadata BucketMetadata, size int64) BucketMetadata {
+	if metadata.Quota <= 0 {
+		return metadata
+	}
+	metadata.Usage += size
+	if metadata.Usage < 0 {
+		metadata.Usage = 0
+	}
+	return metadata
+}
+
+// checkQuota - verify storing size bytes keeps the bucket within its quota, released bytes of the
+// objects or parts being replaced are no longer counted
+func (b bucket) checkQuota(metadata BucketMetadata, size, released int64) *probe.Error {
+	if metadata.Quota <= 0 {
+		return nil
+	}
+	if bucketUsage(metadata)-released+size > metadata.Quota {
+		return probe.NewError(QuotaExceeded{Bucket: b.getBucketName()})
+	}
+	return nil
+}
+
+// indexedObjectSize - size of an object in the bucket index, counted against the quota. Objects are
+// only read while the bucket has a quota, objects not in the bucket index have no size
+func (b bucket) indexedObjectSize(metadata BucketMetadata, objectName string) (int64, *probe.Error) {
+	if metadata.Quota <= 0 {
+		return 0, nil
+	}
+	if _, ok := metadata.BucketObjects[objectName]; !ok {
+		return 0, nil
+	}
+	objMetadata, err := b.readObjectMetadata(b.getObjectPath(objectName))
+	if err != nil {
+		if os.IsNotExist(err.ToGoError()) {
+			return 0, nil
+		}
+		return 0, err.Trace()
+	}
+	return objMetadata.Size, nil
+}
+
+// usage - bytes held by a bucket in memory, of its objects and of the parts of its uploads in progress
+func (s storedBucket) usage() int64 {
+	var usage int64
+	for _, objMetadata := range s.objectMetadata {
+		usage += objMetadata.Size
+	}
+	for _, parts := range s.partMetadata {
+		for _, part := range parts {
+			usage += part.Size
+		}
+	}
+	return usage
+}
@@ -0,0 +1,237 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha512"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/minio/minio/pkg/probe"
+)
+
+// ReadObjectWithOptions - open an object or a range of it to read, tuned by opts. Returns the number
+// of bytes to be read
+func (b bucket) ReadObjectWithOptions(objectName string, opts ReadOptions) (io.ReadCloser, int64, *probe.Error) {
+	return b.readObjectWithOptions(context.Background(), objectName, opts)
+}
+
+// readObjectWithOptions - open an object to read as ReadObjectWithOptions, decoding stops once ctx
+// is cancelled
+func (b bucket) readObjectWithOptions(ctx context.Context, objectName string, opts ReadOptions) (io.ReadCloser, int64, *probe.Error) {
+	if opts.Parallelism < 0 || opts.ReadAhead < 0 || opts.Offset < 0 || opts.Length < 0 || opts.MaxSize < 0 {
+		return nil, 0, probe.NewError(InvalidArgument{})
+	}
+	// bucket is a copy, parallelism and excluded disks apply to this read only
+	b.readParallelism = opts.Parallelism
+	if len(opts.ExcludeDisks) > 0 {
+		b.excludedDisks = make(map[int]struct{})
+		for _, order := range opts.ExcludeDisks {
+			if order < 0 {
+				return nil, 0, probe.NewError(InvalidArgument{})
+			}
+			b.excludedDisks[order] = struct{}{}
+		}
+	}
+	ranged := opts.Offset > 0 || opts.Length > 0
+	// the length of reads up to the end of the object is known from its metadata, reads over the
+	// maximum size are rejected before any slice is opened
+	length := opts.Length
+	if length == 0 && (ranged || opts.MaxSize > 0) {
+		objMetadata, err := b.GetObjectMetadata(objectName)
+		if err != nil {
+			return nil, 0, err.Trace(objectName)
+		}
+		length = objMetadata.Size - opts.Offset
+	}
+	if err := b.checkReadSize(objectName, length, opts); err != nil {
+		return nil, 0, err.Trace(objectName)
+	}
+
+	if opts.VerifyBeforeServe {
+		file, size, err := b.readObjectVerified(ctx, objectName, opts.Progress)
+		if err != nil {
+			return nil, 0, err.Trace(objectName)
+		}
+		offset, length := opts.Offset, opts.Length
+		if length == 0 {
+			length = size - offset
+		}
+		if offset+length > size || length < 0 {
+			file.Close()
+			return nil, 0, probe.NewError(InvalidRange{Start: offset, Length: length})
+		}
+		return b.limitReadSize(objectName, rangeReadCloser{Reader: io.NewSectionReader(file, offset, length), Closer: file}, length, opts)
+	}
+
+	var reader io.ReadCloser
+	var size int64
+	if ranged {
+		rangeReader, err := b.ReadObjectRange(objectName, opts.Offset, length)
+		if err != nil {
+			return nil, 0, err.Trace(objectName)
+		}
+		reader, size = rangeReader, length
+	} else {
+		objectReader, objMetadata, err := b.readObject(ctx, objectName, opts.Progress)
+		if err != nil {
+			return nil, 0, err.Trace(objectName)
+		}
+		reader, size = objectReader, objMetadata.Size
+	}
+	if opts.ReadAhead > 0 {
+		reader = newReadAheadReader(reader, opts.ReadAhead, blockSize)
+	}
+	return b.limitReadSize(objectName, reader, size, opts)
+}
+
+// checkReadSize - reads of size bytes over the maximum size fail unless truncated
+func (b bucket) checkReadSize(objectName string, size int64, opts ReadOptions) *probe.Error {
+	if opts.MaxSize == 0 || size <= opts.MaxSize || opts.TruncateToMaxSize {
+		return nil
+	}
+	return probe.NewError(EntityTooLarge{
+		GenericObjectError: GenericObjectError{Bucket: b.getBucketName(), Object: objectName},
+		Size:               strconv.FormatInt(size, 10),
+		MaxSize:            strconv.FormatInt(opts.MaxSize, 10),
+	})
+}
+
+// limitReadSize - enforce the maximum size of a read, reads over it are closed unless truncated
+func (b bucket) limitReadSize(objectName string, reader io.ReadCloser, size int64, opts ReadOptions) (io.ReadCloser, int64, *probe.Error) {
+	if opts.MaxSize == 0 || size <= opts.MaxSize {
+		return reader, size, nil
+	}
+	if err := b.checkReadSize(objectName, size, opts); err != nil {
+		reader.Close()
+		return nil, 0, err.Trace()
+	}
+	return rangeReadCloser{Reader: io.LimitReader(reader, opts.MaxSize), Closer: reader}, opts.MaxSize, nil
+}
+
+// readObjectVerified - read a whole object into a temporary file, verifying its checksums. Returns
+// the file, removed once closed, and the size of the object
+func (b bucket) readObjectVerified(ctx context.Context, objectName string, progress ChunkProgressFunc) (spooledFile, int64, *probe.Error) {
+	reader, objMetadata, err := b.readObject(ctx, objectName, progress)
+	if err != nil {
+		return spooledFile{}, 0, err.Trace()
+	}
+	defer reader.Close()
+	file, e := ioutil.TempFile("", "xl-verify-")
+	if e != nil {
+		return spooledFile{}, 0, probe.NewError(e)
+	}
+	spooled := spooledFile{File: file}
+	sumMD5 := md5.New()
+	sum512 := sha512.New()
+	if _, e := io.CopyN(io.MultiWriter(file, sumMD5, sum512), reader, objMetadata.Size); e != nil {
+		spooled.Close()
+		return spooledFile{}, 0, probe.NewError(e)
+	}
+	if hex.EncodeToString(sumMD5.Sum(nil)) != objMetadata.MD5Sum || hex.EncodeToString(sum512.Sum(nil)) != objMetadata.SHA512Sum {
+		spooled.Close()
+		return spooledFile{}, 0, probe.NewError(ChecksumMismatch{})
+	}
+	return spooled, objMetadata.Size, nil
+}
+
+// spooledFile - temporary file removed once closed
+type spooledFile struct {
+	*os.File
+}
+
+// Close - close and remove the file
+func (f spooledFile) Close() error {
+	e := f.File.Close()
+	os.Remove(f.Name())
+	return e
+}
+
+// readAheadReader - reads ahead of the caller in a go-routine, up to depth buffers
+type readAheadReader struct {
+	reader  io.ReadCloser
+	buffers chan []byte
+	done    chan struct{}
+	once    sync.Once
+
+	// buffer being read, err is set once all buffers are read
+	buffer []byte
+	err    error
+	errCh  chan error
+}
+
+// newReadAheadReader - start reading ahead of the caller in buffers of bufferSize
+func newReadAheadReader(reader io.ReadCloser, depth, bufferSize int) *readAheadReader {
+	r := &readAheadReader{
+		reader:  reader,
+		buffers: make(chan []byte, depth),
+		done:    make(chan struct{}),
+		errCh:   make(chan error, 1),
+	}
+	go func() {
+		defer close(r.buffers)
+		for {
+			buffer := make([]byte, bufferSize)
+			n, e := io.ReadFull(reader, buffer)
+			if n > 0 {
+				select {
+				case r.buffers <- buffer[:n]:
+				case <-r.done:
+					return
+				}
+			}
+			if e != nil {
+				if e == io.ErrUnexpectedEOF {
+					e = io.EOF
+				}
+				r.errCh <- e
+				return
+			}
+		}
+	}()
+	return r
+}
+
+// Read - read from buffers read ahead, errors are returned once all buffers before them are read
+func (r *readAheadReader) Read(p []byte) (int, error) {
+	for len(r.buffer) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		buffer, ok := <-r.buffers
+		if !ok {
+			r.err = <-r.errCh
+			continue
+		}
+		r.buffer = buffer
+	}
+	n := copy(p, r.buffer)
+	r.buffer = r.buffer[n:]
+	return n, nil
+}
+
+// Close - stop reading ahead and close the underlying reader
+func (r *readAheadReader) Close() error {
+	r.once.Do(func() { close(r.done) })
+	return r.reader.Close()
+}
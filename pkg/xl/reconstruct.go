@@ -0,0 +1,188 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"time"
+
+	"github.com/minio/minio/pkg/probe"
+)
+
+// RecoverOptions - layout of an object whose metadata is unreadable on all disks, as passed to
+// RecoverObject. Zero values are taken from the bucket
+type RecoverOptions struct {
+	// exact size of the object, required for erasure coded objects whose last chunk is padded
+	Size        int64
+	DataDisks   uint8
+	ParityDisks uint8
+	BlockSize   int64
+	// user metadata of the recovered object
+	Metadata map[string]string
+}
+
+// RecoverObject - recover an object of the bucket index whose metadata is unreadable on all disks
+// from its data slices. The slices must agree with each other under the layout of opts, their data
+// is then written again under the same name with fresh metadata and checksums. Objects with readable
+// metadata are left as they are
+func (b bucket) RecoverObject(objectName string, opts RecoverOptions) (ObjectMetadata, *probe.Error) {
+	defer b.lockObject(objectName)()
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	// slices of objects no longer in the index are never brought back
+	if _, ok := bucketMetadata.Buckets[b.getBucketName()].BucketObjects[objectName]; !ok {
+		return ObjectMetadata{}, probe.NewError(ObjectNotFound{Object: objectName})
+	}
+	objectPath := b.getObjectPath(objectName)
+	if objMetadata, err := b.readObjectMetadata(objectPath); err == nil {
+		return objMetadata, nil
+	}
+	objMetadata, err := b.reconstructObjectMetadata(objectPath, opts)
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	readers, err := b.getObjectReaders(objectPath, "data")
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	reader, writer := io.Pipe()
+	defer reader.Close()
+	go func() {
+		if _, err := b.readObjectTo(objectPath, objMetadata, readers, writer); err != nil {
+			writer.CloseWithError(probe.WrapError(err))
+			return
+		}
+		writer.Close()
+	}()
+	// slices are staged under temporary names while written, those being read stay intact
+	objMetadata, err = b.writeObject(context.Background(), objectName, reader, objMetadata.Size, WriteOptions{
+		Metadata: opts.Metadata,
+	})
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	return objMetadata, nil
+}
+
+// reconstructObjectMetadata - reconstruct metadata of an object whose metadata is unreadable on all
+// disks by probing its data slices. Slices identical on all disks holding them and not larger than a
+// small object are replicas, others are erasure coded with the layout of opts and must agree with
+// their parity. Checksums are unknown until the data is read
+func (b bucket) reconstructObjectMetadata(objectName string, opts RecoverOptions) (ObjectMetadata, *probe.Error) {
+	readers, err := b.getObjectReaders(objectName, "data")
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	var sliceLen int64 = -1
+	var sliceMD5Sum string
+	replicas := true
+	for _, reader := range readers {
+		hasher := md5.New()
+		n, e := io.Copy(hasher, reader)
+		reader.Close()
+		if e != nil {
+			return ObjectMetadata{}, probe.NewError(e)
+		}
+		if sliceLen >= 0 && n != sliceLen {
+			return ObjectMetadata{}, probe.NewError(ObjectCorrupted{Object: objectName})
+		}
+		sum := hex.EncodeToString(hasher.Sum(nil))
+		if sliceMD5Sum != "" && sum != sliceMD5Sum {
+			replicas = false
+		}
+		sliceLen, sliceMD5Sum = n, sum
+	}
+	if sliceLen <= 0 {
+		return ObjectMetadata{}, probe.NewError(ObjectCorrupted{Object: objectName})
+	}
+
+	objMetadata := ObjectMetadata{}
+	objMetadata.Version = objectMetadataVersion
+	objMetadata.Created = time.Now().UTC()
+	objMetadata.Bucket = b.getBucketName()
+	objMetadata.Object = denormalizeObjectName(objectName)
+	objMetadata.Reconstructed = true
+	if replicas && sliceLen <= b.smallObjectSize {
+		if opts.Size != 0 && opts.Size != sliceLen {
+			return ObjectMetadata{}, probe.NewError(ObjectCorrupted{Object: objectName})
+		}
+		objMetadata.ReplicaDisks = uint8(len(readers))
+		objMetadata.Size = sliceLen
+		return objMetadata, nil
+	}
+	// the padding of the last chunk can not be told apart from data
+	if opts.Size <= 0 {
+		return ObjectMetadata{}, probe.NewError(InvalidArgument{})
+	}
+
+	// slices are written to the disks of the last node, see getObjectWriters()
+	var totalWriters int
+	for _, node := range b.getNodes() {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return ObjectMetadata{}, err.Trace()
+		}
+		totalWriters = len(disks)
+	}
+	k, m := opts.DataDisks, opts.ParityDisks
+	if k == 0 && m == 0 {
+		k, m, err = b.getDataAndParity(totalWriters)
+		if err != nil {
+			return ObjectMetadata{}, err.Trace()
+		}
+	}
+	if err := checkDataAndParity(k, m, totalWriters); err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	encoder, err := newEncoder(k, m)
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	blockSize := opts.BlockSize
+	if blockSize <= 0 {
+		blockSize = b.getBlockSize()
+	}
+	objMetadata.BlockSize = int(blockSize)
+	objMetadata.ChunkCount = int((opts.Size + blockSize - 1) / blockSize)
+	objMetadata.DataDisks = k
+	objMetadata.ParityDisks = m
+	objMetadata.Size = opts.Size
+	// the layout must account for the slices exactly and every chunk must agree with its parity
+	var encodedLen int64
+	if err := forEachChunk(objMetadata, encoder, func(length, chunkSliceLen int) *probe.Error {
+		encodedLen += int64(chunkSliceLen)
+		return nil
+	}); err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	if encodedLen != sliceLen {
+		return ObjectMetadata{}, probe.NewError(ObjectCorrupted{Object: objectName})
+	}
+	corrupt, recoverable, _, err := b.findCorruptSlices(objectName, objMetadata, encoder)
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	if len(corrupt) > 0 || !recoverable {
+		return ObjectMetadata{}, probe.NewError(ObjectCorrupted{Object: objectName})
+	}
+	return objMetadata, nil
+}
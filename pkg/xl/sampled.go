@@ -0,0 +1,108 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/minio/minio/pkg/probe"
+)
+
+// ReadObjectSampled - read an object into w verifying only a sample of its chunks against their slice
+// hashes, the whole object checksums are not verified. For spot checks of large objects, returns the
+// chunks verified, chunks of objects written without slice hashes are never verified
+func (b bucket) ReadObjectSampled(objectName string, w io.Writer, sample VerifySample) (coverage SampleCoverage, err *probe.Error) {
+	if sample.Rate <= 0 || sample.Rate > 1 {
+		return SampleCoverage{}, probe.NewError(InvalidArgument{})
+	}
+	var written int64
+	defer func(start time.Time) {
+		b.stats.recordRequest(b.name, operationGetObject, start, written, err)
+	}(time.Now())
+	objectPath, objMetadata, readers, err := b.openObjectReaders(objectName)
+	if err != nil {
+		return SampleCoverage{}, err.Trace()
+	}
+	if objMetadata.DataDisks == 0 || objMetadata.Compression != "" {
+		// replicated and compressed objects are not decoded chunk by chunk, verified by their md5sum
+		reader, writer := io.Pipe()
+		defer reader.Close()
+		go b.readObjectReaders(context.Background(), objectPath, readers, writer, objMetadata, nil)
+		sumMD5 := md5.New()
+		n, e := io.CopyN(io.MultiWriter(w, sumMD5), reader, objMetadata.Size)
+		written = n
+		if e != nil {
+			return SampleCoverage{}, probe.NewError(e)
+		}
+		if hex.EncodeToString(sumMD5.Sum(nil)) != objMetadata.MD5Sum {
+			return SampleCoverage{}, probe.NewError(ChecksumMismatch{})
+		}
+		return SampleCoverage{Chunks: 1, Verified: 1}, nil
+	}
+	sampler := newChunkSampler(sample, objMetadata.ChunkCount)
+	written, err = b.readEncodedDataTo(readers, w, objMetadata, sampler)
+	if err != nil {
+		return SampleCoverage{}, err.Trace()
+	}
+	return SampleCoverage{Chunks: objMetadata.ChunkCount, Verified: sampler.verified}, nil
+}
+
+// chunkSampler - picks the chunks of an object to verify, a nil sampler verifies every chunk
+type chunkSampler struct {
+	sampled  map[int]struct{}
+	verified int
+}
+
+// newChunkSampler - sample the rate rounded up of chunks, strided evenly or at random
+func newChunkSampler(sample VerifySample, chunks int) *chunkSampler {
+	s := &chunkSampler{sampled: make(map[int]struct{})}
+	count := int(math.Ceil(float64(chunks) * sample.Rate))
+	if count > chunks {
+		count = chunks
+	}
+	if sample.Random {
+		for _, chunk := range rand.Perm(chunks)[:count] {
+			s.sampled[chunk] = struct{}{}
+		}
+		return s
+	}
+	// evenly spaced, the first chunk is always sampled
+	for chunk := 0; chunk < chunks; chunk++ {
+		if (chunk*count+chunks-1)/chunks != ((chunk+1)*count+chunks-1)/chunks {
+			s.sampled[chunk] = struct{}{}
+		}
+	}
+	return s
+}
+
+// verify - whether the slices of chunk are to be verified against hashes, counts the chunks verified
+func (s *chunkSampler) verify(chunk int, hashes []string) bool {
+	if s == nil {
+		return true
+	}
+	if _, ok := s.sampled[chunk]; !ok || len(hashes) == 0 {
+		return false
+	}
+	s.verified++
+	return true
+}
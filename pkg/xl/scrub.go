@@ -0,0 +1,752 @@
+/*
+ * Minio Cloud Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/minio/minio/pkg/probe"
+)
+
+// scrubIdleInterval - pause of a scrubber between passes over an empty bucket
+const scrubIdleInterval = time.Second
+
+// maxScrubFindings - findings of a scrubber remembered at most, the oldest are dropped
+const maxScrubFindings = 1024
+
+// ScrubRate - pace of a scrubber, zero values are unlimited
+type ScrubRate struct {
+	ObjectsPerSecond float64
+	BytesPerSecond   int64
+}
+
+// ScrubFinding - corrupt or missing slices of an object found while scrubbing
+type ScrubFinding struct {
+	Object string
+	Disks  []int
+	Healed bool
+	// slices rebuilt and written back, zero unless healed
+	Regenerated int
+	Time        time.Time
+}
+
+// Scrubber - continuously verifies all objects of a bucket at a throttled pace, and heals
+// objects whose corruption is recoverable
+type Scrubber struct {
+	bucket   bucket
+	rate     ScrubRate
+	lock     *sync.Mutex
+	findings []ScrubFinding
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+// NewScrubber - instantiate a new scrubber for the bucket, it is not started
+func (b bucket) NewScrubber(rate ScrubRate) *Scrubber {
+	return &Scrubber{
+		bucket: b,
+		rate:   rate,
+		lock:   new(sync.Mutex),
+	}
+}
+
+// Start - start scrubbing in the background, scrubbing continues from the first object
+// in a new pass once all objects are scrubbed
+func (s *Scrubber) Start() *probe.Error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stopCh != nil {
+		return probe.NewError(InvalidArgument{})
+	}
+	s.stopCh = make(chan struct{})
+	s.doneCh = make(chan struct{})
+	go s.run(s.stopCh, s.doneCh)
+	return nil
+}
+
+// Stop - stop scrubbing and wait for the object being scrubbed to finish, stopping a scrubber
+// which is not running is a no-op
+func (s *Scrubber) Stop() {
+	s.lock.Lock()
+	stopCh, doneCh := s.stopCh, s.doneCh
+	s.stopCh, s.doneCh = nil, nil
+	s.lock.Unlock()
+	if stopCh == nil {
+		return
+	}
+	close(stopCh)
+	<-doneCh
+}
+
+// Findings - latest findings recorded since the scrubber was created, oldest first
+func (s *Scrubber) Findings() []ScrubFinding {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	findings := make([]ScrubFinding, len(s.findings))
+	copy(findings, s.findings)
+	return findings
+}
+
+// recordFinding - remember a finding, dropping the oldest past maxScrubFindings
+func (s *Scrubber) recordFinding(finding ScrubFinding) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.findings = append(s.findings, finding)
+	if len(s.findings) > maxScrubFindings {
+		s.findings = s.findings[len(s.findings)-maxScrubFindings:]
+	}
+}
+
+// run - scrub objects until stopped
+func (s *Scrubber) run(stopCh <-chan struct{}, doneCh chan<- struct{}) {
+	defer close(doneCh)
+	for {
+		s.bucket.lock.RLock()
+		bucketMetadata, err := s.bucket.getBucketMetadata()
+		s.bucket.lock.RUnlock()
+		var objects []string
+		if err == nil {
+			for objectName := range bucketMetadata.Buckets[s.bucket.getBucketName()].BucketObjects {
+				objects = append(objects, objectName)
+			}
+			sort.Strings(objects)
+		}
+		if len(objects) == 0 && !s.wait(stopCh, scrubIdleInterval) {
+			return
+		}
+		for _, objectName := range objects {
+			finding, scrubbed, err := s.bucket.ScrubObject(objectName)
+			// objects removed in the meanwhile fail to scrub, they are not findings
+			if err == nil && len(finding.Disks) > 0 {
+				s.recordFinding(finding)
+			}
+			if !s.wait(stopCh, s.getDelay(scrubbed)) {
+				return
+			}
+		}
+	}
+}
+
+// getDelay - delay after scrubbing an object such that the scrub rate is not exceeded
+func (s *Scrubber) getDelay(scrubbed int64) time.Duration {
+	var delay time.Duration
+	if s.rate.ObjectsPerSecond > 0 {
+		delay = time.Duration(float64(time.Second) / s.rate.ObjectsPerSecond)
+	}
+	if s.rate.BytesPerSecond > 0 {
+		if bytesDelay := time.Duration(scrubbed * int64(time.Second) / s.rate.BytesPerSecond); bytesDelay > delay {
+			delay = bytesDelay
+		}
+	}
+	return delay
+}
+
+// wait - wait for delay, returns false if stopped in the meanwhile
+func (s *Scrubber) wait(stopCh <-chan struct{}, delay time.Duration) bool {
+	select {
+	case <-stopCh:
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+// healStagedHook - invoked once rebuilt slices are staged and before they are swapped in, replaced
+// in tests to observe reads during a heal
+var healStagedHook = func(objectName string) {}
+
+// HealObject - rebuild corrupt and missing slices of an object, such as all slices of a replaced
+// disk, from the intact slices. Reads of the object are served from the existing slices
+// while it is healed
+func (b bucket) HealObject(objectName string) (ScrubFinding, *probe.Error) {
+	finding, _, err := b.ScrubObject(objectName)
+	if err != nil {
+		return ScrubFinding{}, err.Trace()
+	}
+	return finding, nil
+}
+
+// ScrubObject - verify every slice of an object, data slices of erasure coded objects against their
+// parity, replicas against the md5sum and metadata slices against the metadata read. Corrupt and missing
+// slices are rebuilt if they can be told apart from the intact ones. Returns the bytes of slices read.
+// Slices are verified and rebuilt without holding the object lock, rebuilt slices are swapped in
+// atomically at the end
+func (b bucket) ScrubObject(objectName string) (ScrubFinding, int64, *probe.Error) {
+	finding := ScrubFinding{Object: objectName, Time: time.Now().UTC()}
+	objectPath := b.getObjectPath(objectName)
+	unlock := b.rlockObject(objectName)
+	objMetadata, err := b.readObjectMetadata(objectPath)
+	unlock()
+	if err != nil {
+		return ScrubFinding{}, 0, err.Trace()
+	}
+	corruptMetadata, err := b.findCorruptMetadataSlices(objectPath, objMetadata)
+	if err != nil {
+		return ScrubFinding{}, 0, err.Trace()
+	}
+	var encoder encoder
+	corrupt := make(map[int]struct{})
+	recoverable := true
+	var scrubbed int64
+	switch {
+	case objMetadata.DataDisks > 0:
+		encoder, err = newEncoder(objMetadata.DataDisks, objMetadata.ParityDisks)
+		if err != nil {
+			return ScrubFinding{}, 0, err.Trace()
+		}
+		corrupt, recoverable, scrubbed, err = b.findCorruptSlices(objectPath, objMetadata, encoder)
+		if err != nil {
+			return ScrubFinding{}, scrubbed, err.Trace()
+		}
+		b.stats.recordRedundancy(b.getBucketName(), objectName, objMetadata.DataDisks, objMetadata.ParityDisks, len(corrupt))
+		recoverable = recoverable && len(corrupt) <= int(objMetadata.ParityDisks)
+	case objMetadata.ReplicaDisks > 0:
+		corrupt, recoverable, scrubbed, err = b.findCorruptReplicas(objectPath, objMetadata)
+		if err != nil {
+			return ScrubFinding{}, scrubbed, err.Trace()
+		}
+	}
+	disks := make(map[int]struct{})
+	for order := range corrupt {
+		disks[order] = struct{}{}
+	}
+	for order := range corruptMetadata {
+		disks[order] = struct{}{}
+	}
+	for order := range disks {
+		finding.Disks = append(finding.Disks, order)
+	}
+	sort.Ints(finding.Disks)
+	if len(disks) == 0 || !recoverable {
+		return finding, scrubbed, nil
+	}
+	var writers []io.WriteCloser
+	if len(corrupt) > 0 {
+		var sliceWriters []io.WriteCloser
+		if objMetadata.DataDisks > 0 {
+			sliceWriters, err = b.rebuildSlices(objectPath, objMetadata, encoder, corrupt)
+		} else {
+			sliceWriters, err = b.rebuildReplicas(objectPath, objMetadata, corrupt)
+		}
+		if err != nil {
+			return finding, scrubbed, err.Trace()
+		}
+		writers = append(writers, sliceWriters...)
+	}
+	if len(corruptMetadata) > 0 {
+		metadataWriters, err := b.rebuildMetadataSlices(objectPath, objMetadata, corruptMetadata)
+		if err != nil {
+			CleanupWritersOnError(writers)
+			return finding, scrubbed, err.Trace()
+		}
+		writers = append(writers, metadataWriters...)
+	}
+	healStagedHook(objectName)
+	if err := b.commitSlices(objectPath, objMetadata, writers); err != nil {
+		return finding, scrubbed, err.Trace()
+	}
+	finding.Healed = true
+	finding.Regenerated = len(disks)
+	return finding, scrubbed, nil
+}
+
+// findCorruptMetadataSlices - disk orders of object metadata slices which are missing or do not
+// decode to the metadata of the object
+func (b bucket) findCorruptMetadataSlices(objectName string, objMetadata ObjectMetadata) (map[int]struct{}, *probe.Error) {
+	readers, err := b.getObjectReaders(objectName, objectMetadataConfig)
+	if err != nil {
+		return nil, err.Trace()
+	}
+	corrupt := make(map[int]struct{})
+	// metadata is written to the disks of the last node, see getObjectWriters()
+	for _, node := range b.getNodes() {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return nil, err.Trace()
+		}
+		for order := range disks {
+			if _, ok := b.excludedDisks[order]; ok {
+				continue
+			}
+			corrupt[order] = struct{}{}
+		}
+	}
+	for order, reader := range readers {
+		var sliceMetadata ObjectMetadata
+		e := json.NewDecoder(reader).Decode(&sliceMetadata)
+		reader.Close()
+		if e == nil && sliceMetadata.Created.Equal(objMetadata.Created) && sliceMetadata.MD5Sum == objMetadata.MD5Sum &&
+			sliceMetadata.LastModified().Equal(objMetadata.LastModified()) {
+			delete(corrupt, order)
+		}
+	}
+	return corrupt, nil
+}
+
+// rebuildMetadataSlices - stage the object metadata on corrupt disks, not visible until committed
+func (b bucket) rebuildMetadataSlices(objectName string, objMetadata ObjectMetadata, corrupt map[int]struct{}) ([]io.WriteCloser, *probe.Error) {
+	writers, err := b.getObjectSliceWriters(objectName, objectMetadataConfig, corrupt)
+	if err != nil {
+		return nil, err.Trace()
+	}
+	var metadataWriters []io.WriteCloser
+	for _, writer := range writers {
+		metadataWriters = append(metadataWriters, writer)
+	}
+	for _, writer := range metadataWriters {
+		if e := json.NewEncoder(writer).Encode(&objMetadata); e != nil {
+			CleanupWritersOnError(metadataWriters)
+			return nil, probe.NewError(e)
+		}
+	}
+	return metadataWriters, nil
+}
+
+// findCorruptReplicas - disk orders of replicas which are missing or mismatch the md5sum of a
+// replicated object, not recoverable without any intact replica
+func (b bucket) findCorruptReplicas(objectName string, objMetadata ObjectMetadata) (map[int]struct{}, bool, int64, *probe.Error) {
+	readers, err := b.getObjectReaders(objectName, "data")
+	if err != nil {
+		return nil, false, 0, err.Trace()
+	}
+	corrupt := make(map[int]struct{})
+	var scrubbed int64
+	for order := range b.replicaOrders(objMetadata) {
+		reader, ok := readers[order]
+		if !ok {
+			corrupt[order] = struct{}{}
+			continue
+		}
+		hasher := md5.New()
+		n, e := io.Copy(hasher, reader)
+		scrubbed += n
+		if e != nil || hex.EncodeToString(hasher.Sum(nil)) != objMetadata.MD5Sum {
+			corrupt[order] = struct{}{}
+		}
+	}
+	for _, reader := range readers {
+		reader.Close()
+	}
+	return corrupt, len(corrupt) < int(objMetadata.ReplicaDisks), scrubbed, nil
+}
+
+// replicaOrders - disk orders of the replicas of a replicated object, less any excluded disks
+func (b bucket) replicaOrders(objMetadata ObjectMetadata) map[int]struct{} {
+	orders := make(map[int]struct{})
+	for order := 0; order < int(objMetadata.ReplicaDisks); order++ {
+		if _, ok := b.excludedDisks[order]; !ok {
+			orders[order] = struct{}{}
+		}
+	}
+	return orders
+}
+
+// rebuildReplicas - stage replicas on corrupt disks from an intact replica, not visible until committed
+func (b bucket) rebuildReplicas(objectName string, objMetadata ObjectMetadata, corrupt map[int]struct{}) ([]io.WriteCloser, *probe.Error) {
+	readers, err := b.getObjectReaders(objectName, "data")
+	if err != nil {
+		return nil, err.Trace()
+	}
+	for _, reader := range readers {
+		defer reader.Close()
+	}
+	expectedMD5Sum, e := hex.DecodeString(objMetadata.MD5Sum)
+	if e != nil {
+		return nil, probe.NewError(e)
+	}
+	var replica bytes.Buffer
+	if err := b.readReplicatedData(objectName, readers, expectedMD5Sum, &replica); err != nil {
+		return nil, err.Trace()
+	}
+	writers, err := b.getObjectSliceWriters(objectName, "data", corrupt)
+	if err != nil {
+		return nil, err.Trace()
+	}
+	var replicaWriters []io.WriteCloser
+	for _, writer := range writers {
+		replicaWriters = append(replicaWriters, writer)
+	}
+	for _, writer := range replicaWriters {
+		if _, e := writer.Write(replica.Bytes()); e != nil {
+			CleanupWritersOnError(replicaWriters)
+			return nil, probe.NewError(e)
+		}
+	}
+	return replicaWriters, nil
+}
+
+// findCorruptSlices - disk orders of slices which are missing, short, mismatch their slice hashes or
+// disagree with the other slices of the object. Without slice hashes not recoverable if a chunk has
+// more than one corrupt slice
+func (b bucket) findCorruptSlices(objectName string, objMetadata ObjectMetadata, encoder encoder) (map[int]struct{}, bool, int64, *probe.Error) {
+	readers, err := b.getObjectReaders(objectName, "data")
+	if err != nil {
+		return nil, false, 0, err.Trace()
+	}
+	for _, reader := range readers {
+		defer reader.Close()
+	}
+	totalShards := int(encoder.k + encoder.m)
+	reconcileShardReaders(readers, totalShards)
+	corrupt := make(map[int]struct{})
+	for order := 0; order < totalShards; order++ {
+		if _, ok := readers[order]; !ok {
+			corrupt[order] = struct{}{}
+		}
+	}
+	recoverable := true
+	var scrubbed int64
+	chunk := 0
+	err = forEachChunk(objMetadata, encoder, func(length, sliceLen int) *probe.Error {
+		expectedHashes := objMetadata.getSliceHashes(chunk)
+		chunk++
+		shards, errs := readChunkShards(readers, totalShards, sliceLen)
+		for order := range errs {
+			corrupt[order] = struct{}{}
+		}
+		for order, shard := range shards {
+			if shard == nil {
+				continue
+			}
+			scrubbed += int64(len(shard))
+			// shards mismatching their hash are left out, remaining shards are known intact
+			if order < len(expectedHashes) && hashSlice(shard) != expectedHashes[order] {
+				corrupt[order] = struct{}{}
+				shards[order] = nil
+			}
+		}
+		order, ok, err := findCorruptShard(encoder, shards, length)
+		if err != nil {
+			return err.Trace()
+		}
+		if !ok {
+			recoverable = false
+			return nil
+		}
+		if order >= 0 {
+			corrupt[order] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, scrubbed, err.Trace()
+	}
+	return corrupt, recoverable, scrubbed, nil
+}
+
+// findCorruptShard - order of the single shard disagreeing with the others, -1 if all shards agree.
+// Not ok if the shards do not agree with any single shard left out
+func findCorruptShard(encoder encoder, shards [][]byte, length int) (int, bool, *probe.Error) {
+	consistent, err := isConsistentShards(encoder, shards, length)
+	if err != nil {
+		return -1, false, err.Trace()
+	}
+	if consistent {
+		return -1, true, nil
+	}
+	for order := range shards {
+		if shards[order] == nil {
+			continue
+		}
+		trial := make([][]byte, len(shards))
+		copy(trial, shards)
+		trial[order] = nil
+		consistent, err := isConsistentShards(encoder, trial, length)
+		if err != nil {
+			return -1, false, err.Trace()
+		}
+		if consistent {
+			return order, true, nil
+		}
+	}
+	return -1, false, nil
+}
+
+// maxQueuedParitySamples - chunks sampled by reads waiting to be verified at most, chunks sampled while
+// the queue is full are not verified
+const maxQueuedParitySamples = 64
+
+// paritySample - shards of a chunk served by a read, to be verified against each other
+type paritySample struct {
+	bucket  bucket
+	object  string
+	encoder encoder
+	shards  [][]byte
+	missing []int
+	length  int
+}
+
+// paritySampleQueue - verifies chunks sampled by reads one at a time in the background, such that
+// reads never wait on verification
+type paritySampleQueue struct {
+	once    *sync.Once
+	samples chan paritySample
+}
+
+// newParitySampleQueue - instantiate a new queue, its worker is started on first use
+func newParitySampleQueue() *paritySampleQueue {
+	return &paritySampleQueue{
+		once:    new(sync.Once),
+		samples: make(chan paritySample, maxQueuedParitySamples),
+	}
+}
+
+// enqueue - queue a chunk to be verified without blocking, returns false if the queue is full and the
+// chunk is dropped
+func (q *paritySampleQueue) enqueue(sample paritySample) bool {
+	q.once.Do(func() { go q.run() })
+	select {
+	case q.samples <- sample:
+		return true
+	default:
+		return false
+	}
+}
+
+// run - verify queued chunks as they come
+func (q *paritySampleQueue) run() {
+	for sample := range q.samples {
+		sample.bucket.verifyParitySample(sample.object, sample.encoder, sample.shards, sample.missing, sample.length)
+	}
+}
+
+// isParitySampled - whether the shards of a chunk being read are to be verified against each other
+func (b bucket) isParitySampled() bool {
+	return b.parityVerifyRate > 0 && rand.Float64() < b.parityVerifyRate
+}
+
+// verifyParitySample - verify the shards of a chunk read by a normal read agree with each other, shards
+// which were missing or are found corrupt are recorded as a finding to be healed later
+func (b bucket) verifyParitySample(objectName string, encoder encoder, shards [][]byte, missing []int, length int) {
+	// missing shards may have been reconstructed by decoding, only shards read are verified
+	present := make([][]byte, len(shards))
+	copy(present, shards)
+	for _, order := range missing {
+		present[order] = nil
+	}
+	order, ok, err := findCorruptShard(encoder, present, length)
+	if err != nil {
+		return
+	}
+	disks := append([]int{}, missing...)
+	if order >= 0 {
+		b.stats.recordCorruptSlice(order)
+		disks = append(disks, order)
+	}
+	// shards disagreeing without a single corrupt one are left to be told apart by a scrub
+	if len(disks) == 0 && ok {
+		return
+	}
+	sort.Ints(disks)
+	b.stats.recordParityFinding(ParityFinding{
+		Bucket: b.getBucketName(),
+		Object: objectName,
+		Disks:  disks,
+		Time:   time.Now().UTC(),
+	})
+}
+
+// isConsistentShards - verify if all shards present agree with the data decoded from them, too few
+// shards to decode are never consistent
+func isConsistentShards(encoder encoder, shards [][]byte, length int) (bool, *probe.Error) {
+	present := 0
+	for _, shard := range shards {
+		if shard != nil {
+			present++
+		}
+	}
+	if present < int(encoder.k) {
+		return false, nil
+	}
+	// decoding may reconstruct missing shards in place
+	encodedShards := make([][]byte, len(shards))
+	copy(encodedShards, shards)
+	decodedData, err := encoder.Decode(encodedShards, length)
+	if err != nil {
+		return false, err.Trace()
+	}
+	expectedShards, err := encoder.Encode(decodedData)
+	if err != nil {
+		return false, err.Trace()
+	}
+	for order, shard := range shards {
+		if shard != nil && !bytes.Equal(shard, expectedShards[order]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// rebuildSlices - rebuild the slices on corrupt disks from the remaining slices of the object, rebuilt
+// slices are staged and not visible until committed
+func (b bucket) rebuildSlices(objectName string, objMetadata ObjectMetadata, encoder encoder, corrupt map[int]struct{}) ([]io.WriteCloser, *probe.Error) {
+	readers, err := b.getObjectReaders(objectName, "data")
+	if err != nil {
+		return nil, err.Trace()
+	}
+	for _, reader := range readers {
+		defer reader.Close()
+	}
+	totalShards := int(encoder.k + encoder.m)
+	reconcileShardReaders(readers, totalShards)
+	for order := range corrupt {
+		delete(readers, order)
+	}
+	writers, err := b.getObjectSliceWriters(objectName, "data", corrupt)
+	if err != nil {
+		return nil, err.Trace()
+	}
+	var orders []int
+	for order := range writers {
+		orders = append(orders, order)
+	}
+	sort.Ints(orders)
+	sliceWriters := make([]io.WriteCloser, len(orders))
+	for i, order := range orders {
+		sliceWriters[i] = writers[order]
+	}
+	// slice hashes kept beside the data slices are rebuilt along with them
+	hashWriters := make(map[int]io.WriteCloser)
+	if objMetadata.SliceHashSidecar {
+		hashWriters, err = b.getObjectSliceWriters(objectName, sliceHashesConfig, corrupt)
+		if err != nil {
+			CleanupWritersOnError(sliceWriters)
+			return nil, err.Trace()
+		}
+		for _, order := range orders {
+			sliceWriters = append(sliceWriters, hashWriters[order])
+		}
+	}
+	err = forEachChunk(objMetadata, encoder, func(length, sliceLen int) *probe.Error {
+		shards, errs := readChunkShards(readers, totalShards, sliceLen)
+		for _, e := range errs {
+			return probe.NewError(e)
+		}
+		decodedData, err := encoder.Decode(shards, length)
+		if err != nil {
+			return err.Trace()
+		}
+		encodedShards, err := encoder.Encode(decodedData)
+		if err != nil {
+			return err.Trace()
+		}
+		for _, order := range orders {
+			if _, e := writers[order].Write(encodedShards[order]); e != nil {
+				return probe.NewError(e)
+			}
+			if hashWriter, ok := hashWriters[order]; ok {
+				sum := sha256.Sum256(encodedShards[order])
+				if _, e := hashWriter.Write(sum[:]); e != nil {
+					return probe.NewError(e)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		CleanupWritersOnError(sliceWriters)
+		return nil, err.Trace()
+	}
+	return sliceWriters, nil
+}
+
+// commitSlices - swap in rebuilt slices, unless the object was re-written while they were rebuilt
+func (b bucket) commitSlices(objectName string, objMetadata ObjectMetadata, writers []io.WriteCloser) *probe.Error {
+	defer b.lockObject(objectName)()
+	currentMetadata, err := b.readObjectMetadata(objectName)
+	if err != nil {
+		CleanupWritersOnError(writers)
+		return err.Trace()
+	}
+	if !currentMetadata.Created.Equal(objMetadata.Created) || currentMetadata.MD5Sum != objMetadata.MD5Sum ||
+		!currentMetadata.LastModified().Equal(objMetadata.LastModified()) {
+		CleanupWritersOnError(writers)
+		return probe.NewError(PreconditionFailed{Bucket: b.getBucketName(), Object: objMetadata.Object})
+	}
+	if e := commitWriters(writers); e != nil {
+		return probe.NewError(e)
+	}
+	return nil
+}
+
+// forEachChunk - call chunkFunc with the data and slice length of every chunk of an erasure coded object
+func forEachChunk(objMetadata ObjectMetadata, encoder encoder, chunkFunc func(length, sliceLen int) *probe.Error) *probe.Error {
+	totalLeft := objMetadata.Size
+	if objMetadata.Compression != "" {
+		totalLeft = objMetadata.StoredSize
+	}
+	for i := 0; i < objMetadata.ChunkCount; i++ {
+		chunkSize := int64(objMetadata.BlockSize)
+		if len(objMetadata.ChunkSizes) > 0 {
+			chunkSize = objMetadata.ChunkSizes[i]
+		}
+		length := chunkSize
+		if totalLeft < chunkSize {
+			length = totalLeft
+		}
+		sliceLen, err := encoder.GetEncodedBlockLen(int(length))
+		if err != nil {
+			return err.Trace()
+		}
+		if err := chunkFunc(int(length), sliceLen); err != nil {
+			return err.Trace()
+		}
+		totalLeft = totalLeft - chunkSize
+	}
+	return nil
+}
+
+// getObjectSliceWriters - writers for the object slices on the given disk orders only
+func (b bucket) getObjectSliceWriters(objectName, objectMeta string, orders map[int]struct{}) (map[int]io.WriteCloser, *probe.Error) {
+	writers := make(map[int]io.WriteCloser)
+	nodeSlice := 0
+	for _, node := range b.getNodes() {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return nil, err.Trace()
+		}
+		for order, disk := range disks {
+			if _, ok := orders[order]; !ok {
+				continue
+			}
+			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, order)
+			objectSlice, err := disk.CreateFile(filepath.Join(b.xlName, bucketSlice, objectName, objectMeta))
+			if err != nil {
+				var created []io.WriteCloser
+				for _, writer := range writers {
+					created = append(created, writer)
+				}
+				CleanupWritersOnError(created)
+				return nil, err.Trace()
+			}
+			writers[order] = objectSlice
+		}
+		nodeSlice = nodeSlice + 1
+	}
+	return writers, nil
+}
@@ -0,0 +1,109 @@
+/*
+ * Minio Cloud Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// errShardNotSeekable - shard reader can only be read sequentially
+var errShardNotSeekable = errors.New("shard reader does not support seeking")
+
+// shardReadLimiter - bounds the shard reads in flight on every disk, shared by all buckets such
+// that reads of many objects at once, by requests or by the scrubber, keep disk I/O bounded
+type shardReadLimiter struct {
+	lock     *sync.Mutex
+	slots    map[string]chan struct{}
+	inFlight map[string]int
+}
+
+// shardReadAcquiredHook - invoked with the reads in flight on a disk once a read slot of the disk
+// is acquired, replaced in tests to observe the limit
+var shardReadAcquiredHook = func(disk string, inFlight int) {}
+
+// newShardReadLimiter - instantiate a new shard read limiter
+func newShardReadLimiter() *shardReadLimiter {
+	return &shardReadLimiter{
+		lock:     new(sync.Mutex),
+		slots:    make(map[string]chan struct{}),
+		inFlight: make(map[string]int),
+	}
+}
+
+// acquire - wait for a read slot on disk, limit is the reads allowed in flight per disk and
+// applies from the first read of the disk on
+func (l *shardReadLimiter) acquire(disk string, limit int) {
+	l.lock.Lock()
+	slots, ok := l.slots[disk]
+	if !ok {
+		slots = make(chan struct{}, limit)
+		l.slots[disk] = slots
+	}
+	l.lock.Unlock()
+	slots <- struct{}{}
+	l.lock.Lock()
+	l.inFlight[disk]++
+	shardReadAcquiredHook(disk, l.inFlight[disk])
+	l.lock.Unlock()
+}
+
+// release - give back a read slot on disk
+func (l *shardReadLimiter) release(disk string) {
+	l.lock.Lock()
+	l.inFlight[disk]--
+	slots := l.slots[disk]
+	l.lock.Unlock()
+	<-slots
+}
+
+// limitedShardReader - shard reader holding a read slot of its disk for the duration of every read
+type limitedShardReader struct {
+	io.ReadCloser
+	disk    string
+	limit   int
+	limiter *shardReadLimiter
+}
+
+func (r limitedShardReader) Read(p []byte) (int, error) {
+	r.limiter.acquire(r.disk, r.limit)
+	defer r.limiter.release(r.disk)
+	return r.ReadCloser.Read(p)
+}
+
+// Seek - seek the underlying shard reader, seeking reads nothing and needs no read slot
+func (r limitedShardReader) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := r.ReadCloser.(io.Seeker)
+	if !ok {
+		return 0, errShardNotSeekable
+	}
+	return seeker.Seek(offset, whence)
+}
+
+// limitShardReader - bound reads of a shard on disk, if a limit is configured
+func (b bucket) limitShardReader(reader io.ReadCloser, disk string) io.ReadCloser {
+	if b.stats == nil || b.shardReadLimit <= 0 {
+		return reader
+	}
+	return limitedShardReader{
+		ReadCloser: reader,
+		disk:       disk,
+		limit:      b.shardReadLimit,
+		limiter:    b.stats.shardReads,
+	}
+}
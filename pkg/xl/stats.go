@@ -0,0 +1,249 @@
+/*
+ * Minio Cloud Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import (
+	"sync"
+	"time"
+)
+
+// maxParityFindings - findings of parity sampled reads remembered at most, the oldest are dropped
+const maxParityFindings = 1024
+
+// DegradedReadStats - counters for reads which needed parity reconstruction
+type DegradedReadStats struct {
+	TotalReads    int64
+	DegradedReads int64
+	Buckets       map[string]int64
+	Disks         map[int]int64
+	// slices read per disk not matching their stored checksum
+	CorruptSlices map[int]int64
+}
+
+// DegradedReadFunc - callback invoked when degraded reads exceed threshold
+type DegradedReadFunc func(DegradedReadStats)
+
+// ReencodeFunc - callback invoked when an object read is encoded with parameters not matching
+// the current disks, invoked on its own goroutine
+type ReencodeFunc func(ObjectMetadata)
+
+// RedundancyAlarm - an erasure coded object read or scrubbed with fewer redundant shards than the minimum
+type RedundancyAlarm struct {
+	Bucket      string
+	Object      string
+	Shards      int
+	DataDisks   uint8
+	ParityDisks uint8
+}
+
+// RedundancyAlarmFunc - callback invoked when an object falls below the minimum redundancy, invoked
+// on its own goroutine
+type RedundancyAlarmFunc func(RedundancyAlarm)
+
+// ParityFinding - shards of an object found missing or corrupt by a read verifying parity, Disks is
+// empty if the shards disagree without a single one being corrupt
+type ParityFinding struct {
+	Bucket string
+	Object string
+	Disks  []int
+	Time   time.Time
+}
+
+// readStats - internal degraded read counters, read callbacks, request metrics, not found objects
+// and shard read limits shared by all buckets
+type readStats struct {
+	lock          *sync.Mutex
+	totalReads    int64
+	degradedReads int64
+	buckets       map[string]int64
+	disks         map[int]int64
+	corruptSlices map[int]int64
+	threshold     float64
+	callback      DegradedReadFunc
+	reencode      ReencodeFunc
+	operations    map[string]map[string]*operationMetrics
+	notFound      *notFoundCache
+	minRedundancy int
+	redundancy    RedundancyAlarmFunc
+	shardReads    *shardReadLimiter
+	parity        []ParityFinding
+	paritySamples *paritySampleQueue
+}
+
+// newReadStats - instantiate new read stats
+func newReadStats() *readStats {
+	return &readStats{
+		lock:          new(sync.Mutex),
+		buckets:       make(map[string]int64),
+		disks:         make(map[int]int64),
+		corruptSlices: make(map[int]int64),
+		operations:    make(map[string]map[string]*operationMetrics),
+		notFound:      newNotFoundCache(),
+		shardReads:    newShardReadLimiter(),
+		paritySamples: newParitySampleQueue(),
+	}
+}
+
+// recordRead - record an erasure coded read, degraded if parity reconstruction was needed
+func (r *readStats) recordRead(bucket string, degraded bool, disks map[int]struct{}) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	r.totalReads++
+	for disk := range disks {
+		r.disks[disk]++
+	}
+	if !degraded {
+		r.lock.Unlock()
+		return
+	}
+	r.degradedReads++
+	r.buckets[bucket]++
+	callback := r.callback
+	exceeded := float64(r.degradedReads)/float64(r.totalReads) > r.threshold
+	stats := r.getStats()
+	r.lock.Unlock()
+
+	if callback != nil && exceeded {
+		callback(stats)
+	}
+}
+
+// recordCorruptSlice - record a slice read from a disk not matching its stored checksum
+func (r *readStats) recordCorruptSlice(disk int) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.corruptSlices[disk]++
+}
+
+// recordParityFinding - remember a finding of a parity sampled read for later healing
+func (r *readStats) recordParityFinding(finding ParityFinding) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.parity = append(r.parity, finding)
+	if len(r.parity) > maxParityFindings {
+		r.parity = r.parity[len(r.parity)-maxParityFindings:]
+	}
+}
+
+// recordReencode - notify that an object read should be re-encoded
+func (r *readStats) recordReencode(objMetadata ObjectMetadata) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	reencode := r.reencode
+	r.lock.Unlock()
+
+	if reencode != nil {
+		go reencode(objMetadata)
+	}
+}
+
+// recordRedundancy - notify if an object with unavailable shards is left with fewer shards beyond
+// its data shards than the minimum redundancy
+func (r *readStats) recordRedundancy(bucket, object string, k, m uint8, unavailable int) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	redundancy := r.redundancy
+	minRedundancy := r.minRedundancy
+	r.lock.Unlock()
+
+	shards := int(k) + int(m) - unavailable
+	if redundancy != nil && shards-int(k) < minRedundancy {
+		go redundancy(RedundancyAlarm{
+			Bucket:      bucket,
+			Object:      object,
+			Shards:      shards,
+			DataDisks:   k,
+			ParityDisks: m,
+		})
+	}
+}
+
+// getStats - copy of current counters, caller must hold the lock
+func (r *readStats) getStats() DegradedReadStats {
+	stats := DegradedReadStats{
+		TotalReads:    r.totalReads,
+		DegradedReads: r.degradedReads,
+		Buckets:       make(map[string]int64),
+		Disks:         make(map[int]int64),
+		CorruptSlices: make(map[int]int64),
+	}
+	for bucket, count := range r.buckets {
+		stats.Buckets[bucket] = count
+	}
+	for disk, count := range r.disks {
+		stats.Disks[disk] = count
+	}
+	for disk, count := range r.corruptSlices {
+		stats.CorruptSlices[disk] = count
+	}
+	return stats
+}
+
+// DegradedReadStats - return degraded read counters per bucket and per disk
+func (xl API) DegradedReadStats() DegradedReadStats {
+	xl.stats.lock.Lock()
+	defer xl.stats.lock.Unlock()
+	return xl.stats.getStats()
+}
+
+// SetDegradedReadThreshold - callback is invoked on every degraded read once the ratio of
+// degraded reads to total reads exceeds threshold, a 'nil' callback disables it
+func (xl API) SetDegradedReadThreshold(threshold float64, callback DegradedReadFunc) {
+	xl.stats.lock.Lock()
+	defer xl.stats.lock.Unlock()
+	xl.stats.threshold = threshold
+	xl.stats.callback = callback
+}
+
+// SetReencodeCallback - callback is invoked for every read of an object whose erasure coding
+// parameters no longer match the current disks, a 'nil' callback disables it
+func (xl API) SetReencodeCallback(callback ReencodeFunc) {
+	xl.stats.lock.Lock()
+	defer xl.stats.lock.Unlock()
+	xl.stats.reencode = callback
+}
+
+// SetRedundancyAlarm - callback is invoked for every read or scrub of an erasure coded object left with
+// fewer than minRedundancy shards beyond its data shards, a 'nil' callback disables it
+func (xl API) SetRedundancyAlarm(minRedundancy int, callback RedundancyAlarmFunc) {
+	xl.stats.lock.Lock()
+	defer xl.stats.lock.Unlock()
+	xl.stats.minRedundancy = minRedundancy
+	xl.stats.redundancy = callback
+}
+
+// ParityFindings - findings of reads verifying parity, oldest first. Objects found are to be healed
+// with HealObject of their bucket
+func (xl API) ParityFindings() []ParityFinding {
+	xl.stats.lock.Lock()
+	defer xl.stats.lock.Unlock()
+	findings := make([]ParityFinding, len(xl.stats.parity))
+	copy(findings, xl.stats.parity)
+	return findings
+}
@@ -0,0 +1,243 @@
+/*
+ * Minio Cloud Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/minio/minio/pkg/probe"
+)
+
+// leaves and nodes are hashed with distinct prefixes, such that a node never passes as a leaf
+const (
+	treeHashLeafPrefix = 0x00
+	treeHashNodePrefix = 0x01
+)
+
+// treeHash - merkle tree over the sha256 of every block of an object as stored, blocks
+// are added in order as they are written
+type treeHash struct {
+	leaves [][]byte
+}
+
+// addBlock - add the next block of an object, no-op on a nil tree
+func (t *treeHash) addBlock(block []byte) {
+	if t == nil {
+		return
+	}
+	t.leaves = append(t.leaves, hashTreeLeaf(block))
+}
+
+// getRoot - hex encoded root of the tree, empty for a nil tree
+func (t *treeHash) getRoot() string {
+	if t == nil || len(t.leaves) == 0 {
+		return ""
+	}
+	return hex.EncodeToString(getTreeHashRoot(t.leaves))
+}
+
+// hashTreeLeaf - hash of a block
+func hashTreeLeaf(block []byte) []byte {
+	hasher := sha256.New()
+	hasher.Write([]byte{treeHashLeafPrefix})
+	hasher.Write(block)
+	return hasher.Sum(nil)
+}
+
+// hashTreeNode - hash of two child nodes
+func hashTreeNode(left, right []byte) []byte {
+	hasher := sha256.New()
+	hasher.Write([]byte{treeHashNodePrefix})
+	hasher.Write(left)
+	hasher.Write(right)
+	return hasher.Sum(nil)
+}
+
+// nextTreeLevel - parent level of a level of the tree, a node without sibling is carried up as is
+func nextTreeLevel(level [][]byte) [][]byte {
+	var next [][]byte
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			next = append(next, level[i])
+			continue
+		}
+		next = append(next, hashTreeNode(level[i], level[i+1]))
+	}
+	return next
+}
+
+// getTreeHashRoot - root of the tree over leaves
+func getTreeHashRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		return nil
+	}
+	level := leaves
+	for len(level) > 1 {
+		level = nextTreeLevel(level)
+	}
+	return level[0]
+}
+
+// getTreeHashProof - sibling hashes from the leaf at index up to the root, levels where the
+// node has no sibling contribute nothing
+func getTreeHashProof(leaves [][]byte, index int) [][]byte {
+	var proof [][]byte
+	level := leaves
+	for len(level) > 1 {
+		if sibling := index ^ 1; sibling < len(level) {
+			proof = append(proof, level[sibling])
+		}
+		level = nextTreeLevel(level)
+		index = index / 2
+	}
+	return proof
+}
+
+// VerifyTreeHashProof - verify a block at index, of an object with count blocks, against the
+// hex encoded tree hash root using the hex encoded proof
+func VerifyTreeHashProof(block []byte, index, count int, proof []string, root string) bool {
+	if index < 0 || index >= count {
+		return false
+	}
+	hash := hashTreeLeaf(block)
+	for count > 1 {
+		if sibling := index ^ 1; sibling < count {
+			if len(proof) == 0 {
+				return false
+			}
+			siblingHash, err := hex.DecodeString(proof[0])
+			if err != nil {
+				return false
+			}
+			proof = proof[1:]
+			if index%2 == 0 {
+				hash = hashTreeNode(hash, siblingHash)
+			} else {
+				hash = hashTreeNode(siblingHash, hash)
+			}
+		}
+		index = index / 2
+		count = (count + 1) / 2
+	}
+	return len(proof) == 0 && hex.EncodeToString(hash) == root
+}
+
+// readTreeHashLeaves - hash every stored block of an object again, all slices are read and decoded
+func (b bucket) readTreeHashLeaves(objectPath string, objMetadata ObjectMetadata, encoder encoder) ([][]byte, *probe.Error) {
+	readers, err := b.getObjectReaders(objectPath, "data")
+	if err != nil {
+		return nil, err.Trace()
+	}
+	for _, reader := range readers {
+		defer reader.Close()
+	}
+	var leaves [][]byte
+	err = forEachChunk(objMetadata, encoder, func(chunkLength, sliceLen int) *probe.Error {
+		block, _, err := b.decodeEncodedData(int64(chunkLength), int64(chunkLength), readers, encoder, objMetadata.getSliceHashes(len(leaves)), nil)
+		if err != nil {
+			return err.Trace()
+		}
+		leaves = append(leaves, hashTreeLeaf(block))
+		return nil
+	})
+	if err != nil {
+		return nil, err.Trace()
+	}
+	return leaves, nil
+}
+
+// TreeHashProof - hex encoded proof of the block at index, for objects written with tree hashing.
+// Only the root is stored, the whole object is read to hash all blocks and the proof is given only
+// if they still hash to the root
+func (b bucket) TreeHashProof(objectName string, index int) ([]string, *probe.Error) {
+	defer b.rlockObject(objectName)()
+	objectPath := b.getObjectPath(objectName)
+	objMetadata, err := b.readObjectMetadata(objectPath)
+	if err != nil {
+		return nil, err.Trace()
+	}
+	if objMetadata.TreeHash == "" || index < 0 || index >= objMetadata.ChunkCount {
+		return nil, probe.NewError(InvalidArgument{})
+	}
+	encoder, err := newEncoder(objMetadata.DataDisks, objMetadata.ParityDisks)
+	if err != nil {
+		return nil, err.Trace()
+	}
+	leaves, err := b.readTreeHashLeaves(objectPath, objMetadata, encoder)
+	if err != nil {
+		return nil, err.Trace()
+	}
+	if hex.EncodeToString(getTreeHashRoot(leaves)) != objMetadata.TreeHash {
+		return nil, probe.NewError(ChecksumMismatch{})
+	}
+	var proof []string
+	for _, hash := range getTreeHashProof(leaves, index) {
+		proof = append(proof, hex.EncodeToString(hash))
+	}
+	return proof, nil
+}
+
+// VerifyObjectBlock - verify a single stored block of an object written with tree hashing against
+// its tree hash root using the proof of the block, only the slices of that block are read and decoded
+func (b bucket) VerifyObjectBlock(objectName string, index int, proof []string) *probe.Error {
+	defer b.rlockObject(objectName)()
+	objectPath := b.getObjectPath(objectName)
+	objMetadata, err := b.readObjectMetadata(objectPath)
+	if err != nil {
+		return err.Trace()
+	}
+	if objMetadata.TreeHash == "" || index < 0 || index >= objMetadata.ChunkCount {
+		return probe.NewError(InvalidArgument{})
+	}
+	encoder, err := newEncoder(objMetadata.DataDisks, objMetadata.ParityDisks)
+	if err != nil {
+		return err.Trace()
+	}
+	// offset of the block in every slice and its length
+	var offset int64
+	var length, chunk int
+	err = forEachChunk(objMetadata, encoder, func(chunkLength, sliceLen int) *probe.Error {
+		if chunk < index {
+			offset += int64(sliceLen)
+		}
+		if chunk == index {
+			length = chunkLength
+		}
+		chunk++
+		return nil
+	})
+	if err != nil {
+		return err.Trace()
+	}
+	readers, err := b.getObjectReaders(objectPath, "data")
+	if err != nil {
+		return err.Trace()
+	}
+	for _, reader := range readers {
+		defer reader.Close()
+	}
+	skipShardReaders(readers, offset)
+	block, _, err := b.decodeEncodedData(int64(length), int64(length), readers, encoder, objMetadata.getSliceHashes(index), nil)
+	if err != nil {
+		return err.Trace()
+	}
+	if !VerifyTreeHashProof(block, index, objMetadata.ChunkCount, proof, objMetadata.TreeHash) {
+		return probe.NewError(ChecksumMismatch{})
+	}
+	return nil
+}
@@ -0,0 +1,592 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio/pkg/probe"
+)
+
+// maxPartID - highest part number of a multipart upload, matching AWS S3
+const maxPartID = 10000
+
+// NewMultipartUpload - initiate a multipart upload of an object, returns the upload id parts are
+// uploaded with. An upload already in progress for the object is replaced and its parts removed
+func (b bucket) NewMultipartUpload(objectName string) (string, *probe.Error) {
+	defer b.lockObject(objectName)()
+	defer lockMetadata(b.xlName)()
+	if !IsValidObjectName(objectName) || isReservedObjectName(objectName, b.reservedPrefixes) {
+		return "", probe.NewError(ObjectNameInvalid{Bucket: b.getBucketName(), Object: objectName})
+	}
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return "", err.Trace()
+	}
+	metadata, ok := bucketMetadata.Buckets[b.getBucketName()]
+	if !ok {
+		return "", probe.NewError(BucketNotFound{Bucket: b.getBucketName()})
+	}
+	if metadata.Multiparts == nil {
+		metadata.Multiparts = make(map[string]MultiPartSession)
+	}
+	metadata, stale := reconcileMultiparts(metadata)
+	previous, replaced := metadata.Multiparts[objectName]
+
+	id := []byte(strconv.Itoa(rand.Int()) + b.getBucketName() + objectName + time.Now().UTC().String())
+	uploadIDSum := sha512.Sum512(id)
+	uploadID := base64.URLEncoding.EncodeToString(uploadIDSum[:])[:47]
+	metadata.Multiparts[objectName] = MultiPartSession{
+		UploadID:  uploadID,
+		Initiated: time.Now().UTC(),
+		Parts:     make(map[string]PartMetadata),
+	}
+	bucketMetadata.Buckets[b.getBucketName()] = metadata
+	if err := b.saveBucketMetadata(bucketMetadata); err != nil {
+		return "", err.Trace()
+	}
+	if replaced {
+		stale[objectName] = previous
+	}
+	for name, session := range stale {
+		if err := b.removeUploadParts(name, session); err != nil {
+			return uploadID, err.Trace()
+		}
+	}
+	return uploadID, nil
+}
+
+// PutObjectPart - upload a part of a multipart upload, stored erasure coded like an object of its
+// own. Uploading a part number again replaces the part, an empty expectedMD5Sum is not verified.
+// Parts count against the bucket quota, parts taking the bucket beyond it fail with QuotaExceeded
+func (b bucket) PutObjectPart(objectName, uploadID string, partID int, data io.Reader, size int64, expectedMD5Sum string) (PartMetadata, *probe.Error) {
+	if partID < 1 || partID > maxPartID {
+		return PartMetadata{}, probe.NewError(InvalidArgument{})
+	}
+	partName := getPartName(objectName, uploadID, partID)
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return PartMetadata{}, err.Trace()
+	}
+	metadata, session, err := b.getUploadSession(bucketMetadata, objectName, uploadID)
+	if err != nil {
+		return PartMetadata{}, err.Trace()
+	}
+	// parts of unknown size are verified against the quota once written
+	if size >= 0 {
+		if err := b.checkQuota(metadata, size, session.Parts[strconv.Itoa(partID)].Size); err != nil {
+			return PartMetadata{}, err.Trace()
+		}
+	}
+	// parts are written under a name of their own and replace the part they upload again only once
+	// accepted, parts of an upload and writes of the same part proceed concurrently
+	stagedName := fmt.Sprintf("%s$%d", partName, rand.Int63())
+	unlock := b.lockObject(stagedName)
+	objMetadata, err := b.writeObject(context.Background(), stagedName, data, size, WriteOptions{ExpectedMD5Sum: expectedMD5Sum, skipObjectCount: true, uploadPart: true})
+	unlock()
+	if err != nil {
+		return PartMetadata{}, err.Trace()
+	}
+
+	// the upload may have been completed or aborted while the part was written
+	defer lockMetadata(b.xlName)()
+	part, err := b.commitObjectPart(objectName, uploadID, partID, stagedName, objMetadata)
+	if err != nil {
+		b.removeObjectSlices(normalizeObjectName(stagedName), "", nil)
+		return PartMetadata{}, err.Trace()
+	}
+	return part, nil
+}
+
+// commitObjectPart - replace part partID of an upload by the part written under stagedName, once
+// the upload is still in progress and the part fits the bucket quota
+func (b bucket) commitObjectPart(objectName, uploadID string, partID int, stagedName string, objMetadata ObjectMetadata) (PartMetadata, *probe.Error) {
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return PartMetadata{}, err.Trace()
+	}
+	metadata, session, err := b.getUploadSession(bucketMetadata, objectName, uploadID)
+	if err != nil {
+		return PartMetadata{}, err.Trace()
+	}
+	// parts written concurrently are reserved against the quota one at a time
+	previous, replaced := session.Parts[strconv.Itoa(partID)]
+	if err := b.checkQuota(metadata, objMetadata.Size, previous.Size); err != nil {
+		return PartMetadata{}, err.Trace()
+	}
+	partName := getPartName(objectName, uploadID, partID)
+	if err := b.renameObjectSlices(normalizeObjectName(stagedName), normalizeObjectName(partName)); err != nil {
+		return PartMetadata{}, err.Trace()
+	}
+	objMetadata.Object = partName
+	if err := b.writeObjectMetadata(normalizeObjectName(partName), objMetadata); err != nil {
+		return PartMetadata{}, err.Trace()
+	}
+	part := PartMetadata{
+		PartNumber:   partID,
+		LastModified: objMetadata.Created,
+		ETag:         objMetadata.MD5Sum,
+		Size:         objMetadata.Size,
+	}
+	if !replaced {
+		session.TotalParts++
+	}
+	session.Parts[strconv.Itoa(partID)] = part
+	metadata.Multiparts[objectName] = session
+	bucketMetadata.Buckets[b.getBucketName()] = metadata
+	if err := b.saveBucketMetadata(bucketMetadata); err != nil {
+		return PartMetadata{}, err.Trace()
+	}
+	return part, nil
+}
+
+// CompleteMultipartUpload - assemble the object from the given parts in order of their part numbers,
+// parts not given are discarded. The object ETag is the md5sum of the md5sums of its parts suffixed
+// with the number of parts, the parts and their offsets are kept in the object metadata. Erasure
+// coded parts are stitched together on every disk without decoding them, other parts are encoded
+// anew. Only writes of the object wait while parts are assembled, the bucket metadata is locked
+// once the object is staged
+func (b bucket) CompleteMultipartUpload(objectName, uploadID string, parts []CompletePart) (ObjectMetadata, *probe.Error) {
+	defer b.lockObject(objectName)()
+	if len(parts) == 0 {
+		return ObjectMetadata{}, probe.NewError(InvalidPart{})
+	}
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	metadata, session, err := b.getUploadSession(bucketMetadata, objectName, uploadID)
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	if err := b.checkObjectCount(metadata, objectName); err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	objectParts, err := getCompleteParts(session, parts)
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	var partSums []byte
+	var partMetadatas []ObjectMetadata
+	var size int64
+	for _, part := range objectParts {
+		partSum, e := hex.DecodeString(part.ETag)
+		if e != nil {
+			return ObjectMetadata{}, probe.NewError(e)
+		}
+		partSums = append(partSums, partSum...)
+		partMetadata, err := b.readObjectMetadata(normalizeObjectName(getPartName(objectName, uploadID, part.PartNumber)))
+		if err != nil {
+			return ObjectMetadata{}, err.Trace()
+		}
+		// the part was uploaded again since the session was read
+		if partMetadata.MD5Sum != part.ETag {
+			return ObjectMetadata{}, probe.NewError(InvalidPart{})
+		}
+		partMetadatas = append(partMetadatas, partMetadata)
+		size += part.Size
+	}
+	compositeSum := md5.Sum(partSums)
+	etag := fmt.Sprintf("%s-%d", hex.EncodeToString(compositeSum[:]), len(objectParts))
+
+	stagingPath := normalizeObjectName(getUploadName(objectName, uploadID))
+	objMetadata, stitched, err := b.stitchParts(objectName, uploadID, stagingPath, objectParts, partMetadatas)
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	if stitched {
+		objMetadata.ETag = etag
+		objMetadata.Parts = objectParts
+		objMetadata.Metadata, objMetadata.ContentType = normalizeMetadata(nil)
+		if err := b.writeObjectMetadata(stagingPath, objMetadata); err != nil {
+			b.removeObjectSlices(stagingPath, "", nil)
+			return ObjectMetadata{}, err.Trace()
+		}
+	} else {
+		reader, writer := io.Pipe()
+		// closing the reader stops merging parts on failures
+		go b.mergeParts(objectName, uploadID, partMetadatas, objectParts, writer)
+		// the object count is verified again once committed
+		objMetadata, err = b.writeObject(context.Background(), objectName, reader, size, WriteOptions{
+			Parts:           objectParts,
+			skipObjectCount: true,
+			stagingPath:     stagingPath,
+			etag:            etag,
+		})
+		reader.Close()
+		if err != nil {
+			b.removeObjectSlices(stagingPath, "", nil)
+			return ObjectMetadata{}, err.Trace()
+		}
+	}
+
+	unlock := lockMetadata(b.xlName)
+	session, err = b.commitMultipartUpload(objectName, uploadID, stagingPath, objMetadata)
+	unlock()
+	if err != nil {
+		b.removeObjectSlices(stagingPath, "", nil)
+		return ObjectMetadata{}, err.Trace()
+	}
+	b.forgetNotFound(objectName)
+	if err := b.removeUploadParts(objectName, session); err != nil {
+		return objMetadata, err.Trace()
+	}
+	return objMetadata, b.forgetMultipartUpload(objectName, uploadID).Trace()
+}
+
+// getCompleteParts - parts of an upload as listed for completion, with their offsets in the object
+func getCompleteParts(session MultiPartSession, parts []CompletePart) ([]PartMetadata, *probe.Error) {
+	var objectParts []PartMetadata
+	var offset int64
+	for i, part := range parts {
+		if i > 0 && part.PartNumber <= parts[i-1].PartNumber {
+			return nil, probe.NewError(InvalidPartOrder{UploadID: session.UploadID})
+		}
+		storedPart, ok := session.Parts[strconv.Itoa(part.PartNumber)]
+		if !ok || strings.Trim(part.ETag, "\"") != storedPart.ETag {
+			return nil, probe.NewError(InvalidPart{})
+		}
+		storedPart.Offset = offset
+		offset += storedPart.Size
+		objectParts = append(objectParts, storedPart)
+	}
+	return objectParts, nil
+}
+
+// commitMultipartUpload - move the object staged under stagingPath into place and add it to the
+// bucket index, once the upload is still in progress with the parts the object was assembled from.
+// The upload is marked completed, such that it is dropped even if removing its parts fails. Must be
+// called with the bucket metadata locked, returns the upload whose parts are left to be removed
+func (b bucket) commitMultipartUpload(objectName, uploadID, stagingPath string, objMetadata ObjectMetadata) (MultiPartSession, *probe.Error) {
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return MultiPartSession{}, err.Trace()
+	}
+	metadata, session, err := b.getUploadSession(bucketMetadata, objectName, uploadID)
+	if err != nil {
+		return MultiPartSession{}, err.Trace()
+	}
+	// parts uploaded again while the object was assembled
+	for _, part := range objMetadata.Parts {
+		if session.Parts[strconv.Itoa(part.PartNumber)].ETag != part.ETag {
+			return MultiPartSession{}, probe.NewError(InvalidPart{})
+		}
+	}
+	if err := b.checkObjectMutable(metadata, objectName); err != nil {
+		return MultiPartSession{}, err.Trace()
+	}
+	if err := b.checkObjectCount(metadata, objectName); err != nil {
+		return MultiPartSession{}, err.Trace()
+	}
+	// parts already count against the quota, an object overwritten no longer does
+	replacedSize, err := b.indexedObjectSize(metadata, objectName)
+	if err != nil {
+		return MultiPartSession{}, err.Trace()
+	}
+	if err := b.renameObjectSlices(stagingPath, normalizeObjectName(objectName)); err != nil {
+		return MultiPartSession{}, err.Trace()
+	}
+	if err := b.removeLegacyObjectSlices(objectName); err != nil {
+		return MultiPartSession{}, err.Trace()
+	}
+
+	session.Completed = true
+	metadata.Multiparts[objectName] = session
+	if metadata.BucketObjects == nil {
+		metadata.BucketObjects = make(map[string]struct{})
+	}
+	metadata.BucketObjects[objectName] = struct{}{}
+	metadata = addUsage(metadata, objMetadata.Size-replacedSize)
+	metadata = recordObjectChange(metadata, objectName, objMetadata.Created)
+	metadata = advanceGeneration(metadata)
+	bucketMetadata.Buckets[b.getBucketName()] = metadata
+	if err := b.saveBucketMetadata(bucketMetadata); err != nil {
+		return MultiPartSession{}, err.Trace()
+	}
+	return session, nil
+}
+
+// forgetMultipartUpload - drop a completed upload from the bucket metadata once its parts are removed
+func (b bucket) forgetMultipartUpload(objectName, uploadID string) *probe.Error {
+	defer lockMetadata(b.xlName)()
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return err.Trace()
+	}
+	metadata, ok := bucketMetadata.Buckets[b.getBucketName()]
+	if !ok {
+		return probe.NewError(BucketNotFound{Bucket: b.getBucketName()})
+	}
+	// the object is locked, the upload can only have been replaced by dropping it
+	session, ok := metadata.Multiparts[objectName]
+	if !ok || session.UploadID != uploadID {
+		return nil
+	}
+	delete(metadata.Multiparts, objectName)
+	bucketMetadata.Buckets[b.getBucketName()] = metadata
+	return b.saveBucketMetadata(bucketMetadata).Trace()
+}
+
+// AbortMultipartUpload - abort a multipart upload and remove the slices of all its parts
+func (b bucket) AbortMultipartUpload(objectName, uploadID string) *probe.Error {
+	defer b.lockObject(objectName)()
+	defer lockMetadata(b.xlName)()
+	bucketMetadata, err := b.getBucketMetadata()
+	if err != nil {
+		return err.Trace()
+	}
+	metadata, ok := bucketMetadata.Buckets[b.getBucketName()]
+	if !ok {
+		return probe.NewError(BucketNotFound{Bucket: b.getBucketName()})
+	}
+	// completed uploads left behind can still be aborted, removing their parts
+	session, ok := metadata.Multiparts[objectName]
+	if !ok || session.UploadID != uploadID {
+		return probe.NewError(InvalidUploadID{UploadID: uploadID})
+	}
+	delete(metadata.Multiparts, objectName)
+	bucketMetadata.Buckets[b.getBucketName()] = metadata
+	if err := b.saveBucketMetadata(bucketMetadata); err != nil {
+		return err.Trace()
+	}
+	return b.removeUploadParts(objectName, session).Trace()
+}
+
+// getUploadSession - bucket metadata and the upload in progress of an object, matching uploadID
+func (b bucket) getUploadSession(bucketMetadata *AllBuckets, objectName, uploadID string) (BucketMetadata, MultiPartSession, *probe.Error) {
+	metadata, ok := bucketMetadata.Buckets[b.getBucketName()]
+	if !ok {
+		return BucketMetadata{}, MultiPartSession{}, probe.NewError(BucketNotFound{Bucket: b.getBucketName()})
+	}
+	session, ok := metadata.Multiparts[objectName]
+	if !ok || session.UploadID != uploadID || session.Completed {
+		return BucketMetadata{}, MultiPartSession{}, probe.NewError(InvalidUploadID{UploadID: uploadID})
+	}
+	if session.Parts == nil {
+		session.Parts = make(map[string]PartMetadata)
+	}
+	return metadata, session, nil
+}
+
+// reconcileMultiparts - drop uploads completed into their object but left behind, such that an
+// object name is either a completed object or being uploaded. Uploads in progress are kept whether
+// or not the object exists, they replace it once completed. Returns the uploads dropped, whose parts
+// are left to be removed
+func reconcileMultiparts(metadata BucketMetadata) (BucketMetadata, map[string]MultiPartSession) {
+	stale := make(map[string]MultiPartSession)
+	for objectName, session := range metadata.Multiparts {
+		if session.Completed {
+			stale[objectName] = session
+			delete(metadata.Multiparts, objectName)
+		}
+	}
+	return metadata, stale
+}
+
+// mergeParts - write the data of parts into writer one after another, every part is read to its end
+// such that it is verified against its checksums
+func (b bucket) mergeParts(objectName, uploadID string, partMetadatas []ObjectMetadata, parts []PartMetadata, writer *io.PipeWriter) {
+	for i, part := range parts {
+		partReader, partWriter := io.Pipe()
+		go b.readObjectData(context.Background(), normalizeObjectName(getPartName(objectName, uploadID, part.PartNumber)), partWriter, partMetadatas[i], nil)
+		n, e := io.Copy(writer, partReader)
+		partReader.Close()
+		if e == nil && n != part.Size {
+			e = io.ErrUnexpectedEOF
+		}
+		if e != nil {
+			writer.CloseWithError(e)
+			return
+		}
+	}
+	writer.Close()
+}
+
+// isStitchable - parts can be stitched together if all are erasure coded alike and keep the hashes
+// of their encoded blocks beside their data slices
+func isStitchable(partMetadatas []ObjectMetadata) bool {
+	first := partMetadatas[0]
+	for _, partMetadata := range partMetadatas {
+		switch {
+		case partMetadata.Size == 0 || partMetadata.DataDisks == 0 || partMetadata.ReplicaDisks > 0:
+			return false
+		case partMetadata.Compression != "" || partMetadata.TreeHash != "":
+			return false
+		case !partMetadata.SliceHashSidecar || len(partMetadata.SliceHashes) > 0:
+			return false
+		case partMetadata.DataDisks != first.DataDisks || partMetadata.ParityDisks != first.ParityDisks:
+			return false
+		}
+	}
+	return true
+}
+
+// getChunkSizes - size of every chunk of an erasure coded object
+func getChunkSizes(objMetadata ObjectMetadata) []int64 {
+	if len(objMetadata.ChunkSizes) > 0 {
+		return objMetadata.ChunkSizes
+	}
+	chunkSizes := make([]int64, objMetadata.ChunkCount)
+	totalLeft := objMetadata.Size
+	for i := range chunkSizes {
+		chunkSizes[i] = int64(objMetadata.BlockSize)
+		if chunkSizes[i] > totalLeft {
+			chunkSizes[i] = totalLeft
+		}
+		totalLeft = totalLeft - chunkSizes[i]
+	}
+	return chunkSizes
+}
+
+// stitchParts - stage the object under stagingPath by concatenating the data slices and the slice
+// hashes of its parts on every disk, chunks are kept as encoded. Parts are read once in full to
+// verify them and checksum the object. Returns false with nothing staged if the parts cannot be
+// stitched, parts not erasure coded alike or missing slices on any disk are encoded anew
+func (b bucket) stitchParts(objectName, uploadID, stagingPath string, parts []PartMetadata, partMetadatas []ObjectMetadata) (ObjectMetadata, bool, *probe.Error) {
+	if !isStitchable(partMetadatas) {
+		return ObjectMetadata{}, false, nil
+	}
+	first := partMetadatas[0]
+	encoder, err := newEncoder(first.DataDisks, first.ParityDisks)
+	if err != nil {
+		return ObjectMetadata{}, false, err.Trace()
+	}
+	// bytes of the data slice and of the slice hashes every part keeps on each disk
+	var chunkSizes []int64
+	sliceLengths := make([]map[string]int64, len(partMetadatas))
+	for i, partMetadata := range partMetadatas {
+		var dataLength int64
+		for _, chunkSize := range getChunkSizes(partMetadata) {
+			blockLen, err := encoder.GetEncodedBlockLen(int(chunkSize))
+			if err != nil {
+				return ObjectMetadata{}, false, err.Trace()
+			}
+			dataLength += int64(blockLen)
+			chunkSizes = append(chunkSizes, chunkSize)
+		}
+		sliceLengths[i] = map[string]int64{
+			"data":            dataLength,
+			sliceHashesConfig: int64(partMetadata.ChunkCount * sha256.Size),
+		}
+	}
+
+	reader, writer := io.Pipe()
+	go b.mergeParts(objectName, uploadID, partMetadatas, parts, writer)
+	sumMD5 := md5.New()
+	sum512 := sha512.New()
+	_, e := io.Copy(io.MultiWriter(sumMD5, sum512), reader)
+	reader.Close()
+	if e != nil {
+		return ObjectMetadata{}, false, probe.NewError(e)
+	}
+
+	var writers []io.WriteCloser
+	nodeSlice := 0
+	for _, node := range b.getNodes() {
+		disks, err := node.ListDisks()
+		if err != nil {
+			CleanupWritersOnError(writers)
+			return ObjectMetadata{}, false, err.Trace()
+		}
+		for order, disk := range disks {
+			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, order)
+			for _, objectMeta := range []string{"data", sliceHashesConfig} {
+				sliceWriter, err := disk.CreateFile(filepath.Join(b.xlName, bucketSlice, stagingPath, objectMeta))
+				if err != nil {
+					CleanupWritersOnError(writers)
+					return ObjectMetadata{}, false, err.Trace()
+				}
+				writers = append(writers, sliceWriter)
+				for i, part := range parts {
+					partPath := normalizeObjectName(getPartName(objectName, uploadID, part.PartNumber))
+					sliceReader, err := disk.Open(filepath.Join(b.xlName, bucketSlice, partPath, objectMeta))
+					if err != nil {
+						CleanupWritersOnError(writers)
+						return ObjectMetadata{}, false, nil
+					}
+					n, e := io.Copy(sliceWriter, sliceReader)
+					sliceReader.Close()
+					if e != nil || n != sliceLengths[i][objectMeta] {
+						CleanupWritersOnError(writers)
+						return ObjectMetadata{}, false, nil
+					}
+				}
+			}
+		}
+		nodeSlice = nodeSlice + 1
+	}
+	if err := commitWriters(writers); err != nil {
+		b.removeObjectSlices(stagingPath, "", nil)
+		return ObjectMetadata{}, false, probe.NewError(err)
+	}
+
+	objMetadata := ObjectMetadata{
+		Version:              objectMetadataVersion,
+		Created:              time.Now().UTC(),
+		Bucket:               b.getBucketName(),
+		Object:               objectName,
+		DataDisks:            first.DataDisks,
+		ParityDisks:          first.ParityDisks,
+		RequestedParityDisks: first.RequestedParityDisks,
+		BlockSize:            first.BlockSize,
+		ChunkCount:           len(chunkSizes),
+		ChunkSizes:           getAdaptiveChunkSizes(chunkSizes, int64(first.BlockSize)),
+		MD5Sum:               hex.EncodeToString(sumMD5.Sum(nil)),
+		SHA512Sum:            hex.EncodeToString(sum512.Sum(nil)),
+		SliceHashSidecar:     true,
+	}
+	for _, partMetadata := range partMetadatas {
+		objMetadata.Size += partMetadata.Size
+	}
+	return objMetadata, true, nil
+}
+
+// removeUploadParts - remove the slices of all parts of an upload
+func (b bucket) removeUploadParts(objectName string, session MultiPartSession) *probe.Error {
+	for _, part := range session.Parts {
+		if err := b.removeObjectSlices(normalizeObjectName(getPartName(objectName, session.UploadID, part.PartNumber)), "", nil); err != nil {
+			return err.Trace()
+		}
+	}
+	return nil
+}
+
+// multipartPrefix - object names reserved for the parts of multipart uploads, such that parts never
+// collide with objects
+const multipartPrefix = ".multipart/"
+
+// getUploadName - name the parts of an upload are written under, upload ids never contain '/'
+func getUploadName(objectName, uploadID string) string {
+	return multipartPrefix + objectName + "/" + uploadID
+}
+
+// getPartName - name a part is written under like an object, never added to the bucket index
+func getPartName(objectName, uploadID string, partID int) string {
+	return fmt.Sprintf("%s/%d", getUploadName(objectName, uploadID), partID)
+}
@@ -0,0 +1,90 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xl
+
+import "strings"
+
+const (
+	// metadata key the content type of an object is stored under
+	contentTypeKey = "contentType"
+	// content type of objects written without one
+	defaultContentType = "application/octet-stream"
+	// prefix of user metadata keys, stored lowercased
+	userMetadataPrefix = "x-amz-meta-"
+)
+
+// hopByHopHeaders - headers meaningful only for a single connection, never stored with an object
+var hopByHopHeaders = map[string]struct{}{
+	"connection":          {},
+	"keep-alive":          {},
+	"proxy-authenticate":  {},
+	"proxy-authorization": {},
+	"proxy-connection":    {},
+	"te":                  {},
+	"trailer":             {},
+	"transfer-encoding":   {},
+	"upgrade":             {},
+}
+
+// normalizeMetadata - canonical form of the metadata of a write, the caller's metadata is left
+// untouched. Keys are matched trimmed and regardless of case, hop-by-hop headers are dropped, user
+// metadata keys are lowercased and the content type is kept under "contentType". The content type
+// may be given as "contentType" or as a Content-Type header, "contentType" takes precedence. Returns
+// the content type along with the metadata, application/octet-stream if none is given
+func normalizeMetadata(metadata map[string]string) (map[string]string, string) {
+	if metadata == nil {
+		return nil, defaultContentType
+	}
+	normalized := make(map[string]string, len(metadata))
+	var contentType, contentTypeHeader string
+	for key, value := range metadata {
+		key = strings.TrimSpace(key)
+		lowerKey := strings.ToLower(key)
+		if _, ok := hopByHopHeaders[lowerKey]; ok {
+			continue
+		}
+		switch {
+		case lowerKey == strings.ToLower(contentTypeKey):
+			contentType = strings.TrimSpace(value)
+		case lowerKey == "content-type":
+			contentTypeHeader = strings.TrimSpace(value)
+		case strings.HasPrefix(lowerKey, userMetadataPrefix):
+			normalized[lowerKey] = value
+		default:
+			normalized[key] = value
+		}
+	}
+	if contentType == "" {
+		contentType = contentTypeHeader
+	}
+	if contentType == "" {
+		return normalized, defaultContentType
+	}
+	normalized[contentTypeKey] = contentType
+	return normalized, contentType
+}
+
+// UserMetadata - user defined metadata of the object, keyed by lowercased x-amz-meta- header names
+func (o ObjectMetadata) UserMetadata() map[string]string {
+	userMetadata := make(map[string]string)
+	for key, value := range o.Metadata {
+		if strings.HasPrefix(strings.ToLower(key), userMetadataPrefix) {
+			userMetadata[strings.ToLower(key)] = value
+		}
+	}
+	return userMetadata
+}
@@ -17,6 +17,7 @@
 package xl
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -44,6 +45,7 @@ const (
 	// bucket, object metadata
 	bucketMetadataConfig = "bucketMetadata.json"
 	objectMetadataConfig = "objectMetadata.json"
+	objectTagsConfig     = "objectTags.json"
 
 	// versions
 	objectMetadataVersion = "1.0.0"
@@ -65,7 +67,7 @@ func (xl API) getBucketMetadata(bucketName string) (BucketMetadata, *probe.Error
 	if err := xl.listXLBuckets(); err != nil {
 		return BucketMetadata{}, err.Trace()
 	}
-	if _, ok := xl.buckets[bucketName]; !ok {
+	if _, ok := xl.getBucket(bucketName); !ok {
 		return BucketMetadata{}, probe.NewError(BucketNotFound{Bucket: bucketName})
 	}
 	metadata, err := xl.getXLBucketMetadata()
@@ -80,6 +82,7 @@ func (xl API) setBucketMetadata(bucketName string, bucketMetadata map[string]str
 	if err := xl.listXLBuckets(); err != nil {
 		return err.Trace()
 	}
+	defer lockMetadata(xl.config.XLName)()
 	metadata, err := xl.getXLBucketMetadata()
 	if err != nil {
 		return err.Trace()
@@ -94,6 +97,141 @@ func (xl API) setBucketMetadata(bucketName string, bucketMetadata map[string]str
 	return xl.setXLBucketMetadata(metadata)
 }
 
+// setBucketETagAlgorithm - set digest used as ETag for new objects in bucket
+func (xl API) setBucketETagAlgorithm(bucketName, algorithm string) *probe.Error {
+	if err := xl.listXLBuckets(); err != nil {
+		return err.Trace()
+	}
+	if _, ok := xl.getBucket(bucketName); !ok {
+		return probe.NewError(BucketNotFound{Bucket: bucketName})
+	}
+	defer lockMetadata(xl.config.XLName)()
+	metadata, err := xl.getXLBucketMetadata()
+	if err != nil {
+		return err.Trace()
+	}
+	bucketMetadata := metadata.Buckets[bucketName]
+	bucketMetadata.ETagAlgorithm = algorithm
+	metadata.Buckets[bucketName] = bucketMetadata
+	return xl.setXLBucketMetadata(metadata)
+}
+
+// setBucketLifecycle - set lifecycle rules expiring objects in bucket
+func (xl API) setBucketLifecycle(bucketName string, rules []LifecycleRule) *probe.Error {
+	if err := xl.listXLBuckets(); err != nil {
+		return err.Trace()
+	}
+	if _, ok := xl.getBucket(bucketName); !ok {
+		return probe.NewError(BucketNotFound{Bucket: bucketName})
+	}
+	defer lockMetadata(xl.config.XLName)()
+	metadata, err := xl.getXLBucketMetadata()
+	if err != nil {
+		return err.Trace()
+	}
+	bucketMetadata := metadata.Buckets[bucketName]
+	bucketMetadata.LifecycleRules = rules
+	metadata.Buckets[bucketName] = bucketMetadata
+	return xl.setXLBucketMetadata(metadata)
+}
+
+// setBucketImmutability - set the grace period after which objects in bucket become immutable
+func (xl API) setBucketImmutability(bucketName string, immutableAfter time.Duration) *probe.Error {
+	if err := xl.listXLBuckets(); err != nil {
+		return err.Trace()
+	}
+	if _, ok := xl.getBucket(bucketName); !ok {
+		return probe.NewError(BucketNotFound{Bucket: bucketName})
+	}
+	defer lockMetadata(xl.config.XLName)()
+	metadata, err := xl.getXLBucketMetadata()
+	if err != nil {
+		return err.Trace()
+	}
+	bucketMetadata := metadata.Buckets[bucketName]
+	bucketMetadata.ImmutableAfter = immutableAfter
+	metadata.Buckets[bucketName] = bucketMetadata
+	return xl.setXLBucketMetadata(metadata)
+}
+
+// setBucketMaxObjectCount - set the maximum count of objects in bucket
+func (xl API) setBucketMaxObjectCount(bucketName string, maxObjectCount int) *probe.Error {
+	if err := xl.listXLBuckets(); err != nil {
+		return err.Trace()
+	}
+	if _, ok := xl.getBucket(bucketName); !ok {
+		return probe.NewError(BucketNotFound{Bucket: bucketName})
+	}
+	defer lockMetadata(xl.config.XLName)()
+	metadata, err := xl.getXLBucketMetadata()
+	if err != nil {
+		return err.Trace()
+	}
+	bucketMetadata := metadata.Buckets[bucketName]
+	bucketMetadata.MaxObjectCount = maxObjectCount
+	metadata.Buckets[bucketName] = bucketMetadata
+	return xl.setXLBucketMetadata(metadata)
+}
+
+// setBucketBlockSize - set the size of the chunks new objects in bucket are erasure coded in, objects
+// already written are read by the block size kept with them
+func (xl API) setBucketBlockSize(bucketName string, size int64) *probe.Error {
+	if err := xl.listXLBuckets(); err != nil {
+		return err.Trace()
+	}
+	if _, ok := xl.getBucket(bucketName); !ok {
+		return probe.NewError(BucketNotFound{Bucket: bucketName})
+	}
+	defer lockMetadata(xl.config.XLName)()
+	metadata, err := xl.getXLBucketMetadata()
+	if err != nil {
+		return err.Trace()
+	}
+	bucketMetadata := metadata.Buckets[bucketName]
+	bucketMetadata.BlockSize = size
+	metadata.Buckets[bucketName] = bucketMetadata
+	if err := xl.setXLBucketMetadata(metadata); err != nil {
+		return err.Trace()
+	}
+	xl.lock.Lock()
+	defer xl.lock.Unlock()
+	if bkt, ok := xl.buckets[bucketName]; ok {
+		xl.buckets[bucketName] = bkt.withBucketSettings(bucketMetadata)
+	}
+	return nil
+}
+
+// setBucketQuota - set the bytes bucket may hold, the usage of the objects it holds is computed anew
+func (xl API) setBucketQuota(bucketName string, quota int64) *probe.Error {
+	if err := xl.listXLBuckets(); err != nil {
+		return err.Trace()
+	}
+	bkt, ok := xl.getBucket(bucketName)
+	if !ok {
+		return probe.NewError(BucketNotFound{Bucket: bucketName})
+	}
+	// objects are neither added nor removed while their sizes are summed up
+	bkt.lock.Lock()
+	defer bkt.lock.Unlock()
+	defer lockMetadata(xl.config.XLName)()
+	metadata, err := xl.getXLBucketMetadata()
+	if err != nil {
+		return err.Trace()
+	}
+	bucketMetadata := metadata.Buckets[bucketName]
+	bucketMetadata.Quota = quota
+	bucketMetadata.Usage = 0
+	for objectName := range bucketMetadata.BucketObjects {
+		size, err := bkt.indexedObjectSize(bucketMetadata, objectName)
+		if err != nil {
+			return err.Trace()
+		}
+		bucketMetadata = addUsage(bucketMetadata, size)
+	}
+	metadata.Buckets[bucketName] = bucketMetadata
+	return xl.setXLBucketMetadata(metadata)
+}
+
 // listBuckets - return list of buckets
 func (xl API) listBuckets() (map[string]BucketMetadata, *probe.Error) {
 	if err := xl.listXLBuckets(); err != nil {
@@ -117,10 +255,11 @@ func (xl API) listObjects(bucket, prefix, marker, delimiter string, maxkeys int)
 	if err := xl.listXLBuckets(); err != nil {
 		return ListObjectsResults{}, err.Trace()
 	}
-	if _, ok := xl.buckets[bucket]; !ok {
+	bkt, ok := xl.getBucket(bucket)
+	if !ok {
 		return ListObjectsResults{}, probe.NewError(BucketNotFound{Bucket: bucket})
 	}
-	listObjects, err := xl.buckets[bucket].ListObjects(prefix, marker, delimiter, maxkeys)
+	listObjects, err := bkt.ListObjects(prefix, marker, delimiter, maxkeys)
 	if err != nil {
 		return ListObjectsResults{}, err.Trace()
 	}
@@ -128,7 +267,7 @@ func (xl API) listObjects(bucket, prefix, marker, delimiter string, maxkeys int)
 }
 
 // putObject - put object
-func (xl API) putObject(bucket, object, expectedMD5Sum string, reader io.Reader, size int64, metadata map[string]string, signature *signature4.Sign) (ObjectMetadata, *probe.Error) {
+func (xl API) putObject(ctx context.Context, bucket, object, expectedMD5Sum string, reader io.Reader, size int64, metadata map[string]string, parts []PartMetadata, signature *signature4.Sign) (ObjectMetadata, *probe.Error) {
 	if bucket == "" || strings.TrimSpace(bucket) == "" {
 		return ObjectMetadata{}, probe.NewError(InvalidArgument{})
 	}
@@ -138,7 +277,8 @@ func (xl API) putObject(bucket, object, expectedMD5Sum string, reader io.Reader,
 	if err := xl.listXLBuckets(); err != nil {
 		return ObjectMetadata{}, err.Trace()
 	}
-	if _, ok := xl.buckets[bucket]; !ok {
+	bkt, ok := xl.getBucket(bucket)
+	if !ok {
 		return ObjectMetadata{}, probe.NewError(BucketNotFound{Bucket: bucket})
 	}
 	bucketMeta, err := xl.getXLBucketMetadata()
@@ -148,19 +288,74 @@ func (xl API) putObject(bucket, object, expectedMD5Sum string, reader io.Reader,
 	if _, ok := bucketMeta.Buckets[bucket].BucketObjects[object]; ok {
 		return ObjectMetadata{}, probe.NewError(ObjectExists{Object: object})
 	}
-	objMetadata, err := xl.buckets[bucket].WriteObject(object, reader, size, expectedMD5Sum, metadata, signature)
+	// objects of unknown size are verified against the quota once written
+	if size >= 0 {
+		if err := bkt.checkQuota(bucketMeta.Buckets[bucket], size, 0); err != nil {
+			return ObjectMetadata{}, err.Trace()
+		}
+	}
+	bkt.etagAlgorithm = bucketMeta.Buckets[bucket].ETagAlgorithm
+	objMetadata, err := bkt.writeObjectLocked(ctx, object, reader, size, WriteOptions{
+		ExpectedMD5Sum: expectedMD5Sum,
+		Metadata:       metadata,
+		Signature:      signature,
+		Parts:          parts,
+	})
+	if err != nil {
+		return ObjectMetadata{}, err.Trace()
+	}
+	// metadata is read again, it may have been updated while the object was written
+	defer lockMetadata(xl.config.XLName)()
+	bucketMeta, err = xl.getXLBucketMetadata()
 	if err != nil {
 		return ObjectMetadata{}, err.Trace()
 	}
+	if err := bkt.checkQuota(bucketMeta.Buckets[bucket], objMetadata.Size, 0); err != nil {
+		bkt.removeObjectSlices(normalizeObjectName(object), "", nil)
+		return ObjectMetadata{}, err.Trace()
+	}
+	// objects added while the object was written count against the limit as well
+	if err := bkt.checkObjectCount(bucketMeta.Buckets[bucket], object); err != nil {
+		bkt.removeObjectSlices(normalizeObjectName(object), "", nil)
+		return ObjectMetadata{}, err.Trace()
+	}
 	bucketMeta.Buckets[bucket].BucketObjects[object] = struct{}{}
+	bucketMetadata := addUsage(bucketMeta.Buckets[bucket], objMetadata.Size)
+	bucketMeta.Buckets[bucket] = advanceGeneration(recordObjectChange(bucketMetadata, object, objMetadata.Created))
 	if err := xl.setXLBucketMetadata(bucketMeta); err != nil {
 		return ObjectMetadata{}, err.Trace()
 	}
 	return objMetadata, nil
 }
 
+// listObjectChanges - list objects changed after a given sequence, or after a given time if not zero
+func (xl API) listObjectChanges(bucket string, sequence uint64, t time.Time) ([]ObjectChange, *probe.Error) {
+	if err := xl.listXLBuckets(); err != nil {
+		return nil, err.Trace()
+	}
+	if _, ok := xl.getBucket(bucket); !ok {
+		return nil, probe.NewError(BucketNotFound{Bucket: bucket})
+	}
+	bucketMeta, err := xl.getXLBucketMetadata()
+	if err != nil {
+		return nil, err.Trace()
+	}
+	if !t.IsZero() {
+		changes, err := listObjectChangesAfter(bucketMeta.Buckets[bucket], t)
+		if err != nil {
+			return nil, err.Trace()
+		}
+		return changes, nil
+	}
+	changes, err := listObjectChangesSince(bucketMeta.Buckets[bucket], sequence)
+	if err != nil {
+		return nil, err.Trace()
+	}
+	return changes, nil
+}
+
 // putObject - put object
-func (xl API) putObjectPart(bucket, object, expectedMD5Sum, uploadID string, partID int, reader io.Reader, size int64, metadata map[string]string, signature *signature4.Sign) (PartMetadata, *probe.Error) {
+func (xl API) putObjectPart(ctx context.Context, bucket, object, expectedMD5Sum, uploadID string, partID int, reader io.Reader, size int64, metadata map[string]string, signature *signature4.Sign) (PartMetadata, *probe.Error) {
 	if bucket == "" || strings.TrimSpace(bucket) == "" {
 		return PartMetadata{}, probe.NewError(InvalidArgument{})
 	}
@@ -170,7 +365,8 @@ func (xl API) putObjectPart(bucket, object, expectedMD5Sum, uploadID string, par
 	if err := xl.listXLBuckets(); err != nil {
 		return PartMetadata{}, err.Trace()
 	}
-	if _, ok := xl.buckets[bucket]; !ok {
+	bkt, ok := xl.getBucket(bucket)
+	if !ok {
 		return PartMetadata{}, probe.NewError(BucketNotFound{Bucket: bucket})
 	}
 	bucketMeta, err := xl.getXLBucketMetadata()
@@ -184,7 +380,7 @@ func (xl API) putObjectPart(bucket, object, expectedMD5Sum, uploadID string, par
 		return PartMetadata{}, probe.NewError(ObjectExists{Object: object})
 	}
 	objectPart := object + "/" + "multipart" + "/" + strconv.Itoa(partID)
-	objmetadata, err := xl.buckets[bucket].WriteObject(objectPart, reader, size, expectedMD5Sum, metadata, signature)
+	objmetadata, err := bkt.WriteObject(ctx, objectPart, reader, size, expectedMD5Sum, metadata, signature)
 	if err != nil {
 		return PartMetadata{}, err.Trace()
 	}
@@ -194,7 +390,16 @@ func (xl API) putObjectPart(bucket, object, expectedMD5Sum, uploadID string, par
 		ETag:         objmetadata.MD5Sum,
 		Size:         objmetadata.Size,
 	}
-	multipartSession := bucketMeta.Buckets[bucket].Multiparts[object]
+	// metadata is read again, it may have been updated while the part was written
+	defer lockMetadata(xl.config.XLName)()
+	bucketMeta, err = xl.getXLBucketMetadata()
+	if err != nil {
+		return PartMetadata{}, err.Trace()
+	}
+	multipartSession, ok := bucketMeta.Buckets[bucket].Multiparts[object]
+	if !ok {
+		return PartMetadata{}, probe.NewError(InvalidUploadID{UploadID: uploadID})
+	}
 	multipartSession.Parts[strconv.Itoa(partID)] = partMetadata
 	bucketMeta.Buckets[bucket].Multiparts[object] = multipartSession
 	if err := xl.setXLBucketMetadata(bucketMeta); err != nil {
@@ -204,7 +409,7 @@ func (xl API) putObjectPart(bucket, object, expectedMD5Sum, uploadID string, par
 }
 
 // getObject - get object
-func (xl API) getObject(bucket, object string) (reader io.ReadCloser, size int64, err *probe.Error) {
+func (xl API) getObject(ctx context.Context, bucket, object string) (reader io.ReadCloser, size int64, err *probe.Error) {
 	if bucket == "" || strings.TrimSpace(bucket) == "" {
 		return nil, 0, probe.NewError(InvalidArgument{})
 	}
@@ -214,10 +419,11 @@ func (xl API) getObject(bucket, object string) (reader io.ReadCloser, size int64
 	if err := xl.listXLBuckets(); err != nil {
 		return nil, 0, err.Trace()
 	}
-	if _, ok := xl.buckets[bucket]; !ok {
+	bkt, ok := xl.getBucket(bucket)
+	if !ok {
 		return nil, 0, probe.NewError(BucketNotFound{Bucket: bucket})
 	}
-	return xl.buckets[bucket].ReadObject(object)
+	return bkt.ReadObject(ctx, object, nil)
 }
 
 // getObjectMetadata - get object metadata
@@ -225,21 +431,30 @@ func (xl API) getObjectMetadata(bucket, object string) (ObjectMetadata, *probe.E
 	if err := xl.listXLBuckets(); err != nil {
 		return ObjectMetadata{}, err.Trace()
 	}
-	if _, ok := xl.buckets[bucket]; !ok {
+	bkt, ok := xl.getBucket(bucket)
+	if !ok {
 		return ObjectMetadata{}, probe.NewError(BucketNotFound{Bucket: bucket})
 	}
-	bucketMeta, err := xl.getXLBucketMetadata()
+	objectMetadata, err := bkt.StatObject(object)
 	if err != nil {
 		return ObjectMetadata{}, err.Trace()
 	}
-	if _, ok := bucketMeta.Buckets[bucket].BucketObjects[object]; !ok {
-		return ObjectMetadata{}, probe.NewError(ObjectNotFound{Object: object})
+	return objectMetadata, nil
+}
+
+// deleteObjects - delete objects, failures are reported per object in an AggregateError
+func (xl API) deleteObjects(bucket string, objects []string) *probe.Error {
+	if err := xl.listXLBuckets(); err != nil {
+		return err.Trace()
+	}
+	bkt, ok := xl.getBucket(bucket)
+	if !ok {
+		return probe.NewError(BucketNotFound{Bucket: bucket})
 	}
-	objectMetadata, err := xl.buckets[bucket].GetObjectMetadata(object)
-	if err != nil {
-		return ObjectMetadata{}, err.Trace()
+	if err := bkt.DeleteObjects(objects); err != nil {
+		return err.Trace()
 	}
-	return objectMetadata, nil
+	return nil
 }
 
 // newMultipartUpload - new multipart upload request
@@ -247,9 +462,10 @@ func (xl API) newMultipartUpload(bucket, object, contentType string) (string, *p
 	if err := xl.listXLBuckets(); err != nil {
 		return "", err.Trace()
 	}
-	if _, ok := xl.buckets[bucket]; !ok {
+	if _, ok := xl.getBucket(bucket); !ok {
 		return "", probe.NewError(BucketNotFound{Bucket: bucket})
 	}
+	defer lockMetadata(xl.config.XLName)()
 	allbuckets, err := xl.getXLBucketMetadata()
 	if err != nil {
 		return "", err.Trace()
@@ -292,7 +508,7 @@ func (xl API) listObjectParts(bucket, object string, resources ObjectResourcesMe
 	if err := xl.listXLBuckets(); err != nil {
 		return ObjectResourcesMetadata{}, err.Trace()
 	}
-	if _, ok := xl.buckets[bucket]; !ok {
+	if _, ok := xl.getBucket(bucket); !ok {
 		return ObjectResourcesMetadata{}, probe.NewError(BucketNotFound{Bucket: bucket})
 	}
 	allBuckets, err := xl.getXLBucketMetadata()
@@ -347,7 +563,7 @@ func (xl API) completeMultipartUpload(bucket, object, uploadID string, data io.R
 	if err := xl.listXLBuckets(); err != nil {
 		return ObjectMetadata{}, err.Trace()
 	}
-	if _, ok := xl.buckets[bucket]; !ok {
+	if _, ok := xl.getBucket(bucket); !ok {
 		return ObjectMetadata{}, probe.NewError(BucketNotFound{Bucket: bucket})
 	}
 	allBuckets, err := xl.getXLBucketMetadata()
@@ -417,7 +633,7 @@ func (xl API) listMultipartUploads(bucket string, resources BucketMultipartResou
 	if err := xl.listXLBuckets(); err != nil {
 		return BucketMultipartResourcesMetadata{}, err.Trace()
 	}
-	if _, ok := xl.buckets[bucket]; !ok {
+	if _, ok := xl.getBucket(bucket); !ok {
 		return BucketMultipartResourcesMetadata{}, probe.NewError(BucketNotFound{Bucket: bucket})
 	}
 	allbuckets, err := xl.getXLBucketMetadata()
@@ -427,6 +643,10 @@ func (xl API) listMultipartUploads(bucket string, resources BucketMultipartResou
 	bucketMetadata := allbuckets.Buckets[bucket]
 	var uploads []*UploadMetadata
 	for key, session := range bucketMetadata.Multiparts {
+		// completed uploads are only left until their parts are removed
+		if session.Completed {
+			continue
+		}
 		if strings.HasPrefix(key, resources.Prefix) {
 			if len(uploads) > resources.MaxUploads {
 				sort.Sort(byKey(uploads))
@@ -475,9 +695,10 @@ func (xl API) abortMultipartUpload(bucket, object, uploadID string) *probe.Error
 	if err := xl.listXLBuckets(); err != nil {
 		return err.Trace()
 	}
-	if _, ok := xl.buckets[bucket]; !ok {
+	if _, ok := xl.getBucket(bucket); !ok {
 		return probe.NewError(BucketNotFound{Bucket: bucket})
 	}
+	defer lockMetadata(xl.config.XLName)()
 	allbuckets, err := xl.getXLBucketMetadata()
 	if err != nil {
 		return err.Trace()
@@ -501,26 +722,6 @@ func (xl API) abortMultipartUpload(bucket, object, uploadID string) *probe.Error
 
 //// internal functions
 
-// getBucketMetadataWriters -
-func (xl API) getBucketMetadataWriters() ([]io.WriteCloser, *probe.Error) {
-	var writers []io.WriteCloser
-	for _, node := range xl.nodes {
-		disks, err := node.ListDisks()
-		if err != nil {
-			return nil, err.Trace()
-		}
-		writers = make([]io.WriteCloser, len(disks))
-		for order, disk := range disks {
-			bucketMetaDataWriter, err := disk.CreateFile(filepath.Join(xl.config.XLName, bucketMetadataConfig))
-			if err != nil {
-				return nil, err.Trace()
-			}
-			writers[order] = bucketMetaDataWriter
-		}
-	}
-	return writers, nil
-}
-
 // getBucketMetadataReaders - readers are returned in map rather than slice
 func (xl API) getBucketMetadataReaders() (map[int]io.ReadCloser, *probe.Error) {
 	readers := make(map[int]io.ReadCloser)
@@ -552,26 +753,21 @@ func (xl API) getBucketMetadataReaders() (map[int]io.ReadCloser, *probe.Error) {
 
 // setXLBucketMetadata -
 func (xl API) setXLBucketMetadata(metadata *AllBuckets) *probe.Error {
-	writers, err := xl.getBucketMetadataWriters()
-	if err != nil {
-		return err.Trace()
-	}
-	for _, writer := range writers {
-		jenc := json.NewEncoder(writer)
-		if err := jenc.Encode(metadata); err != nil {
-			CleanupWritersOnError(writers)
-			return probe.NewError(err)
+	disks := make(map[int]block.Block)
+	for _, node := range xl.nodes {
+		nDisks, err := node.ListDisks()
+		if err != nil {
+			return err.Trace()
+		}
+		for k, v := range nDisks {
+			disks[k] = v
 		}
 	}
-	for _, writer := range writers {
-		writer.Close()
-	}
-	return nil
+	return writeBucketMetadata(disks, filepath.Join(xl.config.XLName, bucketMetadataConfig), metadata, getMetadataWriteRetries(xl.config))
 }
 
 // getXLBucketMetadata -
 func (xl API) getXLBucketMetadata() (*AllBuckets, *probe.Error) {
-	metadata := &AllBuckets{}
 	readers, err := xl.getBucketMetadataReaders()
 	if err != nil {
 		return nil, err.Trace()
@@ -581,13 +777,22 @@ func (xl API) getXLBucketMetadata() (*AllBuckets, *probe.Error) {
 	}
 	{
 		var err error
-		for _, reader := range readers {
+		metadatas := make(map[int]*AllBuckets)
+		for order, reader := range readers {
+			metadata := new(AllBuckets)
 			jenc := json.NewDecoder(reader)
 			if err = jenc.Decode(metadata); err == nil {
-				return metadata, nil
+				metadatas[order] = metadata
 			}
 		}
-		return nil, probe.NewError(err)
+		if len(metadatas) == 0 {
+			return nil, probe.NewError(err)
+		}
+		metadata, _, perr := quorumBucketMetadata(metadatas, len(readers))
+		if perr != nil {
+			return nil, perr.Trace()
+		}
+		return metadata, nil
 	}
 }
 
@@ -596,15 +801,20 @@ func (xl API) makeXLBucket(bucketName, acl string) *probe.Error {
 	if err := xl.listXLBuckets(); err != nil {
 		return err.Trace()
 	}
-	if _, ok := xl.buckets[bucketName]; ok {
-		return probe.NewError(BucketExists{Bucket: bucketName})
-	}
-	bkt, bucketMetadata, err := newBucket(bucketName, acl, xl.config.XLName, xl.nodes)
+	bkt, bucketMetadata, err := newBucket(bucketName, acl, xl.config, xl.nodes, xl.stats)
 	if err != nil {
 		return err.Trace()
 	}
-	nodeNumber := 0
+	xl.lock.Lock()
+	if _, ok := xl.buckets[bucketName]; ok {
+		xl.lock.Unlock()
+		return probe.NewError(BucketExists{Bucket: bucketName})
+	}
+	// a new bucket holds no objects stored under their legacy name
+	bkt.setNamesEncoded(true)
 	xl.buckets[bucketName] = bkt
+	xl.lock.Unlock()
+	nodeNumber := 0
 	for _, node := range xl.nodes {
 		disks := make(map[int]block.Block)
 		disks, err = node.ListDisks()
@@ -620,6 +830,7 @@ func (xl API) makeXLBucket(bucketName, acl string) *probe.Error {
 		}
 		nodeNumber = nodeNumber + 1
 	}
+	defer lockMetadata(xl.config.XLName)()
 	var metadata *AllBuckets
 	metadata, err = xl.getXLBucketMetadata()
 	if err != nil {
@@ -664,18 +875,44 @@ func (xl API) listXLBuckets() *probe.Error {
 	if err != nil {
 		return err.Trace()
 	}
+	var allBuckets *AllBuckets
 	for _, dir := range dirs {
 		splitDir := strings.Split(dir.Name(), "$")
 		if len(splitDir) < 3 {
 			return probe.NewError(CorruptedBackend{Backend: dir.Name()})
 		}
 		bucketName := splitDir[0]
-		// we dont need this once we cache from makeXLBucket()
-		bkt, _, err := newBucket(bucketName, "private", xl.config.XLName, xl.nodes)
+		// buckets already known are kept, such that all requests share the locks of a bucket
+		if _, ok := xl.getBucket(bucketName); ok {
+			continue
+		}
+		bkt, _, err := newBucket(bucketName, "private", xl.config, xl.nodes, xl.stats)
 		if err != nil {
 			return err.Trace()
 		}
-		xl.buckets[bucketName] = bkt
+		// settings kept with the bucket take precedence over the config
+		if allBuckets == nil {
+			allBuckets, _ = xl.getXLBucketMetadata()
+		}
+		if allBuckets != nil {
+			bkt = bkt.withBucketSettings(allBuckets.Buckets[bucketName])
+		}
+		// objects not migrated yet, for instance while disks are missing, are still looked up under
+		// their legacy name
+		bkt.migrateObjectNames()
+		xl.lock.Lock()
+		if _, ok := xl.buckets[bucketName]; !ok {
+			xl.buckets[bucketName] = bkt
+		}
+		xl.lock.Unlock()
 	}
 	return nil
 }
+
+// getBucket - bucket of the given name, as last listed from disk
+func (xl API) getBucket(bucketName string) (bucket, bool) {
+	xl.lock.RLock()
+	defer xl.lock.RUnlock()
+	bkt, ok := xl.buckets[bucketName]
+	return bkt, ok
+}
@@ -18,15 +18,20 @@ package xl
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/base64"
 	"encoding/hex"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/minio/minio/pkg/probe"
 	. "gopkg.in/check.v1"
 )
 
@@ -156,7 +161,7 @@ func (s *MyXLSuite) TestCreateMultipleBucketsAndList(c *C) {
 
 // test object create without bucket
 func (s *MyXLSuite) TestNewObjectFailsWithoutBucket(c *C) {
-	_, err := dd.CreateObject("unknown", "obj", "", 0, nil, nil, nil)
+	_, err := dd.CreateObject(context.Background(), "unknown", "obj", "", 0, nil, nil, nil)
 	c.Assert(err, Not(IsNil))
 }
 
@@ -171,7 +176,7 @@ func (s *MyXLSuite) TestNewObjectMetadata(c *C) {
 	err := dd.MakeBucket("foo6", "private", nil, nil)
 	c.Assert(err, IsNil)
 
-	objectMetadata, err := dd.CreateObject("foo6", "obj", expectedMd5Sum, int64(len(data)), reader, map[string]string{"contentType": "application/json"}, nil)
+	objectMetadata, err := dd.CreateObject(context.Background(), "foo6", "obj", expectedMd5Sum, int64(len(data)), reader, map[string]string{"contentType": "application/json"}, nil)
 	c.Assert(err, IsNil)
 	c.Assert(objectMetadata.MD5Sum, Equals, hex.EncodeToString(hasher.Sum(nil)))
 	c.Assert(objectMetadata.Metadata["contentType"], Equals, "application/json")
@@ -179,7 +184,7 @@ func (s *MyXLSuite) TestNewObjectMetadata(c *C) {
 
 // test create object fails without name
 func (s *MyXLSuite) TestNewObjectFailsWithEmptyName(c *C) {
-	_, err := dd.CreateObject("foo", "", "", 0, nil, nil, nil)
+	_, err := dd.CreateObject(context.Background(), "foo", "", "", 0, nil, nil, nil)
 	c.Assert(err, Not(IsNil))
 }
 
@@ -195,12 +200,12 @@ func (s *MyXLSuite) TestNewObjectCanBeWritten(c *C) {
 	expectedMd5Sum := base64.StdEncoding.EncodeToString(hasher.Sum(nil))
 	reader := ioutil.NopCloser(bytes.NewReader([]byte(data)))
 
-	actualMetadata, err := dd.CreateObject("foo", "obj", expectedMd5Sum, int64(len(data)), reader, map[string]string{"contentType": "application/octet-stream"}, nil)
+	actualMetadata, err := dd.CreateObject(context.Background(), "foo", "obj", expectedMd5Sum, int64(len(data)), reader, map[string]string{"contentType": "application/octet-stream"}, nil)
 	c.Assert(err, IsNil)
 	c.Assert(actualMetadata.MD5Sum, Equals, hex.EncodeToString(hasher.Sum(nil)))
 
 	var buffer bytes.Buffer
-	size, err := dd.GetObject(&buffer, "foo", "obj", 0, 0)
+	size, err := dd.GetObject(context.Background(), &buffer, "foo", "obj", 0, 0)
 	c.Assert(err, IsNil)
 	c.Assert(size, Equals, int64(len(data)))
 	c.Assert(buffer.Bytes(), DeepEquals, []byte(data))
@@ -217,21 +222,21 @@ func (s *MyXLSuite) TestMultipleNewObjects(c *C) {
 
 	one := ioutil.NopCloser(bytes.NewReader([]byte("one")))
 
-	_, err := dd.CreateObject("foo5", "obj1", "", int64(len("one")), one, nil, nil)
+	_, err := dd.CreateObject(context.Background(), "foo5", "obj1", "", int64(len("one")), one, nil, nil)
 	c.Assert(err, IsNil)
 
 	two := ioutil.NopCloser(bytes.NewReader([]byte("two")))
-	_, err = dd.CreateObject("foo5", "obj2", "", int64(len("two")), two, nil, nil)
+	_, err = dd.CreateObject(context.Background(), "foo5", "obj2", "", int64(len("two")), two, nil, nil)
 	c.Assert(err, IsNil)
 
 	var buffer1 bytes.Buffer
-	size, err := dd.GetObject(&buffer1, "foo5", "obj1", 0, 0)
+	size, err := dd.GetObject(context.Background(), &buffer1, "foo5", "obj1", 0, 0)
 	c.Assert(err, IsNil)
 	c.Assert(size, Equals, int64(len([]byte("one"))))
 	c.Assert(buffer1.Bytes(), DeepEquals, []byte("one"))
 
 	var buffer2 bytes.Buffer
-	size, err = dd.GetObject(&buffer2, "foo5", "obj2", 0, 0)
+	size, err = dd.GetObject(context.Background(), &buffer2, "foo5", "obj2", 0, 0)
 	c.Assert(err, IsNil)
 	c.Assert(size, Equals, int64(len([]byte("two"))))
 
@@ -270,11 +275,11 @@ func (s *MyXLSuite) TestMultipleNewObjects(c *C) {
 	c.Assert(objectsMetadata[1].Object, Equals, "obj2")
 
 	three := ioutil.NopCloser(bytes.NewReader([]byte("three")))
-	_, err = dd.CreateObject("foo5", "obj3", "", int64(len("three")), three, nil, nil)
+	_, err = dd.CreateObject(context.Background(), "foo5", "obj3", "", int64(len("three")), three, nil, nil)
 	c.Assert(err, IsNil)
 
 	var buffer bytes.Buffer
-	size, err = dd.GetObject(&buffer, "foo5", "obj3", 0, 0)
+	size, err = dd.GetObject(context.Background(), &buffer, "foo5", "obj3", 0, 0)
 	c.Assert(err, IsNil)
 	c.Assert(size, Equals, int64(len([]byte("three"))))
 	c.Assert(buffer.Bytes(), DeepEquals, []byte("three"))
@@ -288,3 +293,108 @@ func (s *MyXLSuite) TestMultipleNewObjects(c *C) {
 	c.Assert(resources.IsTruncated, Equals, true)
 	c.Assert(len(objectsMetadata), Equals, 2)
 }
+
+// test deleting objects reports the objects which failed and deletes the others
+func (s *MyXLSuite) TestObjectsDeletedInBatch(c *C) {
+	c.Assert(dd.MakeBucket("foo8", "private", nil, nil), IsNil)
+	for _, object := range []string{"obj1", "obj2"} {
+		_, err := dd.CreateObject(context.Background(), "foo8", object, "", int64(len("hello")), bytes.NewReader([]byte("hello")), nil, nil)
+		c.Assert(err, IsNil)
+	}
+	_, err := dd.GetObjectMetadata("foo8", "obj1")
+	c.Assert(err, IsNil)
+
+	err = dd.DeleteObjects("foo8", []string{"obj1", "missing"})
+	c.Assert(err, Not(IsNil))
+	aggregate, ok := err.ToGoError().(AggregateError)
+	c.Assert(ok, Equals, true)
+	c.Assert(len(aggregate.Errors), Equals, 1)
+	_, ok = aggregate.Errors["missing"].(ObjectNotFound)
+	c.Assert(ok, Equals, true)
+
+	_, err = dd.GetObjectMetadata("foo8", "obj1")
+	c.Assert(err, Not(IsNil))
+	_, err = dd.GetObjectMetadata("foo8", "obj2")
+	c.Assert(err, IsNil)
+}
+
+// test objects are written while the body of another object is still being read
+func (s *MyXLSuite) TestObjectsWrittenConcurrently(c *C) {
+	c.Assert(dd.MakeBucket("foo9", "private", nil, nil), IsNil)
+	slowReader, slowWriter := io.Pipe()
+	slowDone := make(chan *probe.Error, 1)
+	go func() {
+		_, err := dd.CreateObject(context.Background(), "foo9", "slow", "", int64(len("hello")), slowReader, nil, nil)
+		slowDone <- err
+	}()
+
+	fastDone := make(chan *probe.Error, 1)
+	go func() {
+		_, err := dd.CreateObject(context.Background(), "foo9", "fast", "", int64(len("hello")), bytes.NewReader([]byte("hello")), nil, nil)
+		fastDone <- err
+	}()
+	select {
+	case err := <-fastDone:
+		c.Assert(err, IsNil)
+	case <-time.After(10 * time.Second):
+		c.Fatal("write blocked by the write of another object")
+	}
+	var buffer bytes.Buffer
+	_, err := dd.GetObject(context.Background(), &buffer, "foo9", "fast", 0, 0)
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, "hello")
+
+	_, e := slowWriter.Write([]byte("hello"))
+	c.Assert(e, IsNil)
+	c.Assert(slowWriter.Close(), IsNil)
+	c.Assert(<-slowDone, IsNil)
+	buffer.Reset()
+	_, err = dd.GetObject(context.Background(), &buffer, "foo9", "slow", 0, 0)
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, "hello")
+}
+
+// test writes and reads through the API stop once their context is cancelled
+func (s *MyXLSuite) TestObjectContextCancelled(c *C) {
+	c.Assert(dd.MakeBucket("foo10", "private", nil, nil), IsNil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := dd.CreateObject(ctx, "foo10", "obj", "", int64(len("hello")), bytes.NewReader([]byte("hello")), nil, nil)
+	c.Assert(err.ToGoError(), Equals, context.Canceled)
+	_, err = dd.CreateObject(context.Background(), "foo10", "obj", "", int64(len("hello")), bytes.NewReader([]byte("hello")), nil, nil)
+	c.Assert(err, IsNil)
+
+	// objects not cached are read from disk
+	dd.(API).objects.Delete("foo10/obj")
+	var buffer bytes.Buffer
+	_, err = dd.GetObject(ctx, &buffer, "foo10", "obj", 0, 0)
+	c.Assert(err, Not(IsNil))
+	c.Assert(buffer.Len(), Equals, 0)
+}
+
+// test bucket settings updated concurrently are all kept in the bucket metadata
+func (s *MyXLSuite) TestUpdateBucketSettingsConcurrently(c *C) {
+	c.Assert(dd.MakeBucket("foo11", "private", nil, nil), IsNil)
+	var wg sync.WaitGroup
+	errs := make(chan *probe.Error, 2*10)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- dd.(API).SetBucketImmutability("foo11", time.Hour)
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- dd.(API).SetBucketMaxObjectCount("foo11", 5)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		c.Assert(err, IsNil)
+	}
+	metadata, err := dd.(API).getXLBucketMetadata()
+	c.Assert(err, IsNil)
+	c.Assert(metadata.Buckets["foo11"].ImmutableAfter, Equals, time.Hour)
+	c.Assert(metadata.Buckets["foo11"].MaxObjectCount, Equals, 5)
+}
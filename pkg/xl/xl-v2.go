@@ -18,6 +18,7 @@ package xl
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/base64"
 	"encoding/hex"
@@ -50,17 +51,55 @@ type Config struct {
 	MaxSize     uint64              `json:"max-size"`
 	XLName      string              `json:"xl-name"`
 	NodeDiskMap map[string][]string `json:"node-disk-map"`
+	// objects up to this size are replicated, defaults to 4KiB if not set
+	SmallObjectSize int64 `json:"small-object-size"`
+	// pre-create object directories on all disks before streaming data
+	PreProvisionDirs bool `json:"pre-provision-dirs"`
+	// compress erasure coded objects at rest, "gzip" or "zlib"
+	Compression string `json:"compression"`
+	// batch encoded blocks written to a disk into fewer writes
+	BatchShardWrites bool `json:"batch-shard-writes"`
+	// retries of bucket metadata writes failing on a disk, defaults to 3 if not set, negative disables retries
+	MetadataWriteRetries int `json:"metadata-write-retries"`
+	// hash object data on a goroutine per checksum, spreading the hashing of large objects across cores
+	ParallelHashing bool `json:"parallel-hashing"`
+	// a disk not completing a slice read within this duration is treated as failed, no timeout if not set
+	DiskReadTimeout time.Duration `json:"disk-read-timeout"`
+	// record a merkle tree hash over the blocks of erasure coded objects, blocks are verifiable one at a time
+	TreeHash bool `json:"tree-hash"`
+	// remember objects found not to exist for this duration, lookups of them skip the disks, disabled if not set
+	NotFoundCacheTTL time.Duration `json:"not-found-cache-ttl"`
+	// maximum number of objects remembered as not existing, defaults to 1024
+	NotFoundCacheSize int `json:"not-found-cache-size"`
+	// object name prefixes reserved for system use, in addition to the names of internal files
+	ReservedObjectPrefixes []string `json:"reserved-object-prefixes"`
+	// maximum shard reads in flight on a disk across all requests and the scrubber, unlimited if not set
+	ShardReadsPerDisk int `json:"shard-reads-per-disk"`
+	// store sha512sums supplied by writers without computing them again, for trusted pipelines only
+	TrustSuppliedSHA512 bool `json:"trust-supplied-sha512"`
+	// place shards on nodes sorted by name, such that placement is reproducible across runs. Kept with
+	// buckets when made
+	DeterministicPlacement bool `json:"deterministic-placement"`
+	// fraction of chunks decoded by whole object reads whose shards are also verified against each other
+	// once served, in the background. Corrupt or missing shards found are recorded for healing. Disabled
+	// if not set, at most one
+	ParityVerifyRate float64 `json:"parity-verify-rate"`
+	// size of the chunks objects of buckets made afterwards are erasure coded in, recorded per object.
+	// Defaults to 10MiB if not set, from 64KiB up to 1GiB
+	BlockSize int64 `json:"block-size"`
 }
 
 // API - local variables
 type API struct {
 	config           *Config
-	lock             *sync.Mutex
+	lock             *sync.RWMutex
+	objectLocks      *objectLocker
 	objects          *data.Cache
 	multiPartObjects map[string]*data.Cache
 	storedBuckets    *metadata.Cache
 	nodes            map[string]node
 	buckets          map[string]bucket
+	stats            *readStats
 }
 
 // storedBucket saved bucket
@@ -87,14 +126,20 @@ func New() (Interface, *probe.Error) {
 			return nil, err.Trace()
 		}
 	}
+	if err := checkConfig(conf); err != nil {
+		return nil, err.Trace()
+	}
 	a := API{config: conf}
+	a.lock = new(sync.RWMutex)
+	a.objectLocks = newObjectLocker()
 	a.storedBuckets = metadata.NewCache()
 	a.nodes = make(map[string]node)
 	a.buckets = make(map[string]bucket)
+	a.stats = newReadStats()
 	a.objects = data.NewCache(a.config.MaxSize)
 	a.multiPartObjects = make(map[string]*data.Cache)
+	// the callback is bound to a copy of the API, which must already hold its locks
 	a.objects.OnEvicted = a.evictedObject
-	a.lock = new(sync.Mutex)
 
 	if len(a.config.NodeDiskMap) > 0 {
 		for k, v := range a.config.NodeDiskMap {
@@ -127,10 +172,7 @@ func New() (Interface, *probe.Error) {
 /// V2 API functions
 
 // GetObject - GET object from cache buffer
-func (xl API) GetObject(w io.Writer, bucket string, object string, start, length int64) (int64, *probe.Error) {
-	xl.lock.Lock()
-	defer xl.lock.Unlock()
-
+func (xl API) GetObject(ctx context.Context, w io.Writer, bucket string, object string, start, length int64) (int64, *probe.Error) {
 	if !IsValidBucket(bucket) {
 		return 0, probe.NewError(BucketNameInvalid{Bucket: bucket})
 	}
@@ -147,11 +189,20 @@ func (xl API) GetObject(w io.Writer, bucket string, object string, start, length
 		return 0, probe.NewError(BucketNotFound{Bucket: bucket})
 	}
 	objectKey := bucket + "/" + object
+	unlock := xl.rlockObject(objectKey)
 	data, ok := xl.objects.Get(objectKey)
+	if !ok {
+		// objects read from disk are cached, readers of an uncached object are serialized
+		// such that it is cached once
+		unlock()
+		unlock = xl.lockObject(objectKey)
+		data, ok = xl.objects.Get(objectKey)
+	}
+	defer unlock()
 	var written int64
 	if !ok {
 		if len(xl.config.NodeDiskMap) > 0 {
-			reader, size, err := xl.getObject(bucket, object)
+			reader, size, err := xl.getObject(ctx, bucket, object)
 			if err != nil {
 				return 0, err.Trace()
 			}
@@ -202,11 +253,72 @@ func (xl API) GetObject(w io.Writer, bucket string, object string, start, length
 	return written, nil
 }
 
+// GetObjectRanges - GET multiple discontiguous ranges of an object, the object is read once and a
+// reader is returned for every range in the order requested. Ranges must lie within the object
+// and must not overlap. Ranges of cached objects are not copied
+func (xl API) GetObjectRanges(ctx context.Context, bucket, object string, ranges []ByteRange) ([]io.Reader, *probe.Error) {
+	if !IsValidBucket(bucket) {
+		return nil, probe.NewError(BucketNameInvalid{Bucket: bucket})
+	}
+	if !IsValidObjectName(object) {
+		return nil, probe.NewError(ObjectNameInvalid{Object: object})
+	}
+	if len(ranges) == 0 {
+		return nil, probe.NewError(InvalidArgument{})
+	}
+	// ranges are read in the order they appear in the object
+	sortedRanges := make(byRangeStart, len(ranges))
+	for i, byteRange := range ranges {
+		sortedRanges[i] = indexedRange{index: i, ByteRange: byteRange}
+	}
+	sort.Sort(sortedRanges)
+
+	if !xl.storedBuckets.Exists(bucket) {
+		return nil, probe.NewError(BucketNotFound{Bucket: bucket})
+	}
+	objectKey := bucket + "/" + object
+	unlock := xl.rlockObject(objectKey)
+	if data, ok := xl.objects.Get(objectKey); ok {
+		unlock()
+		if err := sortedRanges.validate(int64(len(data))); err != nil {
+			return nil, err.Trace()
+		}
+		readers := make([]io.Reader, len(ranges))
+		for _, byteRange := range sortedRanges {
+			readers[byteRange.index] = bytes.NewReader(data[byteRange.Start : byteRange.Start+byteRange.Length])
+		}
+		return readers, nil
+	}
+	unlock()
+	if len(xl.config.NodeDiskMap) == 0 {
+		return nil, probe.NewError(ObjectNotFound{Object: object})
+	}
+	reader, size, err := xl.getObject(ctx, bucket, object)
+	if err != nil {
+		return nil, err.Trace()
+	}
+	defer reader.Close()
+	if err := sortedRanges.validate(size); err != nil {
+		return nil, err.Trace()
+	}
+	readers := make([]io.Reader, len(ranges))
+	var offset int64
+	for _, byteRange := range sortedRanges {
+		if _, err := io.CopyN(ioutil.Discard, reader, byteRange.Start-offset); err != nil {
+			return nil, probe.NewError(err)
+		}
+		var buffer bytes.Buffer
+		if _, err := io.CopyN(&buffer, reader, byteRange.Length); err != nil {
+			return nil, probe.NewError(err)
+		}
+		readers[byteRange.index] = &buffer
+		offset = byteRange.Start + byteRange.Length
+	}
+	return readers, nil
+}
+
 // GetBucketMetadata -
 func (xl API) GetBucketMetadata(bucket string) (BucketMetadata, *probe.Error) {
-	xl.lock.Lock()
-	defer xl.lock.Unlock()
-
 	if !IsValidBucket(bucket) {
 		return BucketMetadata{}, probe.NewError(BucketNameInvalid{Bucket: bucket})
 	}
@@ -216,9 +328,11 @@ func (xl API) GetBucketMetadata(bucket string) (BucketMetadata, *probe.Error) {
 			if err != nil {
 				return BucketMetadata{}, err.Trace()
 			}
+			xl.lock.Lock()
 			storedBucket := xl.storedBuckets.Get(bucket).(storedBucket)
 			storedBucket.bucketMetadata = bucketMetadata
 			xl.storedBuckets.Set(bucket, storedBucket)
+			xl.lock.Unlock()
 		}
 		return BucketMetadata{}, probe.NewError(BucketNotFound{Bucket: bucket})
 	}
@@ -227,60 +341,223 @@ func (xl API) GetBucketMetadata(bucket string) (BucketMetadata, *probe.Error) {
 
 // SetBucketMetadata -
 func (xl API) SetBucketMetadata(bucket string, metadata map[string]string) *probe.Error {
+	if !IsValidBucket(bucket) {
+		return probe.NewError(BucketNameInvalid{Bucket: bucket})
+	}
+	if !xl.storedBuckets.Exists(bucket) {
+		return probe.NewError(BucketNotFound{Bucket: bucket})
+	}
+	if len(xl.config.NodeDiskMap) > 0 {
+		if err := xl.setBucketMetadata(bucket, metadata); err != nil {
+			return err.Trace()
+		}
+	}
 	xl.lock.Lock()
 	defer xl.lock.Unlock()
+	storedBucket := xl.storedBuckets.Get(bucket).(storedBucket)
+	storedBucket.bucketMetadata.ACL = BucketACL(metadata["acl"])
+	xl.storedBuckets.Set(bucket, storedBucket)
+	return nil
+}
 
+// SetBucketETagAlgorithm - set digest used as ETag for new objects in bucket, "md5" or "sha256"
+func (xl API) SetBucketETagAlgorithm(bucket, algorithm string) *probe.Error {
 	if !IsValidBucket(bucket) {
 		return probe.NewError(BucketNameInvalid{Bucket: bucket})
 	}
+	if algorithm != etagMD5 && algorithm != etagSHA256 {
+		return probe.NewError(InvalidArgument{})
+	}
 	if !xl.storedBuckets.Exists(bucket) {
 		return probe.NewError(BucketNotFound{Bucket: bucket})
 	}
 	if len(xl.config.NodeDiskMap) > 0 {
-		if err := xl.setBucketMetadata(bucket, metadata); err != nil {
+		if err := xl.setBucketETagAlgorithm(bucket, algorithm); err != nil {
 			return err.Trace()
 		}
 	}
+	xl.lock.Lock()
+	defer xl.lock.Unlock()
 	storedBucket := xl.storedBuckets.Get(bucket).(storedBucket)
-	storedBucket.bucketMetadata.ACL = BucketACL(metadata["acl"])
+	storedBucket.bucketMetadata.ETagAlgorithm = algorithm
 	xl.storedBuckets.Set(bucket, storedBucket)
 	return nil
 }
 
-// isMD5SumEqual - returns error if md5sum mismatches, success its `nil`
-func isMD5SumEqual(expectedMD5Sum, actualMD5Sum string) *probe.Error {
-	if strings.TrimSpace(expectedMD5Sum) != "" && strings.TrimSpace(actualMD5Sum) != "" {
-		expectedMD5SumBytes, err := hex.DecodeString(expectedMD5Sum)
-		if err != nil {
-			return probe.NewError(err)
+// SetBucketLifecycle - set lifecycle rules expiring objects in bucket, replaces any previous rules
+func (xl API) SetBucketLifecycle(bucket string, rules []LifecycleRule) *probe.Error {
+	if !IsValidBucket(bucket) {
+		return probe.NewError(BucketNameInvalid{Bucket: bucket})
+	}
+	if !isValidLifecycleRules(rules) {
+		return probe.NewError(InvalidArgument{})
+	}
+	if !xl.storedBuckets.Exists(bucket) {
+		return probe.NewError(BucketNotFound{Bucket: bucket})
+	}
+	if len(xl.config.NodeDiskMap) > 0 {
+		if err := xl.setBucketLifecycle(bucket, rules); err != nil {
+			return err.Trace()
 		}
-		actualMD5SumBytes, err := hex.DecodeString(actualMD5Sum)
-		if err != nil {
-			return probe.NewError(err)
+	}
+	xl.lock.Lock()
+	defer xl.lock.Unlock()
+	storedBucket := xl.storedBuckets.Get(bucket).(storedBucket)
+	storedBucket.bucketMetadata.LifecycleRules = rules
+	xl.storedBuckets.Set(bucket, storedBucket)
+	return nil
+}
+
+// SetBucketImmutability - objects in bucket can no longer be overwritten or deleted once immutableAfter
+// has passed since their creation, zero disables the policy
+func (xl API) SetBucketImmutability(bucket string, immutableAfter time.Duration) *probe.Error {
+	if !IsValidBucket(bucket) {
+		return probe.NewError(BucketNameInvalid{Bucket: bucket})
+	}
+	if immutableAfter < 0 {
+		return probe.NewError(InvalidArgument{})
+	}
+	if !xl.storedBuckets.Exists(bucket) {
+		return probe.NewError(BucketNotFound{Bucket: bucket})
+	}
+	if len(xl.config.NodeDiskMap) > 0 {
+		if err := xl.setBucketImmutability(bucket, immutableAfter); err != nil {
+			return err.Trace()
+		}
+	}
+	xl.lock.Lock()
+	defer xl.lock.Unlock()
+	storedBucket := xl.storedBuckets.Get(bucket).(storedBucket)
+	storedBucket.bucketMetadata.ImmutableAfter = immutableAfter
+	xl.storedBuckets.Set(bucket, storedBucket)
+	return nil
+}
+
+// SetBucketMaxObjectCount - bucket holds at most maxObjectCount objects, writes of new objects beyond
+// it are rejected with TooManyObjects, zero disables the limit
+func (xl API) SetBucketMaxObjectCount(bucket string, maxObjectCount int) *probe.Error {
+	if !IsValidBucket(bucket) {
+		return probe.NewError(BucketNameInvalid{Bucket: bucket})
+	}
+	if maxObjectCount < 0 {
+		return probe.NewError(InvalidArgument{})
+	}
+	if !xl.storedBuckets.Exists(bucket) {
+		return probe.NewError(BucketNotFound{Bucket: bucket})
+	}
+	if len(xl.config.NodeDiskMap) > 0 {
+		if err := xl.setBucketMaxObjectCount(bucket, maxObjectCount); err != nil {
+			return err.Trace()
 		}
-		if !bytes.Equal(expectedMD5SumBytes, actualMD5SumBytes) {
-			return probe.NewError(BadDigest{})
+	}
+	xl.lock.Lock()
+	defer xl.lock.Unlock()
+	storedBucket := xl.storedBuckets.Get(bucket).(storedBucket)
+	storedBucket.bucketMetadata.MaxObjectCount = maxObjectCount
+	xl.storedBuckets.Set(bucket, storedBucket)
+	return nil
+}
+
+// SetBucketBlockSize - new objects in bucket are erasure coded in chunks of size bytes, objects already
+// written are read by the block size kept with them
+func (xl API) SetBucketBlockSize(bucket string, size int64) *probe.Error {
+	if !IsValidBucket(bucket) {
+		return probe.NewError(BucketNameInvalid{Bucket: bucket})
+	}
+	if !isValidBlockSize(size) {
+		return probe.NewError(InvalidArgument{})
+	}
+	if !xl.storedBuckets.Exists(bucket) {
+		return probe.NewError(BucketNotFound{Bucket: bucket})
+	}
+	if len(xl.config.NodeDiskMap) > 0 {
+		if err := xl.setBucketBlockSize(bucket, size); err != nil {
+			return err.Trace()
 		}
-		return nil
 	}
-	return probe.NewError(InvalidArgument{})
+	xl.lock.Lock()
+	defer xl.lock.Unlock()
+	storedBucket := xl.storedBuckets.Get(bucket).(storedBucket)
+	storedBucket.bucketMetadata.BlockSize = size
+	xl.storedBuckets.Set(bucket, storedBucket)
+	return nil
 }
 
-// CreateObject - create an object
-func (xl API) CreateObject(bucket, key, expectedMD5Sum string, size int64, data io.Reader, metadata map[string]string, signature *signature4.Sign) (ObjectMetadata, *probe.Error) {
+// SetBucketQuota - bucket holds at most quota bytes of objects and of parts of uploads in progress,
+// objects and parts taking the bucket beyond it are rejected with QuotaExceeded, zero disables the quota
+func (xl API) SetBucketQuota(bucket string, quota int64) *probe.Error {
+	if !IsValidBucket(bucket) {
+		return probe.NewError(BucketNameInvalid{Bucket: bucket})
+	}
+	if quota < 0 {
+		return probe.NewError(InvalidArgument{})
+	}
+	if !xl.storedBuckets.Exists(bucket) {
+		return probe.NewError(BucketNotFound{Bucket: bucket})
+	}
+	if len(xl.config.NodeDiskMap) > 0 {
+		if err := xl.setBucketQuota(bucket, quota); err != nil {
+			return err.Trace()
+		}
+	}
 	xl.lock.Lock()
 	defer xl.lock.Unlock()
+	storedBucket := xl.storedBuckets.Get(bucket).(storedBucket)
+	storedBucket.bucketMetadata.Quota = quota
+	xl.storedBuckets.Set(bucket, storedBucket)
+	return nil
+}
+
+// isMD5SumEqual - returns error if md5sum mismatches, success its `nil`, see isChecksumEqual
+func isMD5SumEqual(expectedMD5Sum, actualMD5Sum string) *probe.Error {
+	return isChecksumEqual(expectedMD5Sum, actualMD5Sum)
+}
+
+// isChecksumEqual - returns error if hex encoded checksums of any algorithm mismatch, success its `nil`.
+// An empty or whitespace only expected checksum requests no verification and always succeeds, an empty
+// actual checksum with a non empty expected one has nothing to verify against and fails with MissingDigest
+func isChecksumEqual(expectedSum, actualSum string) *probe.Error {
+	expectedSum = strings.TrimSpace(expectedSum)
+	actualSum = strings.TrimSpace(actualSum)
+	if expectedSum == "" {
+		return nil
+	}
+	if actualSum == "" {
+		return probe.NewError(MissingDigest{})
+	}
+	expectedSumBytes, err := hex.DecodeString(expectedSum)
+	if err != nil {
+		return probe.NewError(err)
+	}
+	actualSumBytes, err := hex.DecodeString(actualSum)
+	if err != nil {
+		return probe.NewError(err)
+	}
+	return isChecksumBytesEqual(expectedSumBytes, actualSumBytes)
+}
+
+// isChecksumBytesEqual - returns error if checksums of any algorithm and encoding mismatch once
+// decoded, success its `nil`
+func isChecksumBytesEqual(expectedSum, actualSum []byte) *probe.Error {
+	if !bytes.Equal(expectedSum, actualSum) {
+		return probe.NewError(BadDigest{})
+	}
+	return nil
+}
 
-	contentType := metadata["contentType"]
-	objectMetadata, err := xl.createObject(bucket, key, contentType, expectedMD5Sum, size, data, signature)
+// CreateObject - create an object
+func (xl API) CreateObject(ctx context.Context, bucket, key, expectedMD5Sum string, size int64, data io.Reader, metadata map[string]string, signature *signature4.Sign) (ObjectMetadata, *probe.Error) {
+	// writes of the same object are serialized, other objects are written concurrently
+	defer xl.lockObject(bucket + "/" + key)()
+	objectMetadata, err := xl.createObject(ctx, bucket, key, expectedMD5Sum, size, data, metadata, nil, signature)
 	// free
 	debug.FreeOSMemory()
 
 	return objectMetadata, err.Trace()
 }
 
-// createObject - PUT object to cache buffer
-func (xl API) createObject(bucket, key, contentType, expectedMD5Sum string, size int64, data io.Reader, signature *signature4.Sign) (ObjectMetadata, *probe.Error) {
+// createObject - PUT object to cache buffer, parts are given for objects assembled by a multipart upload
+func (xl API) createObject(ctx context.Context, bucket, key, expectedMD5Sum string, size int64, data io.Reader, metadata map[string]string, parts []PartMetadata, signature *signature4.Sign) (ObjectMetadata, *probe.Error) {
 	if len(xl.config.NodeDiskMap) == 0 {
 		if size > int64(xl.config.MaxSize) {
 			generic := GenericObjectError{Bucket: bucket, Object: key}
@@ -294,23 +571,35 @@ func (xl API) createObject(bucket, key, contentType, expectedMD5Sum string, size
 	if !IsValidBucket(bucket) {
 		return ObjectMetadata{}, probe.NewError(BucketNameInvalid{Bucket: bucket})
 	}
-	if !IsValidObjectName(key) {
+	if !IsValidObjectName(key) || isReservedObjectName(key, xl.config.ReservedObjectPrefixes) {
 		return ObjectMetadata{}, probe.NewError(ObjectNameInvalid{Object: key})
 	}
 	if !xl.storedBuckets.Exists(bucket) {
 		return ObjectMetadata{}, probe.NewError(BucketNotFound{Bucket: bucket})
 	}
-	storedBucket := xl.storedBuckets.Get(bucket).(storedBucket)
 	// get object key
 	objectKey := bucket + "/" + key
-	if _, ok := storedBucket.objectMetadata[objectKey]; ok == true {
+	xl.lock.RLock()
+	storedBucket := xl.storedBuckets.Get(bucket).(storedBucket)
+	_, exists := storedBucket.objectMetadata[objectKey]
+	objectCount := len(storedBucket.objectMetadata)
+	xl.lock.RUnlock()
+	if exists {
 		return ObjectMetadata{}, probe.NewError(ObjectExists{Object: key})
 	}
+	// with disks the count is enforced against the bucket index on write
+	if len(xl.config.NodeDiskMap) == 0 {
+		maxObjectCount := storedBucket.bucketMetadata.MaxObjectCount
+		if maxObjectCount > 0 && objectCount >= maxObjectCount {
+			return ObjectMetadata{}, probe.NewError(TooManyObjects{Bucket: bucket})
+		}
+	}
 
-	if contentType == "" {
-		contentType = "application/octet-stream"
+	metadata, contentType := normalizeMetadata(metadata)
+	objectMetadata := map[string]string{contentTypeKey: contentType}
+	for name, value := range metadata {
+		objectMetadata[name] = value
 	}
-	contentType = strings.TrimSpace(contentType)
 	if strings.TrimSpace(expectedMD5Sum) != "" {
 		expectedMD5SumBytes, err := base64.StdEncoding.DecodeString(strings.TrimSpace(expectedMD5Sum))
 		if err != nil {
@@ -321,25 +610,28 @@ func (xl API) createObject(bucket, key, contentType, expectedMD5Sum string, size
 	}
 
 	if len(xl.config.NodeDiskMap) > 0 {
+		objectMetadata["contentLength"] = strconv.FormatInt(size, 10)
 		objMetadata, err := xl.putObject(
+			ctx,
 			bucket,
 			key,
 			expectedMD5Sum,
 			data,
 			size,
-			map[string]string{
-				"contentType":   contentType,
-				"contentLength": strconv.FormatInt(size, 10),
-			},
+			objectMetadata,
+			parts,
 			signature,
 		)
 		if err != nil {
 			return ObjectMetadata{}, err.Trace()
 		}
-		storedBucket.objectMetadata[objectKey] = objMetadata
-		xl.storedBuckets.Set(bucket, storedBucket)
+		xl.storeObjectMetadata(bucket, objectKey, objMetadata)
 		return objMetadata, nil
 	}
+	quota := storedBucket.bucketMetadata.Quota
+	if quota > 0 && xl.bucketUsage(bucket)+size > quota {
+		return ObjectMetadata{}, probe.NewError(QuotaExceeded{Bucket: bucket})
+	}
 
 	// calculate md5
 	hash := md5.New()
@@ -372,6 +664,11 @@ func (xl API) createObject(bucket, key, contentType, expectedMD5Sum string, size
 	if err != io.EOF {
 		return ObjectMetadata{}, probe.NewError(err)
 	}
+	// objects of unknown size are verified against the quota once read
+	if quota > 0 && xl.bucketUsage(bucket)+totalLength > quota {
+		xl.objects.Delete(objectKey)
+		return ObjectMetadata{}, probe.NewError(QuotaExceeded{Bucket: bucket})
+	}
 	md5SumBytes := hash.Sum(nil)
 	md5Sum := hex.EncodeToString(md5SumBytes)
 	// Verify if the written object is equal to what is expected, only if it is requested as such
@@ -396,28 +693,44 @@ func (xl API) createObject(bucket, key, contentType, expectedMD5Sum string, size
 		}
 	}
 
-	m := make(map[string]string)
-	m["contentType"] = contentType
 	newObject := ObjectMetadata{
 		Bucket: bucket,
 		Object: key,
 
-		Metadata: m,
-		Created:  time.Now().UTC(),
-		MD5Sum:   md5Sum,
-		Size:     int64(totalLength),
+		Metadata:    objectMetadata,
+		ContentType: contentType,
+		Created:     time.Now().UTC(),
+		MD5Sum:      md5Sum,
+		ETag:        md5Sum,
+		Size:        int64(totalLength),
+		Parts:       parts,
+	}
+	if storedBucket.bucketMetadata.ETagAlgorithm == etagSHA256 {
+		newObject.ETag = hex.EncodeToString(sha256hash.Sum(nil))
 	}
 
-	storedBucket.objectMetadata[objectKey] = newObject
-	xl.storedBuckets.Set(bucket, storedBucket)
+	xl.storeObjectMetadata(bucket, objectKey, newObject)
 	return newObject, nil
 }
 
-// MakeBucket - create bucket in cache
-func (xl API) MakeBucket(bucketName, acl string, location io.Reader, signature *signature4.Sign) *probe.Error {
+// storeObjectMetadata - keep the metadata of an object of a bucket in memory
+func (xl API) storeObjectMetadata(bucket, objectKey string, objMetadata ObjectMetadata) {
 	xl.lock.Lock()
 	defer xl.lock.Unlock()
+	storedBucket := xl.storedBuckets.Get(bucket).(storedBucket)
+	storedBucket.objectMetadata[objectKey] = objMetadata
+	xl.storedBuckets.Set(bucket, storedBucket)
+}
 
+// bucketUsage - bytes of the objects and parts of a bucket kept in memory
+func (xl API) bucketUsage(bucket string) int64 {
+	xl.lock.RLock()
+	defer xl.lock.RUnlock()
+	return xl.storedBuckets.Get(bucket).(storedBucket).usage()
+}
+
+// MakeBucket - create bucket in cache
+func (xl API) MakeBucket(bucketName, acl string, location io.Reader, signature *signature4.Sign) *probe.Error {
 	// do not have to parse location constraint, using this just for signature verification
 	locationSum := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
 	if location != nil {
@@ -461,6 +774,12 @@ func (xl API) MakeBucket(bucketName, acl string, location io.Reader, signature *
 			return err.Trace()
 		}
 	}
+	xl.lock.Lock()
+	defer xl.lock.Unlock()
+	// buckets made concurrently without disks are only checked here
+	if xl.storedBuckets.Exists(bucketName) {
+		return probe.NewError(BucketExists{Bucket: bucketName})
+	}
 	var newBucket = storedBucket{}
 	newBucket.objectMetadata = make(map[string]ObjectMetadata)
 	newBucket.multiPartSession = make(map[string]MultiPartSession)
@@ -473,11 +792,39 @@ func (xl API) MakeBucket(bucketName, acl string, location io.Reader, signature *
 	return nil
 }
 
+// ListObjectChanges - list objects created or modified after a given change sequence, changes are
+// returned in sequence order such that the last sequence can be used to continue from
+func (xl API) ListObjectChanges(bucket string, sequence uint64) ([]ObjectChange, *probe.Error) {
+	if !IsValidBucket(bucket) {
+		return nil, probe.NewError(BucketNameInvalid{Bucket: bucket})
+	}
+	if len(xl.config.NodeDiskMap) == 0 {
+		return nil, probe.NewError(APINotImplemented{API: "ListObjectChanges"})
+	}
+	changes, err := xl.listObjectChanges(bucket, sequence, time.Time{})
+	if err != nil {
+		return nil, err.Trace()
+	}
+	return changes, nil
+}
+
+// ListObjectsModifiedSince - list objects created or modified after a given time
+func (xl API) ListObjectsModifiedSince(bucket string, t time.Time) ([]ObjectChange, *probe.Error) {
+	if !IsValidBucket(bucket) {
+		return nil, probe.NewError(BucketNameInvalid{Bucket: bucket})
+	}
+	if len(xl.config.NodeDiskMap) == 0 {
+		return nil, probe.NewError(APINotImplemented{API: "ListObjectsModifiedSince"})
+	}
+	changes, err := xl.listObjectChanges(bucket, 0, t)
+	if err != nil {
+		return nil, err.Trace()
+	}
+	return changes, nil
+}
+
 // ListObjects - list objects from cache
 func (xl API) ListObjects(bucket string, resources BucketResourcesMetadata) ([]ObjectMetadata, BucketResourcesMetadata, *probe.Error) {
-	xl.lock.Lock()
-	defer xl.lock.Unlock()
-
 	if !IsValidBucket(bucket) {
 		return nil, BucketResourcesMetadata{IsTruncated: false}, probe.NewError(BucketNameInvalid{Bucket: bucket})
 	}
@@ -502,6 +849,7 @@ func (xl API) ListObjects(bucket string, resources BucketResourcesMetadata) ([]O
 		}
 		resources.CommonPrefixes = listObjects.CommonPrefixes
 		resources.IsTruncated = listObjects.IsTruncated
+		resources.NextMarker = listObjects.NextMarker
 		for key := range listObjects.Objects {
 			keys = append(keys, key)
 		}
@@ -509,11 +857,10 @@ func (xl API) ListObjects(bucket string, resources BucketResourcesMetadata) ([]O
 		for _, key := range keys {
 			results = append(results, listObjects.Objects[key])
 		}
-		if resources.IsTruncated && resources.Delimiter != "" {
-			resources.NextMarker = results[len(results)-1].Object
-		}
 		return results, resources, nil
 	}
+	xl.lock.RLock()
+	defer xl.lock.RUnlock()
 	storedBucket := xl.storedBuckets.Get(bucket).(storedBucket)
 	for key := range storedBucket.objectMetadata {
 		if strings.HasPrefix(key, bucket+"/") {
@@ -537,25 +884,22 @@ func (xl API) ListObjects(bucket string, resources BucketResourcesMetadata) ([]O
 		prefixes = SplitDelimiter(prefixes, resources.Delimiter)
 		prefixes = SortUnique(prefixes)
 	}
+	var commonPrefixes []string
 	for _, commonPrefix := range prefixes {
-		resources.CommonPrefixes = append(resources.CommonPrefixes, resources.Prefix+commonPrefix)
+		commonPrefixes = append(commonPrefixes, resources.Prefix+commonPrefix)
 	}
+	commonPrefixes = RemoveDuplicates(commonPrefixes)
+	sort.Strings(commonPrefixes)
 	filteredKeys = RemoveDuplicates(filteredKeys)
 	sort.Strings(filteredKeys)
-
+	for i, key := range filteredKeys {
+		filteredKeys[i] = resources.Prefix + key
+	}
+	// objects and common prefixes count together against maxkeys, as in the bucket listing
+	filteredKeys, resources.CommonPrefixes, resources.IsTruncated, resources.NextMarker = limitObjectNames(filteredKeys, commonPrefixes, resources.Marker, resources.Maxkeys)
 	for _, key := range filteredKeys {
-		if len(results) == resources.Maxkeys {
-			resources.IsTruncated = true
-			if resources.IsTruncated && resources.Delimiter != "" {
-				resources.NextMarker = results[len(results)-1].Object
-			}
-			return results, resources, nil
-		}
-		object := storedBucket.objectMetadata[bucket+"/"+resources.Prefix+key]
-		results = append(results, object)
+		results = append(results, storedBucket.objectMetadata[bucket+"/"+key])
 	}
-	resources.CommonPrefixes = RemoveDuplicates(resources.CommonPrefixes)
-	sort.Strings(resources.CommonPrefixes)
 	return results, resources, nil
 }
 
@@ -566,11 +910,36 @@ func (b byBucketName) Len() int           { return len(b) }
 func (b byBucketName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
 func (b byBucketName) Less(i, j int) bool { return b[i].Name < b[j].Name }
 
+// indexedRange - byte range along with its index in the requested ranges
+type indexedRange struct {
+	index int
+	ByteRange
+}
+
+// byRangeStart is a sortable interface for byte ranges
+type byRangeStart []indexedRange
+
+func (b byRangeStart) Len() int           { return len(b) }
+func (b byRangeStart) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byRangeStart) Less(i, j int) bool { return b[i].Start < b[j].Start }
+
+// validate - sorted ranges must lie within an object of the given size and must not overlap
+func (b byRangeStart) validate(size int64) *probe.Error {
+	var offset int64
+	for i, byteRange := range b {
+		if byteRange.Start < 0 || byteRange.Length <= 0 || byteRange.Start+byteRange.Length > size {
+			return probe.NewError(InvalidRange{Start: byteRange.Start, Length: byteRange.Length})
+		}
+		if i > 0 && byteRange.Start < offset {
+			return probe.NewError(InvalidRange{Start: byteRange.Start, Length: byteRange.Length})
+		}
+		offset = byteRange.Start + byteRange.Length
+	}
+	return nil
+}
+
 // ListBuckets - List buckets from cache
 func (xl API) ListBuckets() ([]BucketMetadata, *probe.Error) {
-	xl.lock.Lock()
-	defer xl.lock.Unlock()
-
 	var results []BucketMetadata
 	if len(xl.config.NodeDiskMap) > 0 {
 		buckets, err := xl.listBuckets()
@@ -592,9 +961,6 @@ func (xl API) ListBuckets() ([]BucketMetadata, *probe.Error) {
 
 // GetObjectMetadata - get object metadata from cache
 func (xl API) GetObjectMetadata(bucket, key string) (ObjectMetadata, *probe.Error) {
-	xl.lock.Lock()
-	defer xl.lock.Unlock()
-
 	// check if bucket exists
 	if !IsValidBucket(bucket) {
 		return ObjectMetadata{}, probe.NewError(BucketNameInvalid{Bucket: bucket})
@@ -605,10 +971,15 @@ func (xl API) GetObjectMetadata(bucket, key string) (ObjectMetadata, *probe.Erro
 	if !xl.storedBuckets.Exists(bucket) {
 		return ObjectMetadata{}, probe.NewError(BucketNotFound{Bucket: bucket})
 	}
-	storedBucket := xl.storedBuckets.Get(bucket).(storedBucket)
 	objectKey := bucket + "/" + key
-	if objMetadata, ok := storedBucket.objectMetadata[objectKey]; ok == true {
-		return objMetadata, nil
+	defer xl.rlockObject(objectKey)()
+	xl.lock.RLock()
+	storedBucket := xl.storedBuckets.Get(bucket).(storedBucket)
+	objMetadata, ok := storedBucket.objectMetadata[objectKey]
+	xl.lock.RUnlock()
+	if ok {
+		// expiry is recomputed so that lifecycle rule changes are reflected
+		return setObjectExpiration(objMetadata, storedBucket.bucketMetadata.LifecycleRules), nil
 	}
 	if len(xl.config.NodeDiskMap) > 0 {
 		objMetadata, err := xl.getObjectMetadata(bucket, key)
@@ -616,13 +987,69 @@ func (xl API) GetObjectMetadata(bucket, key string) (ObjectMetadata, *probe.Erro
 			return ObjectMetadata{}, err.Trace()
 		}
 		// update
-		storedBucket.objectMetadata[objectKey] = objMetadata
-		xl.storedBuckets.Set(bucket, storedBucket)
+		xl.storeObjectMetadata(bucket, objectKey, objMetadata)
 		return objMetadata, nil
 	}
 	return ObjectMetadata{}, probe.NewError(ObjectNotFound{Object: key})
 }
 
+// DeleteObjects - delete objects from cache and disks, objects failing to delete are reported per
+// object in an AggregateError while the others are still deleted
+func (xl API) DeleteObjects(bucket string, objects []string) *probe.Error {
+	if !IsValidBucket(bucket) {
+		return probe.NewError(BucketNameInvalid{Bucket: bucket})
+	}
+	if !xl.storedBuckets.Exists(bucket) {
+		return probe.NewError(BucketNotFound{Bucket: bucket})
+	}
+	errs := make(map[string]error)
+	var valid []string
+	for _, object := range objects {
+		if !IsValidObjectName(object) {
+			errs[object] = ObjectNameInvalid{Bucket: bucket, Object: object}
+			continue
+		}
+		valid = append(valid, object)
+	}
+	if len(xl.config.NodeDiskMap) > 0 {
+		if err := xl.deleteObjects(bucket, valid); err != nil {
+			aggregate, ok := err.ToGoError().(AggregateError)
+			if !ok {
+				return err.Trace()
+			}
+			for object, e := range aggregate.Errors {
+				errs[object] = e
+			}
+		}
+	}
+	for _, object := range valid {
+		if _, ok := errs[object]; ok {
+			continue
+		}
+		objectKey := bucket + "/" + object
+		if !xl.deleteObject(bucket, objectKey) && len(xl.config.NodeDiskMap) == 0 {
+			errs[object] = ObjectNotFound{Object: object}
+		}
+	}
+	if len(errs) > 0 {
+		return probe.NewError(AggregateError{Errors: errs})
+	}
+	return nil
+}
+
+// deleteObject - forget an object of a bucket kept in memory, returns false if it was not kept
+func (xl API) deleteObject(bucket, objectKey string) bool {
+	defer xl.lockObject(objectKey)()
+	xl.lock.Lock()
+	storedBucket := xl.storedBuckets.Get(bucket).(storedBucket)
+	_, ok := storedBucket.objectMetadata[objectKey]
+	delete(storedBucket.objectMetadata, objectKey)
+	xl.lock.Unlock()
+	// evicting from the cache calls back evictedObject, taking the lock
+	xl.objects.Delete(objectKey)
+	return ok
+}
+
 // evictedObject callback function called when an item is evicted from memory
 func (xl API) evictedObject(a ...interface{}) {
 	cacheStats := xl.objects.Stats()
@@ -630,8 +1057,10 @@ func (xl API) evictedObject(a ...interface{}) {
 		cacheStats.Bytes, cacheStats.Items, cacheStats.Evicted)
 	key := a[0].(string)
 	// loop through all buckets
+	xl.lock.Lock()
 	for _, bucket := range xl.storedBuckets.GetAll() {
 		delete(bucket.(storedBucket).objectMetadata, key)
 	}
+	xl.lock.Unlock()
 	debug.FreeOSMemory()
 }
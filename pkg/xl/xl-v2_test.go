@@ -18,6 +18,7 @@ package xl
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/base64"
 	"encoding/hex"
@@ -131,7 +132,7 @@ func (s *MyCacheSuite) TestCreateMultipleBucketsAndList(c *C) {
 
 // test object create without bucket
 func (s *MyCacheSuite) TestNewObjectFailsWithoutBucket(c *C) {
-	_, err := dc.CreateObject("unknown", "obj", "", 0, nil, nil, nil)
+	_, err := dc.CreateObject(context.Background(), "unknown", "obj", "", 0, nil, nil, nil)
 	c.Assert(err, Not(IsNil))
 }
 
@@ -146,7 +147,7 @@ func (s *MyCacheSuite) TestNewObjectMetadata(c *C) {
 	err := dc.MakeBucket("foo6", "private", nil, nil)
 	c.Assert(err, IsNil)
 
-	objectMetadata, err := dc.CreateObject("foo6", "obj", expectedMd5Sum, int64(len(data)), reader, map[string]string{"contentType": "application/json"}, nil)
+	objectMetadata, err := dc.CreateObject(context.Background(), "foo6", "obj", expectedMd5Sum, int64(len(data)), reader, map[string]string{"contentType": "application/json"}, nil)
 	c.Assert(err, IsNil)
 	c.Assert(objectMetadata.MD5Sum, Equals, hex.EncodeToString(hasher.Sum(nil)))
 	c.Assert(objectMetadata.Metadata["contentType"], Equals, "application/json")
@@ -154,7 +155,7 @@ func (s *MyCacheSuite) TestNewObjectMetadata(c *C) {
 
 // test create object fails without name
 func (s *MyCacheSuite) TestNewObjectFailsWithEmptyName(c *C) {
-	_, err := dc.CreateObject("foo", "", "", 0, nil, nil, nil)
+	_, err := dc.CreateObject(context.Background(), "foo", "", "", 0, nil, nil, nil)
 	c.Assert(err, Not(IsNil))
 }
 
@@ -170,12 +171,12 @@ func (s *MyCacheSuite) TestNewObjectCanBeWritten(c *C) {
 	expectedMd5Sum := base64.StdEncoding.EncodeToString(hasher.Sum(nil))
 	reader := ioutil.NopCloser(bytes.NewReader([]byte(data)))
 
-	actualMetadata, err := dc.CreateObject("foo", "obj", expectedMd5Sum, int64(len(data)), reader, map[string]string{"contentType": "application/octet-stream"}, nil)
+	actualMetadata, err := dc.CreateObject(context.Background(), "foo", "obj", expectedMd5Sum, int64(len(data)), reader, map[string]string{"contentType": "application/octet-stream"}, nil)
 	c.Assert(err, IsNil)
 	c.Assert(actualMetadata.MD5Sum, Equals, hex.EncodeToString(hasher.Sum(nil)))
 
 	var buffer bytes.Buffer
-	size, err := dc.GetObject(&buffer, "foo", "obj", 0, 0)
+	size, err := dc.GetObject(context.Background(), &buffer, "foo", "obj", 0, 0)
 	c.Assert(err, IsNil)
 	c.Assert(size, Equals, int64(len(data)))
 	c.Assert(buffer.Bytes(), DeepEquals, []byte(data))
@@ -192,21 +193,21 @@ func (s *MyCacheSuite) TestMultipleNewObjects(c *C) {
 
 	one := ioutil.NopCloser(bytes.NewReader([]byte("one")))
 
-	_, err := dc.CreateObject("foo5", "obj1", "", int64(len("one")), one, nil, nil)
+	_, err := dc.CreateObject(context.Background(), "foo5", "obj1", "", int64(len("one")), one, nil, nil)
 	c.Assert(err, IsNil)
 
 	two := ioutil.NopCloser(bytes.NewReader([]byte("two")))
-	_, err = dc.CreateObject("foo5", "obj2", "", int64(len("two")), two, nil, nil)
+	_, err = dc.CreateObject(context.Background(), "foo5", "obj2", "", int64(len("two")), two, nil, nil)
 	c.Assert(err, IsNil)
 
 	var buffer1 bytes.Buffer
-	size, err := dc.GetObject(&buffer1, "foo5", "obj1", 0, 0)
+	size, err := dc.GetObject(context.Background(), &buffer1, "foo5", "obj1", 0, 0)
 	c.Assert(err, IsNil)
 	c.Assert(size, Equals, int64(len([]byte("one"))))
 	c.Assert(buffer1.Bytes(), DeepEquals, []byte("one"))
 
 	var buffer2 bytes.Buffer
-	size, err = dc.GetObject(&buffer2, "foo5", "obj2", 0, 0)
+	size, err = dc.GetObject(context.Background(), &buffer2, "foo5", "obj2", 0, 0)
 	c.Assert(err, IsNil)
 	c.Assert(size, Equals, int64(len([]byte("two"))))
 
@@ -245,11 +246,11 @@ func (s *MyCacheSuite) TestMultipleNewObjects(c *C) {
 	c.Assert(objectsMetadata[1].Object, Equals, "obj2")
 
 	three := ioutil.NopCloser(bytes.NewReader([]byte("three")))
-	_, err = dc.CreateObject("foo5", "obj3", "", int64(len("three")), three, nil, nil)
+	_, err = dc.CreateObject(context.Background(), "foo5", "obj3", "", int64(len("three")), three, nil, nil)
 	c.Assert(err, IsNil)
 
 	var buffer bytes.Buffer
-	size, err = dc.GetObject(&buffer, "foo5", "obj3", 0, 0)
+	size, err = dc.GetObject(context.Background(), &buffer, "foo5", "obj3", 0, 0)
 	c.Assert(err, IsNil)
 	c.Assert(size, Equals, int64(len([]byte("three"))))
 	c.Assert(buffer.Bytes(), DeepEquals, []byte("three"))
@@ -263,3 +264,76 @@ func (s *MyCacheSuite) TestMultipleNewObjects(c *C) {
 	c.Assert(resources.IsTruncated, Equals, true)
 	c.Assert(len(objectsMetadata), Equals, 2)
 }
+
+// test common prefixes count with objects against maxkeys, pages resuming after the next marker
+func (s *MyCacheSuite) TestPagedListObjectsWithCommonPrefixes(c *C) {
+	c.Assert(dc.MakeBucket("foo7", "private", nil, nil), IsNil)
+	for _, objectName := range []string{"p/a", "p/b/1", "p/b/2", "p/c", "p/d/1"} {
+		_, err := dc.CreateObject(context.Background(), "foo7", objectName, "", int64(len(objectName)), bytes.NewReader([]byte(objectName)), nil, nil)
+		c.Assert(err, IsNil)
+	}
+	var resources BucketResourcesMetadata
+	resources.Prefix = "p/"
+	resources.Delimiter = "/"
+	resources.Maxkeys = 2
+	objectsMetadata, resources, err := dc.ListObjects("foo7", resources)
+	c.Assert(err, IsNil)
+	c.Assert(len(objectsMetadata), Equals, 1)
+	c.Assert(objectsMetadata[0].Object, Equals, "p/a")
+	c.Assert(resources.CommonPrefixes, DeepEquals, []string{"p/b/"})
+	c.Assert(resources.IsTruncated, Equals, true)
+	c.Assert(resources.NextMarker, Equals, "p/b/")
+
+	resources.Marker = resources.NextMarker
+	objectsMetadata, resources, err = dc.ListObjects("foo7", resources)
+	c.Assert(err, IsNil)
+	c.Assert(len(objectsMetadata), Equals, 1)
+	c.Assert(objectsMetadata[0].Object, Equals, "p/c")
+	c.Assert(resources.CommonPrefixes, DeepEquals, []string{"p/d/"})
+	c.Assert(resources.IsTruncated, Equals, false)
+}
+
+// test deleting objects reports the objects which failed and deletes the others
+func (s *MyCacheSuite) TestObjectsDeletedInBatch(c *C) {
+	c.Assert(dc.MakeBucket("foo8", "private", nil, nil), IsNil)
+	for _, object := range []string{"obj1", "obj2"} {
+		_, err := dc.CreateObject(context.Background(), "foo8", object, "", int64(len("hello")), bytes.NewReader([]byte("hello")), nil, nil)
+		c.Assert(err, IsNil)
+	}
+
+	err := dc.DeleteObjects("foo8", []string{"obj1", "missing"})
+	c.Assert(err, Not(IsNil))
+	aggregate, ok := err.ToGoError().(AggregateError)
+	c.Assert(ok, Equals, true)
+	c.Assert(len(aggregate.Errors), Equals, 1)
+	_, ok = aggregate.Errors["missing"].(ObjectNotFound)
+	c.Assert(ok, Equals, true)
+
+	_, err = dc.GetObjectMetadata("foo8", "obj1")
+	c.Assert(err, Not(IsNil))
+	var buffer bytes.Buffer
+	_, err = dc.GetObject(context.Background(), &buffer, "foo8", "obj1", 0, 0)
+	c.Assert(err, Not(IsNil))
+	_, err = dc.GetObjectMetadata("foo8", "obj2")
+	c.Assert(err, IsNil)
+}
+
+// test objects kept in memory normalize their metadata as objects written to disk do
+func (s *MyCacheSuite) TestObjectContentTypeAndUserMetadata(c *C) {
+	c.Assert(dc.MakeBucket("foo9", "private", nil, nil), IsNil)
+	metadata := map[string]string{
+		"Content-Type":     " text/html ",
+		"X-AMZ-META-Color": "blue",
+		"Connection":       "keep-alive",
+	}
+	_, err := dc.CreateObject(context.Background(), "foo9", "obj", "", int64(len("hello")), bytes.NewReader([]byte("hello")), metadata, nil)
+	c.Assert(err, IsNil)
+	objMetadata, err := dc.GetObjectMetadata("foo9", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(objMetadata.ContentType, Equals, "text/html")
+	c.Assert(objMetadata.Metadata, DeepEquals, map[string]string{
+		"contentType":      "text/html",
+		"x-amz-meta-color": "blue",
+	})
+	c.Assert(objMetadata.UserMetadata(), DeepEquals, map[string]string{"x-amz-meta-color": "blue"})
+}
@@ -190,8 +190,9 @@ func serverHandler(conf cloudServerConfig) http.Handler {
 		setPrivateBucketHandler,
 		// Adds cache control for all browser requests.
 		setBrowserCacheControlHandler,
-		// Validates all incoming requests to have a valid date header.
-		setTimeValidityHandler,
+		// Validates all incoming signed requests to be dated within the
+		// allowed skew of the server time.
+		setTimeValidityHandler(api.Signature),
 		// CORS setting for all browser API requests.
 		setCorsHandler,
 		// Validates all incoming URL resources, for invalid/unsupported
@@ -560,6 +560,26 @@ func (s *MyAPIFSCacheSuite) TestPutObject(c *C) {
 	c.Assert(response.StatusCode, Equals, http.StatusOK)
 }
 
+func (s *MyAPIFSCacheSuite) TestPutObjectTimeTooSkewed(c *C) {
+	request, err := s.newRequest("PUT", testAPIFSCacheServer.URL+"/put-object-skewed", 0, nil)
+	c.Assert(err, IsNil)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	// request time is verified on arrival, before the body or the signature is
+	buffer1 := bytes.NewReader([]byte("hello world"))
+	request, err = s.newRequest("PUT", testAPIFSCacheServer.URL+"/put-object-skewed/object", int64(buffer1.Len()), buffer1)
+	c.Assert(err, IsNil)
+	request.Header.Set("x-amz-date", time.Now().UTC().Add(-time.Hour).Format(iso8601Format))
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.", http.StatusForbidden)
+}
+
 func (s *MyAPIFSCacheSuite) TestListBuckets(c *C) {
 	request, err := s.newRequest("GET", testAPIFSCacheServer.URL+"/", 0, nil)
 	c.Assert(err, IsNil)